@@ -1,11 +1,32 @@
 package v1beta1
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 type SyncState string
 
 const (
 	SyncSyncState  SyncState = "SYNC"
 	OkSyncState    SyncState = "OK"
 	ErrorSyncState SyncState = "ERROR"
+
+	// SkippedSyncState denotes that the operator deliberately did not act on the resource; the message tells why
+	SkippedSyncState SyncState = "SKIPPED"
+)
+
+const (
+	// BoundaryAppliedCondition reflects whether the permissions boundary given in the spec is applied in AWS
+	BoundaryAppliedCondition = "BoundaryApplied"
+
+	// UnusedCondition reflects whether the Role has not been used within the configured window
+	UnusedCondition = "Unused"
+
+	// ExpiredCondition reflects whether the TTL of an ephemeral resource has expired and its AWS resource is deleted
+	ExpiredCondition = "Expired"
+
+	// PolicyVersionDriftCondition reflects whether a policy attached to the Role has changed since it has been pinned
+	PolicyVersionDriftCondition = "PolicyVersionDrift"
 )
 
 type AWSObjectStatus struct {
@@ -34,4 +55,37 @@ type AWSObjectStatus struct {
 	//
 	// ObservedGeneration holds the generation (metadata.generation in CR) observed by the controller
 	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// +kubebuilder:validation:optional
+	//
+	// Conditions holds the latest observations of the state of the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Gate references a field of an object in the namespace of the resource (e.g. a key of a ConfigMap), that has to hold
+// a given value before the resource is reconciled
+type Gate struct {
+
+	// +kubebuilder:validation:Required
+	//
+	// APIVersion holds the API version of the referenced object, e.g. v1
+	APIVersion string `json:"apiVersion"`
+
+	// +kubebuilder:validation:Required
+	//
+	// Kind holds the kind of the referenced object; only ConfigMap is supported
+	Kind string `json:"kind"`
+
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Required
+	//
+	// FieldPath holds the dot-separated path of the field to check, e.g. data.ready
+	FieldPath string `json:"fieldPath"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// Value holds the value the field has to equal. If empty, the field only has to be set.
+	Value string `json:"value,omitempty"`
 }
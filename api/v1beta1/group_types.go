@@ -27,10 +27,28 @@ type GroupSpec struct {
 	// Users holds the list of all Users to be added the group
 	// +kubebuilder:validation:optional
 	Users []v1.ObjectReference `json:"users,omitempty"`
+
+	// ManagedPolicyArns holds the ARNs of managed policies to attach to the group directly
+	// +kubebuilder:validation:optional
+	ManagedPolicyArns []string `json:"managedPolicyArns,omitempty"`
+
+	// Path holds the IAM path (e.g. /teams/platform/) to create the group in; defaults to /
+	// +kubebuilder:validation:optional
+	// +kubebuilder:validation:Pattern=`^/([!-~]+/)?$`
+	Path string `json:"path,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// Gate holds a condition on another object, that has to be met before the Group is reconciled
+	Gate *Gate `json:"gate,omitempty"`
 }
 
 type GroupStatus struct {
 	AWSObjectStatus `json:",inline"`
+
+	// ManagedPolicyArns holds the ARNs of the managed policies attached to the group via spec
+	// +kubebuilder:validation:optional
+	ManagedPolicyArns []string `json:"managedPolicyArns,omitempty"`
 }
 
 // +kubebuilder:object:root=true
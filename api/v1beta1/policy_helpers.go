@@ -24,8 +24,26 @@ func (psc PolicyStatementCondition) Normalize() map[string]map[string]string {
 	return out
 }
 
+func (ps PolicyStatement) MarshalPolicyDocument() iam.PolicyDocument {
+	var policyStatement []iam.StatementEntry
+	for _, entry := range ps {
+		policyStatement = append(policyStatement, iam.StatementEntry{
+			Sid:       entry.Sid,
+			Effect:    entry.Effect.String(),
+			Action:    entry.Actions,
+			Resource:  entry.Resources,
+			Condition: entry.Conditions.Normalize(),
+		})
+	}
+
+	return iam.PolicyDocument{
+		Version:   PolicyVersion,
+		Statement: policyStatement,
+	}
+}
+
 func (p *Policy) GetStatus() *AWSObjectStatus {
-	return &p.Status
+	return &p.Status.AWSObjectStatus
 }
 
 func (p *Policy) RuntimeObject() client.Object {
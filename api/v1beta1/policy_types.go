@@ -79,6 +79,20 @@ type PolicyStatementEntry struct {
 
 type PolicyStatement []PolicyStatementEntry
 
+// InlinePolicy is a policy embedded directly in a Role or User
+type InlinePolicy struct {
+
+	//+kubebuilder:validation:Required
+	//
+	// Name holds the name of the inline policy
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Required
+	//
+	// Statement holds the list of all the policy statement entries
+	Statement PolicyStatement `json:"statement"`
+}
+
 // PolicySpec defines the desired state of Policy
 type PolicySpec struct {
 
@@ -96,6 +110,81 @@ type PolicySpec struct {
 	//
 	// AWSPolicyName is the name of the policy to create. If not specified, metadata.name will be used
 	AWSPolicyName string `json:"awsPolicyName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// ARNReferences holds resources managed by this operator, whose ARNs can be used in resources and conditions of
+	// the statement via the placeholder "${ref:<alias>}"
+	ARNReferences []ARNReference `json:"arnReferences,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// Gate holds a condition on another object, that has to be met before the Policy is reconciled
+	Gate *Gate `json:"gate,omitempty"`
+}
+
+// ARNReference references a resource managed by this operator, whose ARN is taken from its status
+type ARNReference struct {
+
+	// +kubebuilder:validation:Required
+	//
+	// Alias is the name used in the placeholder
+	Alias string `json:"alias"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Role;Policy;User;Group
+	//
+	// Kind is the kind of the referenced resource
+	Kind string `json:"kind"`
+
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// Namespace defaults to the namespace of the Policy
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PolicyStatus defines the observed state of Policy
+type PolicyStatus struct {
+	AWSObjectStatus `json:",inline"`
+
+	// +kubebuilder:validation:optional
+	//
+	// PolicyVersion holds the ID of the default version of the policy in AWS, as of the last change
+	PolicyVersion string `json:"policyVersion,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// ChangeGeneration holds the generation of the Policy the last change in AWS has been made for
+	ChangeGeneration int64 `json:"changeGeneration,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// ChangeNote holds the change note annotated on the Policy at the time of the last change in AWS
+	ChangeNote string `json:"changeNote,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// ValidationFindings holds the findings of IAM Access Analyzer for the policy document, if validation is enabled
+	ValidationFindings []string `json:"validationFindings,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// UnusedServices holds the namespaces of the services granted by the policy, but not accessed within the AWS
+	// tracking period, if least-privilege suggestions are enabled
+	UnusedServices []string `json:"unusedServices,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// LeastPrivilegeCheckedAt holds the time (RFC3339) the services accessed via the policy have last been checked
+	LeastPrivilegeCheckedAt string `json:"leastPrivilegeCheckedAt,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// LastAccessedJobID holds the ID of the running IAM job checking the services accessed via the policy
+	LastAccessedJobID string `json:"lastAccessedJobId,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -110,8 +199,8 @@ type Policy struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec   PolicySpec      `json:"spec,omitempty"`
-	Status AWSObjectStatus `json:"status,omitempty"`
+	Spec   PolicySpec   `json:"spec,omitempty"`
+	Status PolicyStatus `json:"status,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -41,6 +41,21 @@ type ExternalResource struct {
 	ARN string `json:"arn,omitempty"`
 }
 
+// ManagedPolicyReference references a managed policy to attach directly, without a PolicyAttachment. It is used by
+// User.Spec.ManagedPolicyArns, so Users can reference Policy resources; Group.Spec.ManagedPolicyArns only takes ARNs.
+type ManagedPolicyReference struct {
+
+	// PolicyReference references the Policy resource to attach
+	// +kubebuilder:validation:Optional
+	// +optional
+	PolicyReference ResourceReference `json:"policy,omitempty"`
+
+	// ExternalPolicy is a reference to a policy that is not created by the controller
+	// +kubebuilder:validation:Optional
+	// +optional
+	ExternalPolicy ExternalResource `json:"externalPolicy,omitempty"`
+}
+
 type TargetType string
 
 const (
@@ -79,6 +94,18 @@ type PolicyAttachmentSpec struct {
 	// Attachments holds all defined attachments
 	// +kubebuilder:validation:Required
 	TargetReference TargetReference `json:"target,omitempty"`
+
+	// Environments restricts the attachment to namespaces whose environment annotation holds one of the given values.
+	// In other environments the attachment is skipped, and detached if it has been made before. An empty list attaches
+	// in every environment.
+	// +kubebuilder:validation:Optional
+	// +optional
+	Environments []string `json:"environments,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// Gate holds a condition on another object, that has to be met before the PolicyAttachment is reconciled
+	Gate *Gate `json:"gate,omitempty"`
 }
 
 // +kubebuilder:object:root=true
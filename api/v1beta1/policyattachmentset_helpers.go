@@ -0,0 +1,35 @@
+package v1beta1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (pas *PolicyAttachmentSet) GetStatus() *AWSObjectStatus {
+	return &pas.Status.AWSObjectStatus
+}
+
+func (pas *PolicyAttachmentSet) RuntimeObject() client.Object {
+	return pas
+}
+
+func (pas *PolicyAttachmentSet) Metadata() metav1.ObjectMeta {
+	return pas.ObjectMeta
+}
+
+// PolicyAttachment returns the given entry of the set as a standalone PolicyAttachment in the namespace of the set,
+// so that it can be resolved the same way as any other attachment
+func (pas *PolicyAttachmentSet) PolicyAttachment(entry PolicyAttachmentSetEntry) *PolicyAttachment {
+	return &PolicyAttachment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pas.Name,
+			Namespace: pas.Namespace,
+		},
+		Spec: PolicyAttachmentSpec{
+			PolicyReference: entry.PolicyReference,
+			ExternalPolicy:  entry.ExternalPolicy,
+			TargetReference: entry.TargetReference,
+		},
+	}
+}
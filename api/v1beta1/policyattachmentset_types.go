@@ -0,0 +1,111 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PolicyAttachmentSetEntry defines a single pair of policy and target in a PolicyAttachmentSet
+type PolicyAttachmentSetEntry struct {
+
+	// PolicyReference refrences the Policy resource to attach to the target
+	// +kubebuilder:validation:Optional
+	// +optional
+	PolicyReference ResourceReference `json:"policy,omitempty"`
+
+	// ExternalPolicy is a reference to a policy that is not created by the controller
+	// +kubebuilder:validation:Optional
+	// +optional
+	ExternalPolicy ExternalResource `json:"externalPolicy,omitempty"`
+
+	// TargetReference references the resource to attach the policy to
+	// +kubebuilder:validation:Required
+	TargetReference TargetReference `json:"target,omitempty"`
+}
+
+// PolicyAttachmentSetSpec defines the desired state of PolicyAttachmentSet
+type PolicyAttachmentSetSpec struct {
+
+	// Attachments holds all pairs of policy and target to attach
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Attachments []PolicyAttachmentSetEntry `json:"attachments"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// Gate holds a condition on another object, that has to be met before the PolicyAttachmentSet is reconciled
+	Gate *Gate `json:"gate,omitempty"`
+}
+
+// PolicyAttachmentSetEntryStatus holds the state of a single attachment of a PolicyAttachmentSet
+type PolicyAttachmentSetEntryStatus struct {
+
+	// PolicyARN holds the ARN of the attached policy
+	PolicyARN string `json:"policyArn,omitempty"`
+
+	// TargetType holds the type of the target the policy is attached to
+	TargetType TargetType `json:"targetType,omitempty"`
+
+	// TargetARN holds the ARN of the target the policy is attached to
+	TargetARN string `json:"targetArn,omitempty"`
+
+	// State holds the current state of the attachment
+	State SyncState `json:"state"`
+
+	// Message holds the current/last status message for the attachment
+	Message string `json:"message,omitempty"`
+}
+
+// PolicyAttachmentSetStatus defines the observed state of PolicyAttachmentSet
+type PolicyAttachmentSetStatus struct {
+	AWSObjectStatus `json:",inline"`
+
+	// +kubebuilder:validation:optional
+	//
+	// Attachments holds the state of every attachment of the set
+	Attachments []PolicyAttachmentSetEntryStatus `json:"attachments,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=policyattachmentsets,shortName=iampolicyattachmentset
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.state`
+// +kubebuilder:printcolumn:name="Last Sync",type=string,JSONPath=`.status.lastSyncAttempt`
+
+// PolicyAttachmentSet is the Schema for the policyattachmentsets API
+type PolicyAttachmentSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PolicyAttachmentSetSpec   `json:"spec,omitempty"`
+	Status PolicyAttachmentSetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PolicyAttachmentSetList contains a list of PolicyAttachmentSet
+type PolicyAttachmentSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PolicyAttachmentSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PolicyAttachmentSet{}, &PolicyAttachmentSetList{})
+}
@@ -53,6 +53,93 @@ type RoleSpec struct {
 	//
 	// AWSRoleName is the name of the role to create. If not specified, metadata.name will be used
 	AWSRoleName string `json:"awsRoleName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// PermissionsBoundary holds the ARN of the managed policy to set as permissions boundary for the Role
+	PermissionsBoundary string `json:"permissionsBoundary,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// InlinePolicies holds the policies to embed into the Role. They are applied in order of their names.
+	InlinePolicies []InlinePolicy `json:"inlinePolicies,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// PolicySelector selects the Policies in the namespace of the Role, that get attached to the Role
+	PolicySelector *metav1.LabelSelector `json:"policySelector,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// TrustConditions holds conditions (e.g. aws:SourceAccount) to merge into all trust policy statements, that
+	// trust a service principal. This protects against the confused deputy problem.
+	TrustConditions PolicyStatementCondition `json:"trustConditions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// RequireMFA requires MFA for assuming the Role, by adding the condition aws:MultiFactorAuthPresent to every trust
+	// policy statement that allows an AWS principal (e.g. the users of an account)
+	RequireMFA bool `json:"requireMFA,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// TTLAfterLastSync makes the Role ephemeral; if it hasn't been refreshed within this duration, the AWS role is
+	// deleted. The Role counts as refreshed on creation, on every change of its spec and whenever its refreshed-at
+	// annotation is set to a later time. Other kinds don't support a TTL.
+	TTLAfterLastSync *metav1.Duration `json:"ttlAfterLastSync,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// DeleteOnExpiry deletes the Role resource itself (and with it the AWS role) when its TTL has expired
+	DeleteOnExpiry bool `json:"deleteOnExpiry,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// PinPolicyVersions records the default version of every managed policy attached to the Role, at the time it has
+	// been attached. A policy whose default version changes afterwards is reported as drifted.
+	PinPolicyVersions bool `json:"pinPolicyVersions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Refuse;Recreate
+	//
+	// RenameStrategy specifies what happens when the name of the AWS role changes, as AWS can't rename roles in place.
+	// Refuse (the default) keeps the existing role and reports an error; Recreate creates a role with the new name,
+	// moves the managed policies attached to the old role over and deletes the old role.
+	RenameStrategy RenameStrategy `json:"renameStrategy,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=10
+	//
+	// SessionPolicies holds the ARNs of the managed policies, that assumers of the Role are expected to pass as session
+	// policies. They are advisory only: AWS doesn't enforce them, but they are listed in the status for downstream
+	// tooling. Changing them doesn't recreate the role.
+	SessionPolicies []string `json:"sessionPolicies,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// Gate holds a condition on another object, that has to be met before the Role is reconciled
+	Gate *Gate `json:"gate,omitempty"`
+}
+
+// RenameStrategy specifies how the rename of an AWS role is handled
+type RenameStrategy string
+
+const (
+	RefuseRenameStrategy   RenameStrategy = "Refuse"
+	RecreateRenameStrategy RenameStrategy = "Recreate"
+)
+
+// PinnedPolicyVersion holds the version of a managed policy the Role depends on
+type PinnedPolicyVersion struct {
+
+	// ARN holds the ARN of the attached policy
+	ARN string `json:"arn"`
+
+	// PinnedVersion holds the default version of the policy at the time it has been attached
+	PinnedVersion string `json:"pinnedVersion"`
+
+	// CurrentVersion holds the default version of the policy as seen after the last reconcile
+	CurrentVersion string `json:"currentVersion"`
 }
 
 // +kubebuilder:object:root=true
@@ -75,6 +162,51 @@ type Role struct {
 type RoleStatus struct {
 	AWSObjectStatus             `json:",inline"`
 	ReadAssumeRolePolicyVersion string `json:"ReadAssumeRolePolicyVersion"`
+
+	// +kubebuilder:validation:optional
+	//
+	// InlinePolicies holds the names of the inline policies applied to the Role
+	InlinePolicies []string `json:"inlinePolicies,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// InlinePolicySize holds the aggregated size (in characters) of all inline policies
+	InlinePolicySize int `json:"inlinePolicySize,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// SelectedPolicies holds the ARNs of the Policies attached via policySelector
+	SelectedPolicies []string `json:"selectedPolicies,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// AttachedPolicies holds the ARNs of all managed policies attached to the Role, as seen after the last reconcile
+	AttachedPolicies []string `json:"attachedPolicies,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// LastUsed holds when the Role has last been used according to AWS
+	LastUsed string `json:"lastUsed,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// PolicyVersions holds the pinned and current versions of the managed policies attached to the Role, if pinned
+	PolicyVersions []PinnedPolicyVersion `json:"policyVersions,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// RenamedFromARN holds the ARN of the role that is replaced by a renamed one, until it has been deleted
+	RenamedFromARN string `json:"renamedFromArn,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// SpecChangedAt holds when a new generation of an ephemeral Role has last been observed; it counts as a refresh
+	SpecChangedAt string `json:"specChangedAt,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// SessionPolicies holds the ARNs of the session policies expected to be passed when assuming the Role
+	SessionPolicies []string `json:"sessionPolicies,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -26,8 +26,87 @@ type UserSpec struct {
 	// CreateLoginProfile triggers the creation of Login Profile in AWS and creates a user/pass secret
 	CreateLoginProfile bool `json:"createLoginProfile,omitempty"`
 
+	// +kubebuilder:validation:Optional
+	//
+	// LoginProfileTTL limits console access to the given duration after it has been granted. Once expired, the Login
+	// Profile and its secret are removed; to grant access again, disable and re-enable CreateLoginProfile.
+	LoginProfileTTL *metav1.Duration `json:"loginProfileTTL,omitempty"`
+
 	// CreateProgrammaticAccess triggers the creation of API creds in AWS and creates a cred secret
 	CreateProgrammaticAccess bool `json:"createProgrammaticAccess,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// ServiceSpecificCredentials holds the AWS services (e.g. codecommit.amazonaws.com) to create service-specific
+	// credentials for. Each generated credential is stored in a secret once.
+	ServiceSpecificCredentials []string `json:"serviceSpecificCredentials,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// PermissionsBoundary holds the ARN of the managed policy to set as permissions boundary for the User
+	PermissionsBoundary string `json:"permissionsBoundary,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// InlinePolicies holds the policies to embed into the User. They are applied in order of their names.
+	InlinePolicies []InlinePolicy `json:"inlinePolicies,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// ManagedPolicyArns holds the managed policies to attach to the User, each either by the ARN of an external policy
+	// or by reference to a Policy resource. Policies removed from the list are detached again, unless a
+	// PolicyAttachment or PolicyAttachmentSet attaches them as well. Unlike the ManagedPolicyArns of a Group, which
+	// are plain ARNs, the entries are ManagedPolicyReferences; the field keeps its name for symmetry with Groups.
+	ManagedPolicyArns []ManagedPolicyReference `json:"managedPolicyArns,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// Groups holds the Groups the User should be a member of. The namespace defaults to the one of the User.
+	// Membership must not be declared in the Group as well.
+	Groups []v1.ObjectReference `json:"groups,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// Tags holds the tags to set on the User. Tags set outside of the operator are left alone.
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// AccessKeysStatusOnly only reports the access keys of the User and their state in the status, for keys managed
+	// outside of the operator (e.g. by a rotation lambda). No keys are created and no secret is written, so it can't
+	// be combined with CreateProgrammaticAccess.
+	AccessKeysStatusOnly bool `json:"accessKeysStatusOnly,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	//
+	// Gate holds a condition on another object, that has to be met before the User is reconciled
+	Gate *Gate `json:"gate,omitempty"`
+}
+
+type ServiceSpecificCredentialStatus struct {
+
+	// ServiceName holds the name of the AWS service the credential is valid for
+	ServiceName string `json:"serviceName"`
+
+	// CredentialID holds the AWS ID of the service-specific credential
+	CredentialID string `json:"credentialId"`
+
+	// +kubebuilder:validation:optional
+	//
+	// Status holds the AWS status (Active/Inactive) of the service-specific credential
+	Status string `json:"status,omitempty"`
+
+	// Secret holds the reference to the created credential Secret
+	Secret v1.SecretReference `json:"secret"`
+}
+
+type AccessKeyStatus struct {
+
+	// AccessKeyID holds the ID of the access key
+	AccessKeyID string `json:"accessKeyId"`
+
+	// Status holds the AWS status (Active/Inactive) of the access key
+	Status string `json:"status"`
 }
 
 type UserStatus struct {
@@ -43,6 +122,16 @@ type UserStatus struct {
 	// LoginProfileSecret holds the reference to the created LoginProfile Secret
 	LoginProfileSecret v1.SecretReference `json:"loginProfileSecret,omitempty"`
 
+	// +kubebuilder:validation:optional
+	//
+	// LoginProfileGrantedAt holds the time (RFC3339) the LoginProfile has been granted
+	LoginProfileGrantedAt string `json:"loginProfileGrantedAt,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// LoginProfileExpired holds info about whether or not the LoginProfile has been removed, as its TTL expired
+	LoginProfileExpired bool `json:"loginProfileExpired,omitempty"`
+
 	// +kubebuilder:validation:optional
 	//
 	// ProgrammaticAccessCreated holds info about whether or not programmatic access credentials have been created for this user
@@ -52,6 +141,41 @@ type UserStatus struct {
 	//
 	// ProgrammaticAccessSecret holds the reference to the created LoginProfile Secret
 	ProgrammaticAccessSecret v1.SecretReference `json:"programmaticAccessSecret,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// ServiceSpecificCredentials holds info about the service-specific credentials created for this user
+	ServiceSpecificCredentials []ServiceSpecificCredentialStatus `json:"serviceSpecificCredentials,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// InlinePolicies holds the names of the inline policies applied to the User
+	InlinePolicies []string `json:"inlinePolicies,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// InlinePolicySize holds the aggregated size (in characters) of all inline policies
+	InlinePolicySize int `json:"inlinePolicySize,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// ManagedPolicies holds the ARNs of the managed policies attached to the User via managedPolicyArns
+	ManagedPolicies []string `json:"managedPolicies,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// Tags holds the keys of the tags applied to the User
+	Tags []string `json:"tags,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// AccessKeys holds the access keys of the User, if only their status is reported
+	AccessKeys []AccessKeyStatus `json:"accessKeys,omitempty"`
+
+	// +kubebuilder:validation:optional
+	//
+	// Groups holds the names of the AWS groups the User has been added to via spec
+	Groups []string `json:"groups,omitempty"`
 }
 
 // +kubebuilder:object:root=true
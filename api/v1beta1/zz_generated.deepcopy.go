@@ -22,13 +22,36 @@ limitations under the License.
 package v1beta1
 
 import (
-	"k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ARNReference) DeepCopyInto(out *ARNReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ARNReference.
+func (in *ARNReference) DeepCopy() *ARNReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ARNReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AWSObjectStatus) DeepCopyInto(out *AWSObjectStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSObjectStatus.
@@ -41,6 +64,21 @@ func (in *AWSObjectStatus) DeepCopy() *AWSObjectStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessKeyStatus) DeepCopyInto(out *AccessKeyStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessKeyStatus.
+func (in *AccessKeyStatus) DeepCopy() *AccessKeyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessKeyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AssumeRolePolicy) DeepCopyInto(out *AssumeRolePolicy) {
 	*out = *in
@@ -196,13 +234,28 @@ func (in *ExternalResource) DeepCopy() *ExternalResource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Gate) DeepCopyInto(out *Gate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Gate.
+func (in *Gate) DeepCopy() *Gate {
+	if in == nil {
+		return nil
+	}
+	out := new(Gate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Group) DeepCopyInto(out *Group) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Group.
@@ -260,9 +313,19 @@ func (in *GroupSpec) DeepCopyInto(out *GroupSpec) {
 	*out = *in
 	if in.Users != nil {
 		in, out := &in.Users, &out.Users
-		*out = make([]v1.ObjectReference, len(*in))
+		*out = make([]corev1.ObjectReference, len(*in))
 		copy(*out, *in)
 	}
+	if in.ManagedPolicyArns != nil {
+		in, out := &in.ManagedPolicyArns, &out.ManagedPolicyArns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Gate != nil {
+		in, out := &in.Gate, &out.Gate
+		*out = new(Gate)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupSpec.
@@ -278,7 +341,12 @@ func (in *GroupSpec) DeepCopy() *GroupSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GroupStatus) DeepCopyInto(out *GroupStatus) {
 	*out = *in
-	out.AWSObjectStatus = in.AWSObjectStatus
+	in.AWSObjectStatus.DeepCopyInto(&out.AWSObjectStatus)
+	if in.ManagedPolicyArns != nil {
+		in, out := &in.ManagedPolicyArns, &out.ManagedPolicyArns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupStatus.
@@ -291,13 +359,67 @@ func (in *GroupStatus) DeepCopy() *GroupStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InlinePolicy) DeepCopyInto(out *InlinePolicy) {
+	*out = *in
+	if in.Statement != nil {
+		in, out := &in.Statement, &out.Statement
+		*out = make(PolicyStatement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InlinePolicy.
+func (in *InlinePolicy) DeepCopy() *InlinePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(InlinePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedPolicyReference) DeepCopyInto(out *ManagedPolicyReference) {
+	*out = *in
+	out.PolicyReference = in.PolicyReference
+	out.ExternalPolicy = in.ExternalPolicy
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedPolicyReference.
+func (in *ManagedPolicyReference) DeepCopy() *ManagedPolicyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedPolicyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PinnedPolicyVersion) DeepCopyInto(out *PinnedPolicyVersion) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinnedPolicyVersion.
+func (in *PinnedPolicyVersion) DeepCopy() *PinnedPolicyVersion {
+	if in == nil {
+		return nil
+	}
+	out := new(PinnedPolicyVersion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Policy) DeepCopyInto(out *Policy) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policy.
@@ -323,8 +445,8 @@ func (in *PolicyAttachment) DeepCopyInto(out *PolicyAttachment) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyAttachment.
@@ -377,12 +499,160 @@ func (in *PolicyAttachmentList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyAttachmentSet) DeepCopyInto(out *PolicyAttachmentSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyAttachmentSet.
+func (in *PolicyAttachmentSet) DeepCopy() *PolicyAttachmentSet {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyAttachmentSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PolicyAttachmentSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyAttachmentSetEntry) DeepCopyInto(out *PolicyAttachmentSetEntry) {
+	*out = *in
+	out.PolicyReference = in.PolicyReference
+	out.ExternalPolicy = in.ExternalPolicy
+	out.TargetReference = in.TargetReference
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyAttachmentSetEntry.
+func (in *PolicyAttachmentSetEntry) DeepCopy() *PolicyAttachmentSetEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyAttachmentSetEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyAttachmentSetEntryStatus) DeepCopyInto(out *PolicyAttachmentSetEntryStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyAttachmentSetEntryStatus.
+func (in *PolicyAttachmentSetEntryStatus) DeepCopy() *PolicyAttachmentSetEntryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyAttachmentSetEntryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyAttachmentSetList) DeepCopyInto(out *PolicyAttachmentSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PolicyAttachmentSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyAttachmentSetList.
+func (in *PolicyAttachmentSetList) DeepCopy() *PolicyAttachmentSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyAttachmentSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PolicyAttachmentSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyAttachmentSetSpec) DeepCopyInto(out *PolicyAttachmentSetSpec) {
+	*out = *in
+	if in.Attachments != nil {
+		in, out := &in.Attachments, &out.Attachments
+		*out = make([]PolicyAttachmentSetEntry, len(*in))
+		copy(*out, *in)
+	}
+	if in.Gate != nil {
+		in, out := &in.Gate, &out.Gate
+		*out = new(Gate)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyAttachmentSetSpec.
+func (in *PolicyAttachmentSetSpec) DeepCopy() *PolicyAttachmentSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyAttachmentSetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyAttachmentSetStatus) DeepCopyInto(out *PolicyAttachmentSetStatus) {
+	*out = *in
+	in.AWSObjectStatus.DeepCopyInto(&out.AWSObjectStatus)
+	if in.Attachments != nil {
+		in, out := &in.Attachments, &out.Attachments
+		*out = make([]PolicyAttachmentSetEntryStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyAttachmentSetStatus.
+func (in *PolicyAttachmentSetStatus) DeepCopy() *PolicyAttachmentSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyAttachmentSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PolicyAttachmentSpec) DeepCopyInto(out *PolicyAttachmentSpec) {
 	*out = *in
 	out.PolicyReference = in.PolicyReference
 	out.ExternalPolicy = in.ExternalPolicy
 	out.TargetReference = in.TargetReference
+	if in.Environments != nil {
+		in, out := &in.Environments, &out.Environments
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Gate != nil {
+		in, out := &in.Gate, &out.Gate
+		*out = new(Gate)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyAttachmentSpec.
@@ -437,6 +707,16 @@ func (in *PolicySpec) DeepCopyInto(out *PolicySpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ARNReferences != nil {
+		in, out := &in.ARNReferences, &out.ARNReferences
+		*out = make([]ARNReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Gate != nil {
+		in, out := &in.Gate, &out.Gate
+		*out = new(Gate)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicySpec.
@@ -564,6 +844,32 @@ func (in *PolicyStatementEntry) DeepCopy() *PolicyStatementEntry {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyStatus) DeepCopyInto(out *PolicyStatus) {
+	*out = *in
+	in.AWSObjectStatus.DeepCopyInto(&out.AWSObjectStatus)
+	if in.ValidationFindings != nil {
+		in, out := &in.ValidationFindings, &out.ValidationFindings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UnusedServices != nil {
+		in, out := &in.UnusedServices, &out.UnusedServices
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyStatus.
+func (in *PolicyStatus) DeepCopy() *PolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceReference) DeepCopyInto(out *ResourceReference) {
 	*out = *in
@@ -585,7 +891,7 @@ func (in *Role) DeepCopyInto(out *Role) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Role.
@@ -654,6 +960,50 @@ func (in *RoleSpec) DeepCopyInto(out *RoleSpec) {
 		*out = new(int64)
 		**out = **in
 	}
+	if in.InlinePolicies != nil {
+		in, out := &in.InlinePolicies, &out.InlinePolicies
+		*out = make([]InlinePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PolicySelector != nil {
+		in, out := &in.PolicySelector, &out.PolicySelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TrustConditions != nil {
+		in, out := &in.TrustConditions, &out.TrustConditions
+		*out = make(PolicyStatementCondition, len(*in))
+		for key, val := range *in {
+			var outVal map[PolicyStatementConditionKey]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(PolicyStatementConditionComparison, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.TTLAfterLastSync != nil {
+		in, out := &in.TTLAfterLastSync, &out.TTLAfterLastSync
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.SessionPolicies != nil {
+		in, out := &in.SessionPolicies, &out.SessionPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Gate != nil {
+		in, out := &in.Gate, &out.Gate
+		*out = new(Gate)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoleSpec.
@@ -669,7 +1019,32 @@ func (in *RoleSpec) DeepCopy() *RoleSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RoleStatus) DeepCopyInto(out *RoleStatus) {
 	*out = *in
-	out.AWSObjectStatus = in.AWSObjectStatus
+	in.AWSObjectStatus.DeepCopyInto(&out.AWSObjectStatus)
+	if in.InlinePolicies != nil {
+		in, out := &in.InlinePolicies, &out.InlinePolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SelectedPolicies != nil {
+		in, out := &in.SelectedPolicies, &out.SelectedPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AttachedPolicies != nil {
+		in, out := &in.AttachedPolicies, &out.AttachedPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PolicyVersions != nil {
+		in, out := &in.PolicyVersions, &out.PolicyVersions
+		*out = make([]PinnedPolicyVersion, len(*in))
+		copy(*out, *in)
+	}
+	if in.SessionPolicies != nil {
+		in, out := &in.SessionPolicies, &out.SessionPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoleStatus.
@@ -682,6 +1057,22 @@ func (in *RoleStatus) DeepCopy() *RoleStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceSpecificCredentialStatus) DeepCopyInto(out *ServiceSpecificCredentialStatus) {
+	*out = *in
+	out.Secret = in.Secret
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceSpecificCredentialStatus.
+func (in *ServiceSpecificCredentialStatus) DeepCopy() *ServiceSpecificCredentialStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceSpecificCredentialStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TargetReference) DeepCopyInto(out *TargetReference) {
 	*out = *in
@@ -702,8 +1093,8 @@ func (in *User) DeepCopyInto(out *User) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new User.
@@ -759,6 +1150,45 @@ func (in *UserList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UserSpec) DeepCopyInto(out *UserSpec) {
 	*out = *in
+	if in.LoginProfileTTL != nil {
+		in, out := &in.LoginProfileTTL, &out.LoginProfileTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ServiceSpecificCredentials != nil {
+		in, out := &in.ServiceSpecificCredentials, &out.ServiceSpecificCredentials
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InlinePolicies != nil {
+		in, out := &in.InlinePolicies, &out.InlinePolicies
+		*out = make([]InlinePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ManagedPolicyArns != nil {
+		in, out := &in.ManagedPolicyArns, &out.ManagedPolicyArns
+		*out = make([]ManagedPolicyReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]corev1.ObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Gate != nil {
+		in, out := &in.Gate, &out.Gate
+		*out = new(Gate)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserSpec.
@@ -774,9 +1204,39 @@ func (in *UserSpec) DeepCopy() *UserSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UserStatus) DeepCopyInto(out *UserStatus) {
 	*out = *in
-	out.AWSObjectStatus = in.AWSObjectStatus
+	in.AWSObjectStatus.DeepCopyInto(&out.AWSObjectStatus)
 	out.LoginProfileSecret = in.LoginProfileSecret
 	out.ProgrammaticAccessSecret = in.ProgrammaticAccessSecret
+	if in.ServiceSpecificCredentials != nil {
+		in, out := &in.ServiceSpecificCredentials, &out.ServiceSpecificCredentials
+		*out = make([]ServiceSpecificCredentialStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.InlinePolicies != nil {
+		in, out := &in.InlinePolicies, &out.InlinePolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ManagedPolicies != nil {
+		in, out := &in.ManagedPolicies, &out.ManagedPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AccessKeys != nil {
+		in, out := &in.AccessKeys, &out.AccessKeys
+		*out = make([]AccessKeyStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserStatus.
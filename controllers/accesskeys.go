@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// listAccessKeyStatus returns the IDs and states of all access keys of the user, without touching them
+func listAccessKeyStatus(svc iamiface.IAMAPI, userName string) ([]iamv1beta1.AccessKeyStatus, error) {
+	var keys []iamv1beta1.AccessKeyStatus
+	err := svc.ListAccessKeysPages(&awsiam.ListAccessKeysInput{
+		UserName: awssdk.String(userName),
+	}, func(page *awsiam.ListAccessKeysOutput, lastPage bool) bool {
+		for _, key := range page.AccessKeyMetadata {
+			keys = append(keys, iamv1beta1.AccessKeyStatus{
+				AccessKeyID: awssdk.StringValue(key.AccessKeyId),
+				Status:      awssdk.StringValue(key.Status),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
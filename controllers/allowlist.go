@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ErrCodeOperationNotAllowed is the error code returned for every AWS call that is not on the allow-list
+const ErrCodeOperationNotAllowed = "OperationNotAllowed"
+
+// allowedOperations holds the AWS operations (e.g. "iam:CreateRole") the operator may call; nil allows all of them
+var allowedOperations map[string]bool
+
+// AllowOperations restricts the AWS operations the operator may call to the given ones, named like IAM actions (e.g.
+// "iam:CreateRole"). It has to be called before the controllers start; an empty list lifts the restriction.
+func AllowOperations(operations []string) {
+	if len(operations) == 0 {
+		allowedOperations = nil
+		return
+	}
+	allowedOperations = make(map[string]bool)
+	for _, operation := range operations {
+		allowedOperations[strings.TrimSpace(operation)] = true
+	}
+}
+
+// restrictOperations makes the client refuse every operation not on the allow-list, no matter which code path tries
+// to call it. This limits what the operator can do, even if its own IAM role is broader. The prefix names the service
+// for the allow-list (e.g. "iam").
+func restrictOperations(handlers *request.Handlers, prefix string) {
+	if allowedOperations == nil {
+		return
+	}
+	log := ctrl.Log.WithName("allow-list")
+	handlers.Validate.PushFront(func(r *request.Request) {
+		operation := prefix + ":" + r.Operation.Name
+		if !allowedOperations[operation] {
+			log.Info(fmt.Sprintf("blocked call to '%s', as it is not on the allow-list", operation))
+			r.Error = awserr.New(ErrCodeOperationNotAllowed, fmt.Sprintf("operation '%s' is not on the allow-list of the operator", operation), nil)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// attachmentHolder returns who else attaches the policy to the target, or "" if nobody does. AWS attaches a policy
+// only once, so a pair must stay attached while PolicyAttachments, PolicyAttachmentSets or the managed policies of a
+// User or Group still hold it. The detaching object itself doesn't count; neither does anything being deleted, or an
+// attachment of a Policy being deleted, as that one is released everywhere.
+func attachmentHolder(ctx context.Context, c client.Client, targetType iamv1beta1.TargetType, policyArn, targetArn string, detaching client.Object) (string, error) {
+	same := func(o client.Object) bool {
+		return reflect.TypeOf(o) == reflect.TypeOf(detaching) && client.ObjectKeyFromObject(o) == client.ObjectKeyFromObject(detaching)
+	}
+
+	attachments := iamv1beta1.PolicyAttachmentList{}
+	if err := c.List(ctx, &attachments); err != nil {
+		return "", err
+	}
+	for i := range attachments.Items {
+		att := &attachments.Items[i]
+		if same(att) || !att.ObjectMeta.DeletionTimestamp.IsZero() || att.Spec.TargetReference.Type != targetType || att.Status.ARN != targetArn {
+			continue
+		}
+		attached := att.Spec.ExternalPolicy.ARN
+		if attached == "" {
+			policy := iamv1beta1.Policy{}
+			ref := att.Spec.PolicyReference
+			if err := c.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, &policy); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return "", err
+			}
+			if !policy.ObjectMeta.DeletionTimestamp.IsZero() {
+				continue
+			}
+			attached = policy.Status.ARN
+		}
+		if attached == policyArn {
+			return fmt.Sprintf("PolicyAttachment '%s/%s'", att.Namespace, att.Name), nil
+		}
+	}
+
+	sets := iamv1beta1.PolicyAttachmentSetList{}
+	if err := c.List(ctx, &sets); err != nil {
+		return "", err
+	}
+	for i := range sets.Items {
+		set := &sets.Items[i]
+		if same(set) || !set.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for _, attached := range set.Status.Attachments {
+			if attached.TargetType == targetType && attached.PolicyARN == policyArn && attached.TargetARN == targetArn && attached.State == iamv1beta1.OkSyncState {
+				return fmt.Sprintf("PolicyAttachmentSet '%s/%s'", set.Namespace, set.Name), nil
+			}
+		}
+	}
+
+	if targetType == iamv1beta1.GroupTargetType {
+		groups := iamv1beta1.GroupList{}
+		if err := c.List(ctx, &groups); err != nil {
+			return "", err
+		}
+		for i := range groups.Items {
+			group := &groups.Items[i]
+			if !same(group) && group.ObjectMeta.DeletionTimestamp.IsZero() && group.Status.ARN == targetArn && containsString(group.Status.ManagedPolicyArns, policyArn) {
+				return fmt.Sprintf("the managed policies of Group '%s/%s'", group.Namespace, group.Name), nil
+			}
+		}
+		return "", nil
+	}
+	if targetType != iamv1beta1.UserTargetType {
+		return "", nil
+	}
+	users := iamv1beta1.UserList{}
+	if err := c.List(ctx, &users); err != nil {
+		return "", err
+	}
+	for _, user := range users.Items {
+		if user.ObjectMeta.DeletionTimestamp.IsZero() && user.Status.ARN == targetArn && containsString(user.Status.ManagedPolicies, policyArn) {
+			return fmt.Sprintf("the managed policies of User '%s/%s'", user.Namespace, user.Name), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/redradrat/cloud-objects/aws"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// annotation confirming that a previously applied permissions boundary may be removed
+const allowBoundaryRemovalAnnotation = "aws-iam.redradrat.xyz/allow-boundary-removal"
+
+// checkBoundaryRemoval refuses to remove a previously applied permissions boundary, unless the removal has been
+// confirmed via annotation. Removing a boundary instantly broadens the effective permissions of the Role or User.
+func checkBoundaryRemoval(obj AWSObjectStatusResource, boundary string) error {
+	if boundary != "" {
+		return nil
+	}
+	if meta.FindStatusCondition(obj.GetStatus().Conditions, iamv1beta1.BoundaryAppliedCondition) == nil {
+		return nil
+	}
+	if obj.RuntimeObject().GetAnnotations()[allowBoundaryRemovalAnnotation] == "true" {
+		return nil
+	}
+	return fmt.Errorf("refusing to remove the permissions boundary, as this broadens the effective permissions; set the annotation '%s: \"true\"' to confirm", allowBoundaryRemovalAnnotation)
+}
+
+// reconcilePermissionsBoundary makes sure the given boundary is set for the named Role or User in AWS, and removes
+// a previously applied boundary if none is given anymore. The outcome is reflected in the BoundaryApplied condition.
+func reconcilePermissionsBoundary(svc iamiface.IAMAPI, obj AWSObjectStatusResource, targetType iamv1beta1.TargetType, name, boundary string) error {
+	status := obj.GetStatus()
+	generation := obj.RuntimeObject().GetGeneration()
+
+	if boundary == "" {
+		// we only need to remove something, if we have applied a boundary before
+		if meta.FindStatusCondition(status.Conditions, iamv1beta1.BoundaryAppliedCondition) == nil {
+			return nil
+		}
+		if err := deletePermissionsBoundary(svc, targetType, name); err != nil {
+			setBoundaryCondition(status, generation, metav1.ConditionFalse, "RemovalFailed", err.Error())
+			return err
+		}
+		meta.RemoveStatusCondition(&status.Conditions, iamv1beta1.BoundaryAppliedCondition)
+		return nil
+	}
+
+	if !awsarn.IsARN(boundary) {
+		err := fmt.Errorf("given permissions boundary '%s' is not a valid ARN", boundary)
+		setBoundaryCondition(status, generation, metav1.ConditionFalse, "InvalidBoundary", err.Error())
+		return err
+	}
+	if err := putPermissionsBoundary(svc, targetType, name, boundary); err != nil {
+		setBoundaryCondition(status, generation, metav1.ConditionFalse, "ApplyFailed", err.Error())
+		return err
+	}
+	setBoundaryCondition(status, generation, metav1.ConditionTrue, "Applied", fmt.Sprintf("permissions boundary '%s' is applied", boundary))
+	return nil
+}
+
+// currentPermissionsBoundary returns the ARN of the boundary currently set in AWS for the named Role or User (empty
+// if there is none)
+func currentPermissionsBoundary(svc iamiface.IAMAPI, targetType iamv1beta1.TargetType, name string) (string, error) {
+	var boundary *awsiam.AttachedPermissionsBoundary
+	switch targetType {
+	case iamv1beta1.RoleTargetType:
+		out, err := svc.GetRole(&awsiam.GetRoleInput{RoleName: awssdk.String(name)})
+		if err != nil {
+			return "", err
+		}
+		boundary = out.Role.PermissionsBoundary
+	case iamv1beta1.UserTargetType:
+		out, err := svc.GetUser(&awsiam.GetUserInput{UserName: awssdk.String(name)})
+		if err != nil {
+			return "", err
+		}
+		boundary = out.User.PermissionsBoundary
+	default:
+		return "", fmt.Errorf("permissions boundaries are not supported for type '%s'", targetType)
+	}
+	if boundary == nil {
+		return "", nil
+	}
+	return awssdk.StringValue(boundary.PermissionsBoundaryArn), nil
+}
+
+func setBoundaryCondition(status *iamv1beta1.AWSObjectStatus, generation int64, conditionStatus metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               iamv1beta1.BoundaryAppliedCondition,
+		Status:             conditionStatus,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func putPermissionsBoundary(svc iamiface.IAMAPI, targetType iamv1beta1.TargetType, name, boundary string) error {
+	var err error
+	switch targetType {
+	case iamv1beta1.RoleTargetType:
+		_, err = svc.PutRolePermissionsBoundary(&awsiam.PutRolePermissionsBoundaryInput{
+			PermissionsBoundary: awssdk.String(boundary),
+			RoleName:            awssdk.String(name),
+		})
+	case iamv1beta1.UserTargetType:
+		_, err = svc.PutUserPermissionsBoundary(&awsiam.PutUserPermissionsBoundaryInput{
+			PermissionsBoundary: awssdk.String(boundary),
+			UserName:            awssdk.String(name),
+		})
+	default:
+		err = fmt.Errorf("permissions boundaries are not supported for type '%s'", targetType)
+	}
+	return err
+}
+
+func deletePermissionsBoundary(svc iamiface.IAMAPI, targetType iamv1beta1.TargetType, name string) error {
+	var err error
+	switch targetType {
+	case iamv1beta1.RoleTargetType:
+		_, err = svc.DeleteRolePermissionsBoundary(&awsiam.DeleteRolePermissionsBoundaryInput{
+			RoleName: awssdk.String(name),
+		})
+	case iamv1beta1.UserTargetType:
+		_, err = svc.DeleteUserPermissionsBoundary(&awsiam.DeleteUserPermissionsBoundaryInput{
+			UserName: awssdk.String(name),
+		})
+	default:
+		return fmt.Errorf("permissions boundaries are not supported for type '%s'", targetType)
+	}
+	if err != nil && !aws.IsNotExistsError(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/redradrat/cloud-objects/aws/iam"
+)
+
+// canonicalPolicyDocument returns a copy of the document with the actions and resources of every statement sorted
+// and deduplicated. Their order has no meaning to IAM, so equivalent specs end up as the very same document in AWS.
+// Statements keep their order, but duplicate statements are dropped. The document is submitted as minified JSON with
+// sorted keys anyway.
+func canonicalPolicyDocument(doc iam.PolicyDocument) iam.PolicyDocument {
+	var statement []iam.StatementEntry
+	for _, entry := range doc.Statement {
+		entry.Action = sortedUnique(entry.Action)
+		entry.Resource = sortedUnique(entry.Resource)
+		statement = append(statement, entry)
+	}
+	doc.Statement = statement
+	return dedupeStatements(doc)
+}
+
+// dedupeStatements returns a copy of the document without the statements that are semantically identical to another
+// one, as they occur when documents are composed from several sources and only waste the size budget. Statements
+// with distinct SIDs are all kept, so they can still be told apart; an unnamed duplicate of a named statement goes.
+func dedupeStatements(doc iam.PolicyDocument) iam.PolicyDocument {
+	var statement []iam.StatementEntry
+	for _, entry := range doc.Statement {
+		duplicate := false
+		for i, existing := range statement {
+			if !sameStatement(existing, entry) {
+				continue
+			}
+			if entry.Sid != "" && existing.Sid != "" {
+				continue
+			}
+			if entry.Sid != "" {
+				// keep the position of the first one, but the name of the named one
+				statement[i] = entry
+			}
+			duplicate = true
+			break
+		}
+		if !duplicate {
+			statement = append(statement, entry)
+		}
+	}
+	doc.Statement = statement
+	return doc
+}
+
+// sameStatement tells whether the statements grant or deny the same, regardless of their SIDs and of the order of
+// their actions and resources
+func sameStatement(a, b iam.StatementEntry) bool {
+	return a.Effect == b.Effect &&
+		reflect.DeepEqual(a.Principal, b.Principal) &&
+		reflect.DeepEqual(a.Condition, b.Condition) &&
+		reflect.DeepEqual(sortedUnique(a.Action), sortedUnique(b.Action)) &&
+		reflect.DeepEqual(sortedUnique(a.Resource), sortedUnique(b.Resource))
+}
+
+// sortedUnique returns a sorted copy of the given strings without duplicates
+func sortedUnique(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	var out []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			out = append(out, value)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// storedPolicyDocumentMatches tells whether the default version of the policy in AWS is semantically equal to the
+// document, so that applying it would only create a spurious policy version
+func storedPolicyDocumentMatches(svc iamiface.IAMAPI, policyArn string, doc iam.PolicyDocument) (bool, error) {
+	policyOut, err := svc.GetPolicy(&awsiam.GetPolicyInput{PolicyArn: awssdk.String(policyArn)})
+	if err != nil {
+		return false, err
+	}
+	versionOut, err := svc.GetPolicyVersion(&awsiam.GetPolicyVersionInput{
+		PolicyArn: policyOut.Policy.Arn,
+		VersionId: policyOut.Policy.DefaultVersionId,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// AWS returns the document URL-encoded
+	stored, err := url.QueryUnescape(awssdk.StringValue(versionOut.PolicyVersion.Document))
+	if err != nil {
+		return false, err
+	}
+	storedDoc, err := normalizePolicyJSON([]byte(stored))
+	if err != nil {
+		return false, fmt.Errorf("unable to parse the stored policy document: %v", err)
+	}
+
+	b, err := json.Marshal(&doc)
+	if err != nil {
+		return false, err
+	}
+	desiredDoc, err := normalizePolicyJSON(b)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(storedDoc, desiredDoc), nil
+}
+
+// normalizePolicyJSON parses a policy document into a form, where equivalent documents are deeply equal: single
+// values are turned into lists, as IAM allows both, and lists whose order doesn't matter are sorted
+func normalizePolicyJSON(raw []byte) (interface{}, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	statements, ok := doc["Statement"].([]interface{})
+	if !ok && doc["Statement"] != nil {
+		statements = []interface{}{doc["Statement"]}
+	}
+	for _, s := range statements {
+		entry, ok := s.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("statement is not an object")
+		}
+		for _, key := range []string{"Action", "NotAction", "Resource", "NotResource"} {
+			if value, ok := entry[key]; ok {
+				entry[key] = normalizeJSONList(value)
+			}
+		}
+		if principal, ok := entry["Principal"].(map[string]interface{}); ok {
+			for key, value := range principal {
+				principal[key] = normalizeJSONList(value)
+			}
+		}
+	}
+	doc["Statement"] = statements
+	return doc, nil
+}
+
+// normalizeJSONList turns a single string or a list of strings into a sorted list without duplicates
+func normalizeJSONList(value interface{}) interface{} {
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				// not a list of strings; leave it to the comparison
+				return value
+			}
+			values = append(values, s)
+		}
+	default:
+		return value
+	}
+	return sortedUnique(values)
+}
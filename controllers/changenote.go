@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// annotation for a note describing why the Policy has been changed. AWS policy versions can't carry a description,
+// so the note is recorded in the status, together with the version created for it.
+const changeNoteAnnotation = "aws-iam.redradrat.xyz/change-note"
+
+// recordPolicyChange records the applied generation and change note of the Policy, and the policy version they have
+// been applied as, in the status. The status is written by the caller.
+func recordPolicyChange(svc iamiface.IAMAPI, policy *iamv1beta1.Policy, generation int64) error {
+	policy.Status.ChangeGeneration = generation
+	policy.Status.ChangeNote = policy.ObjectMeta.Annotations[changeNoteAnnotation]
+	policy.Status.PolicyVersion = ""
+
+	out, err := svc.GetPolicy(&awsiam.GetPolicyInput{PolicyArn: awssdk.String(policy.Status.ARN)})
+	if err != nil {
+		return err
+	}
+	policy.Status.PolicyVersion = awssdk.StringValue(out.Policy.DefaultVersionId)
+	return nil
+}
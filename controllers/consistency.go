@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+const (
+	// how long after its creation a target may still be invisible to IAM, before NoSuchEntity is taken for real
+	attachConsistencyWindow = 2 * time.Minute
+	// how long to wait before retrying an attachment to a target that is not yet visible
+	attachConsistencyRetryInterval = 5 * time.Second
+)
+
+// awaitsConsistency tells whether the attachment failed only because IAM doesn't know the just created target yet.
+// IAM is eventually consistent; right after creating a role, attaching a policy to it can fail with NoSuchEntity.
+func awaitsConsistency(ctx context.Context, c client.Client, policyAttachment *iamv1beta1.PolicyAttachment, err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok || aerr.Code() != awsiam.ErrCodeNoSuchEntityException {
+		return false
+	}
+
+	var target client.Object
+	switch policyAttachment.Spec.TargetReference.Type {
+	case iamv1beta1.RoleTargetType:
+		target = &iamv1beta1.Role{}
+	case iamv1beta1.UserTargetType:
+		target = &iamv1beta1.User{}
+	case iamv1beta1.GroupTargetType:
+		target = &iamv1beta1.Group{}
+	default:
+		return false
+	}
+	key := client.ObjectKey{Name: policyAttachment.Spec.TargetReference.Name, Namespace: policyAttachment.Spec.TargetReference.Namespace}
+	if err := c.Get(ctx, key, target); err != nil {
+		return false
+	}
+	return time.Since(target.GetCreationTimestamp().Time) < attachConsistencyWindow
+}
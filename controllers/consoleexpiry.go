@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"time"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// loginProfileExpiresIn returns how long the console access of the User is still valid. Without a TTL, or if no grant
+// time is known yet, the access doesn't expire and 0 is returned.
+func loginProfileExpiresIn(user *iamv1beta1.User) time.Duration {
+	if user.Spec.LoginProfileTTL == nil || !user.Status.LoginProfileCreated {
+		return 0
+	}
+	granted, err := time.Parse(time.RFC3339, user.Status.LoginProfileGrantedAt)
+	if err != nil {
+		return 0
+	}
+	remaining := time.Until(granted.Add(user.Spec.LoginProfileTTL.Duration))
+	if remaining <= 0 {
+		// expired; callers check this via loginProfileExpired
+		return 0
+	}
+	return remaining
+}
+
+// loginProfileExpired tells whether the User still has console access that has outlived its TTL
+func loginProfileExpired(user *iamv1beta1.User) bool {
+	if user.Spec.LoginProfileTTL == nil || !user.Status.LoginProfileCreated {
+		return false
+	}
+	granted, err := time.Parse(time.RFC3339, user.Status.LoginProfileGrantedAt)
+	if err != nil {
+		return false
+	}
+	return time.Since(granted) > user.Spec.LoginProfileTTL.Duration
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get
+
+// ManagedCRDs lists the names of all CRDs the controllers of this operator depend on
+var ManagedCRDs = []string{
+	"roles." + iamv1beta1.GroupVersion.Group,
+	"assumerolepolicies." + iamv1beta1.GroupVersion.Group,
+	"policies." + iamv1beta1.GroupVersion.Group,
+	"policyattachments." + iamv1beta1.GroupVersion.Group,
+	"policyattachmentsets." + iamv1beta1.GroupVersion.Group,
+	"users." + iamv1beta1.GroupVersion.Group,
+	"groups." + iamv1beta1.GroupVersion.Group,
+}
+
+// WaitForCRDsEstablished blocks until all named CRDs are established, so the controllers don't start against
+// resources the API server doesn't serve yet (e.g. on a fresh install). It gives up after the timeout.
+func WaitForCRDsEstablished(ctx context.Context, reader client.Reader, names []string, interval, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return wait.PollImmediateUntil(interval, func() (bool, error) {
+		for _, name := range names {
+			var crd apiextensionsv1.CustomResourceDefinition
+			if err := reader.Get(ctx, client.ObjectKey{Name: name}, &crd); err != nil {
+				return false, client.IgnoreNotFound(err)
+			}
+			if !crdEstablished(crd) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, ctx.Done())
+}
+
+func crdEstablished(crd apiextensionsv1.CustomResourceDefinition) bool {
+	for _, cond := range crd.Status.Conditions {
+		if cond.Type == apiextensionsv1.Established {
+			return cond.Status == apiextensionsv1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+var _ = Describe("WaitForCRDsEstablished", func() {
+	It("doesn't return before the CRDs are established", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
+		crd := &apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "roles." + iamv1beta1.GroupVersion.Group}}
+		c := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(crd).Build()
+		names := []string{crd.Name, "users." + iamv1beta1.GroupVersion.Group}
+
+		Expect(WaitForCRDsEstablished(ctx, c, names, 10*time.Millisecond, 50*time.Millisecond)).NotTo(Succeed())
+
+		done := make(chan error, 1)
+		go func() {
+			done <- WaitForCRDsEstablished(ctx, c, names, 10*time.Millisecond, 5*time.Second)
+		}()
+		Consistently(done, 100*time.Millisecond).ShouldNot(Receive())
+
+		established := apiextensionsv1.CustomResourceDefinitionCondition{Type: apiextensionsv1.Established, Status: apiextensionsv1.ConditionTrue}
+		crd.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{established}
+		Expect(c.Update(ctx, crd)).To(Succeed())
+		Consistently(done, 100*time.Millisecond).ShouldNot(Receive())
+
+		users := &apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: names[1]}}
+		users.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{established}
+		Expect(c.Create(ctx, users)).To(Succeed())
+		Eventually(done).Should(Receive(BeNil()))
+	})
+})
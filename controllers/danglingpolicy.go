@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/redradrat/cloud-objects/aws/iam"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// releaseDeletedPolicy detaches the referenced Policy, if it is being deleted (or already gone). A Policy can only be
+// deleted once it is detached everywhere, so an attachment holding on to it would block its deletion forever.
+// It returns whether the referenced Policy is deleted, in which case there is nothing left to reconcile.
+// A missing Policy only counts as deleted, if it has been attached or released before; otherwise it might just not
+// have been created yet.
+func (r *PolicyAttachmentReconciler) releaseDeletedPolicy(ctx context.Context, policyAttachment *iamv1beta1.PolicyAttachment, sw client.StatusWriter) (bool, error) {
+	ref := policyAttachment.Spec.PolicyReference
+	released := fmt.Sprintf("referenced Policy '%s/%s' is deleted; nothing is attached", ref.Namespace, ref.Name)
+
+	var policy iamv1beta1.Policy
+	err := r.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, &policy)
+	if client.IgnoreNotFound(err) != nil {
+		return false, err
+	}
+	if err == nil && policy.ObjectMeta.DeletionTimestamp.IsZero() {
+		return false, nil
+	}
+	seen := policyAttachment.Status.ARN != "" ||
+		(policyAttachment.Status.State == iamv1beta1.SkippedSyncState && policyAttachment.Status.Message == released)
+	if errors.IsNotFound(err) && !seen {
+		return false, nil
+	}
+
+	if policyAttachment.Status.ARN != "" {
+		// without the Policy we don't know what to detach; the Policy waits for us though, so this is rare
+		if errors.IsNotFound(err) || policy.Status.ARN == "" {
+			return true, errWithStatus(ctx, policyAttachment, fmt.Errorf("referenced Policy '%s/%s' is gone, but may still be attached to '%s'", ref.Namespace, ref.Name, policyAttachment.Status.ARN), sw)
+		}
+		if r.ReadOnly {
+			return true, skipWithStatus(ctx, policyAttachment, fmt.Sprintf("read-only mode: would detach deleted Policy '%s/%s' from '%s'", ref.Namespace, ref.Name, policyAttachment.Status.ARN), sw)
+		}
+
+		if !r.CreateOnly {
+			policyArn, err := awsarn.Parse(policy.Status.ARN)
+			if err != nil {
+				return true, errWithStatus(ctx, policyAttachment, err, sw)
+			}
+			targetArn, err := awsarn.Parse(policyAttachment.Status.ARN)
+			if err != nil {
+				return true, errWithStatus(ctx, policyAttachment, err, sw)
+			}
+			attachType, err := policyAttachment.GetAttachmentType()
+			if err != nil {
+				return true, errWithStatus(ctx, policyAttachment, err, sw)
+			}
+			holder, err := attachmentHolder(ctx, r.Client, policyAttachment.Spec.TargetReference.Type, policyArn.String(), targetArn.String(), policyAttachment)
+			if err != nil {
+				return true, errWithStatus(ctx, policyAttachment, err, sw)
+			}
+			if holder != "" {
+				// the Policy keeps waiting for the other holder
+				r.Log.WithValues("policyattachment", client.ObjectKeyFromObject(policyAttachment)).Info(fmt.Sprintf("leaving deleted Policy '%s/%s' attached to '%s', as %s attaches it as well", ref.Namespace, ref.Name, policyAttachment.Status.ARN, holder))
+			} else {
+				iamsvc, err := IAMService(r.Region, r.ReadOnly)
+				if err != nil {
+					return true, errWithStatus(ctx, policyAttachment, err, sw)
+				}
+				if _, err := DeleteAWSObject(iamsvc, iam.NewPolicyAttachmentInstance(policyArn, attachType, targetArn), DoNothingPreFunc); err != nil {
+					return true, errWithStatus(ctx, policyAttachment, err, sw)
+				}
+				r.Notifier.Notify(policyAttachment, v1.EventTypeNormal, "Detached", fmt.Sprintf("Detached deleted Policy '%s/%s' from '%s'", ref.Namespace, ref.Name, policyAttachment.Status.ARN))
+			}
+		}
+
+		// nothing is attached anymore, so there is nothing to clean up on deletion either; the deletion would not even
+		// find the Policy to detach anymore
+		policyAttachment.ObjectMeta.Finalizers = removeString(policyAttachment.ObjectMeta.Finalizers, policyAttachmentFinalizer)
+		if err := r.Update(ctx, policyAttachment); err != nil {
+			return true, err
+		}
+		policyAttachment.Status.ARN = ""
+	}
+
+	return true, skipWithStatus(ctx, policyAttachment, released, sw)
+}
+
+// policyAttachmentsForPolicy maps a Policy to the PolicyAttachments referencing it
+func (r *PolicyAttachmentReconciler) policyAttachmentsForPolicy(o client.Object) []reconcile.Request {
+	policy := o.(*iamv1beta1.Policy)
+
+	attachments := iamv1beta1.PolicyAttachmentList{}
+	if err := r.List(context.Background(), &attachments); err != nil {
+		r.Log.Error(err, "unable to list PolicyAttachments for Policy", "policy", policy.Name)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, att := range attachments.Items {
+		if att.Spec.PolicyReference.Name == policy.Name && att.Spec.PolicyReference.Namespace == policy.Namespace {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: att.Name, Namespace: att.Namespace}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Debouncer delays the reconciliation of changed resources until their spec has settled for a given window. This
+// collapses rapid consecutive edits (e.g. by another controller) into a single reconciliation. A change after a quiet
+// window is reconciled right away; only the changes following it within the window are held back.
+// A nil Debouncer or a zero window disables debouncing.
+type Debouncer struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[types.NamespacedName]debounceEntry
+}
+
+type debounceEntry struct {
+	generation int64
+	since      time.Time
+	released   bool
+}
+
+func NewDebouncer(window time.Duration) *Debouncer {
+	return &Debouncer{window: window, seen: make(map[types.NamespacedName]debounceEntry)}
+}
+
+// Wait returns how long the resource with the given generation still has to wait, before it may be reconciled.
+// Every generation following the last one within the window restarts the window.
+func (d *Debouncer) Wait(key types.NamespacedName, generation int64) time.Duration {
+	if d == nil || d.window <= 0 {
+		return 0
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := d.seen[key]
+	if !ok || entry.generation != generation {
+		quiet := !ok || (entry.released && now.Sub(entry.since) >= d.window)
+		d.seen[key] = debounceEntry{generation: generation, since: now, released: quiet}
+		if quiet {
+			return 0
+		}
+		return d.window
+	}
+	if entry.released {
+		return 0
+	}
+	if elapsed := now.Sub(entry.since); elapsed < d.window {
+		return d.window - elapsed
+	}
+	entry.released = true
+	d.seen[key] = entry
+	return 0
+}
+
+// Forget drops everything known about the given resource
+func (d *Debouncer) Forget(key types.NamespacedName) {
+	if d == nil || d.window <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.seen, key)
+}
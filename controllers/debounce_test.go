@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Debouncer", func() {
+	const window = 200 * time.Millisecond
+	var (
+		debouncer *Debouncer
+		key       types.NamespacedName
+	)
+
+	BeforeEach(func() {
+		debouncer = NewDebouncer(window)
+		key = types.NamespacedName{Namespace: "default", Name: uniqueName("policy")}
+	})
+
+	// reconciled calls Wait like the controllers do, and tells whether the resource got reconciled
+	reconciled := func(generation int64) bool {
+		return debouncer.Wait(key, generation) == 0
+	}
+
+	It("lets the first change through right away", func() {
+		Expect(reconciled(1)).To(BeTrue())
+		// requeues of the same generation aren't held back either
+		Expect(reconciled(1)).To(BeTrue())
+	})
+
+	It("collapses rapid consecutive changes into a single reconcile", func() {
+		Expect(reconciled(1)).To(BeTrue())
+
+		reconciles := 0
+		for generation := int64(2); generation <= 6; generation++ {
+			if reconciled(generation) {
+				reconciles++
+			}
+			time.Sleep(window / 10)
+		}
+		Expect(reconciles).To(BeZero())
+
+		time.Sleep(window)
+		Expect(reconciled(6)).To(BeTrue())
+		Expect(reconciled(6)).To(BeTrue())
+
+		// once settled, the next change is let through right away again
+		time.Sleep(window)
+		Expect(reconciled(7)).To(BeTrue())
+	})
+})
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/redradrat/cloud-objects/aws"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// checkDeletionProtection refuses the deletion of a role or user, that carries the given protection tag in AWS. The
+// tag is checked in AWS rather than on the resource, so critical entities stay protected no matter what happens to
+// the resource, until someone removes the tag in AWS. An empty tag key disables the protection.
+func checkDeletionProtection(svc iamiface.IAMAPI, targetType iamv1beta1.TargetType, name string, tagKey string) error {
+	if tagKey == "" {
+		return nil
+	}
+
+	var tags []*awsiam.Tag
+	var err error
+	switch targetType {
+	case iamv1beta1.RoleTargetType:
+		var out *awsiam.ListRoleTagsOutput
+		if out, err = svc.ListRoleTags(&awsiam.ListRoleTagsInput{RoleName: awssdk.String(name)}); err == nil {
+			tags = out.Tags
+		}
+	case iamv1beta1.UserTargetType:
+		var out *awsiam.ListUserTagsOutput
+		if out, err = svc.ListUserTags(&awsiam.ListUserTagsInput{UserName: awssdk.String(name)}); err == nil {
+			tags = out.Tags
+		}
+	default:
+		return fmt.Errorf("deletion protection is not supported for target type '%s'", targetType)
+	}
+	if err != nil {
+		// there is nothing left to protect
+		if aws.IsNotExistsError(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, tag := range tags {
+		if awssdk.StringValue(tag.Key) == tagKey {
+			return fmt.Errorf("%s '%s' is protected from deletion by its tag '%s'; remove the tag in AWS to let it be deleted", targetType, name, tagKey)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// checkManagedEntityCap refuses the creation of another AWS entity, if the operator already manages the given
+// maximum of Roles, Policies, Users and Groups. This limits the blast radius of a runaway misconfiguration (e.g. a
+// templating bug creating thousands of resources). A maximum <= 0 disables the cap.
+// Concurrent reconciles may overshoot the cap by the number of workers.
+func checkManagedEntityCap(ctx context.Context, c client.Client, max int) error {
+	if max <= 0 {
+		return nil
+	}
+
+	count := 0
+	roles := iamv1beta1.RoleList{}
+	if err := c.List(ctx, &roles); err != nil {
+		return err
+	}
+	for _, role := range roles.Items {
+		if role.Status.ARN != "" {
+			count++
+		}
+	}
+	policies := iamv1beta1.PolicyList{}
+	if err := c.List(ctx, &policies); err != nil {
+		return err
+	}
+	for _, policy := range policies.Items {
+		if policy.Status.ARN != "" {
+			count++
+		}
+	}
+	users := iamv1beta1.UserList{}
+	if err := c.List(ctx, &users); err != nil {
+		return err
+	}
+	for _, user := range users.Items {
+		if user.Status.ARN != "" {
+			count++
+		}
+	}
+	groups := iamv1beta1.GroupList{}
+	if err := c.List(ctx, &groups); err != nil {
+		return err
+	}
+	for _, group := range groups.Items {
+		if group.Status.ARN != "" {
+			count++
+		}
+	}
+
+	if count >= max {
+		return fmt.Errorf("refusing to create another AWS entity; the operator already manages %d of at most %d", count, max)
+	}
+	return nil
+}
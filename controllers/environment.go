@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/redradrat/cloud-objects/aws/iam"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// namespace annotation holding the environment (e.g. "staging") resources in the namespace belong to
+const environmentAnnotation = "aws-iam.redradrat.xyz/environment"
+
+// namespaceEnvironment returns the environment of the given namespace, or an empty string if it has none
+func namespaceEnvironment(ctx context.Context, c client.Client, namespace string) (string, error) {
+	var ns v1.Namespace
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, &ns); err != nil {
+		return "", err
+	}
+	return ns.Annotations[environmentAnnotation], nil
+}
+
+// detachFromOtherEnvironment detaches the policy of an attachment made while the environment of its namespace still
+// matched, unless someone else attaches it as well
+func (r *PolicyAttachmentReconciler) detachFromOtherEnvironment(ctx context.Context, policyAttachment *iamv1beta1.PolicyAttachment, environment string) error {
+	policyArn, err := getPolicyAttachmentPolicyARN(ctx, policyAttachment, r.Client)
+	if err != nil {
+		return err
+	}
+	targetArn, err := awsarn.Parse(policyAttachment.Status.ARN)
+	if err != nil {
+		return err
+	}
+	attachType, err := policyAttachment.GetAttachmentType()
+	if err != nil {
+		return err
+	}
+
+	holder, err := attachmentHolder(ctx, r.Client, policyAttachment.Spec.TargetReference.Type, policyArn.String(), targetArn.String(), policyAttachment)
+	if err != nil {
+		return err
+	}
+	if holder == "" {
+		iamsvc, err := IAMService(r.Region, r.ReadOnly)
+		if err != nil {
+			return err
+		}
+		if _, err := DeleteAWSObject(iamsvc, iam.NewPolicyAttachmentInstance(policyArn, attachType, targetArn), DoNothingPreFunc); err != nil {
+			return err
+		}
+		r.Notifier.Notify(policyAttachment, v1.EventTypeNormal, "Detached", fmt.Sprintf("Detached policy '%s' from '%s' in environment '%s'", policyArn.String(), targetArn.String(), environment))
+	}
+	policyAttachment.Status.ARN = ""
+	return nil
+}
+
+// policyAttachmentsForNamespace maps a Namespace to the PolicyAttachments in it, that are restricted to environments
+func (r *PolicyAttachmentReconciler) policyAttachmentsForNamespace(o client.Object) []reconcile.Request {
+	attachments := iamv1beta1.PolicyAttachmentList{}
+	if err := r.List(context.Background(), &attachments, client.InNamespace(o.GetName())); err != nil {
+		r.Log.Error(err, "unable to list PolicyAttachments for Namespace", "namespace", o.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, att := range attachments.Items {
+		if len(att.Spec.Environments) != 0 {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: att.Name, Namespace: att.Namespace}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redradrat/cloud-objects/aws"
+	"github.com/redradrat/cloud-objects/aws/iam"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// annotation holding the time (RFC3339) an ephemeral resource has last been refreshed, e.g. by a CI pipeline
+// redeploying the environment it belongs to
+const refreshedAtAnnotation = "aws-iam.redradrat.xyz/refreshed-at"
+
+// lastRefresh returns when the object has last been refreshed, by its creation, the last change of its spec or its
+// annotation; a missing or invalid time counts as never
+func lastRefresh(obj metav1.Object, specChangedAt string) time.Time {
+	last := obj.GetCreationTimestamp().Time
+	for _, value := range []string{obj.GetAnnotations()[refreshedAtAnnotation], specChangedAt} {
+		if refreshed, err := time.Parse(time.RFC3339, value); err == nil && refreshed.After(last) {
+			last = refreshed
+		}
+	}
+	return last
+}
+
+// roleExpired tells whether the Role is ephemeral and has not been refreshed within its TTL. A generation that hasn't
+// been observed yet is a change of the spec, and is recorded as a refresh.
+func roleExpired(role *iamv1beta1.Role) bool {
+	if role.Spec.TTLAfterLastSync == nil {
+		return false
+	}
+	if role.Status.ObservedGeneration != role.Generation {
+		role.Status.SpecChangedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	return time.Since(lastRefresh(role, role.Status.SpecChangedAt)) > role.Spec.TTLAfterLastSync.Duration
+}
+
+// expireRole deletes the AWS role of an expired Role, or the Role itself if it asks for it. The Role is not recreated
+// until it is refreshed again.
+func (r *RoleReconciler) expireRole(ctx context.Context, role *iamv1beta1.Role, sw client.StatusWriter) error {
+	log := r.Log.WithValues("role", fmt.Sprintf("%s/%s", role.Namespace, role.Name))
+	roleName := r.ResourcePrefix + role.RoleName()
+	message := fmt.Sprintf("Role has not been refreshed since %s; its TTL of %s has expired", lastRefresh(role, role.Status.SpecChangedAt).Format(time.RFC822Z), role.Spec.TTLAfterLastSync.Duration)
+
+	if r.ReadOnly {
+		return skipWithStatus(ctx, role, fmt.Sprintf("read-only mode: would delete expired Role '%s'", roleName), sw)
+	}
+	if r.CreateOnly {
+		return skipWithStatus(ctx, role, fmt.Sprintf("create-only mode: not deleting expired Role '%s'", roleName), sw)
+	}
+
+	if role.Spec.DeleteOnExpiry {
+		log.Info(fmt.Sprintf("%s; deleting the Role", message))
+		r.Notifier.Notify(role, v1.EventTypeNormal, "Expired", fmt.Sprintf("%s; deleting the Role", message))
+		// the finalizer takes care of the AWS role
+		return r.Delete(ctx, role)
+	}
+
+	if role.Status.ARN != "" {
+		log.Info(fmt.Sprintf("%s; deleting AWS role '%s'", message, roleName))
+		iamsvc, err := IAMService(r.Region, r.ReadOnly)
+		if err != nil {
+			return errWithStatus(ctx, role, err, sw)
+		}
+		parsedArn, err := aws.ARNify(role.Status.ARN)
+		if err != nil {
+			return errWithStatus(ctx, role, fmt.Errorf("ARN in Role status is not valid/parsable"), sw)
+		}
+		// the trust policy doesn't matter for the deletion
+		ins := iam.NewExistingRoleInstance(roleName, role.Spec.Description, 0, iam.PolicyDocument{}, parsedArn[len(parsedArn)-1])
+		if _, err := DeleteAWSObject(iamsvc, ins, roleCleanup(r, ctx, *role, iamsvc, roleName)); err != nil {
+			return errWithStatus(ctx, role, err, sw)
+		}
+		r.Notifier.Notify(role, v1.EventTypeNormal, "Expired", fmt.Sprintf("%s; deleted AWS role '%s'", message, role.Status.ARN))
+
+		role.Status.ARN = ""
+		role.Status.InlinePolicies = nil
+		role.Status.InlinePolicySize = 0
+		role.Status.SelectedPolicies = nil
+		role.Status.AttachedPolicies = nil
+	}
+
+	meta.SetStatusCondition(&role.Status.Conditions, metav1.Condition{
+		Type:               iamv1beta1.ExpiredCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: role.Generation,
+		Reason:             "TTLExpired",
+		Message:            message,
+	})
+	return skipWithStatus(ctx, role, fmt.Sprintf("%s; change it or refresh it via the annotation '%s' to recreate it", message, refreshedAtAnnotation), sw)
+}
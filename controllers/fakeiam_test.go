@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/accessanalyzer"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// fakeIAM answers the calls of the IAM clients returned by IAMService, and of the Access Analyzer clients used for
+// policy validation, in place of AWS, and records them. Calls refused by the read-only mode or the allow-list never
+// reach it.
+type fakeIAM struct {
+	mu        sync.Mutex
+	calls     []string
+	responses map[string]func(r *request.Request)
+}
+
+// installFakeIAM makes every IAM client talk to a new fakeIAM; it is removed again after the current spec
+func installFakeIAM() *fakeIAM {
+	f := &fakeIAM{responses: make(map[string]func(r *request.Request))}
+	iamClientHook = func(svc *awsiam.IAM) { f.apply(&svc.Handlers) }
+	accessAnalyzerClientHook = func(svc *accessanalyzer.AccessAnalyzer) { f.apply(&svc.Handlers) }
+	return f
+}
+
+func uninstallFakeIAM() {
+	iamClientHook = nil
+	accessAnalyzerClientHook = nil
+}
+
+// respond registers fn to fill in the output of the named operation (r.Data), or its error (r.Error)
+func (f *fakeIAM) respond(operation string, fn func(r *request.Request)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[operation] = fn
+}
+
+// fail makes the named operation return an AWS error with the given code
+func (f *fakeIAM) fail(operation, code string) {
+	f.respond(operation, func(r *request.Request) {
+		r.Error = awserr.New(code, "fake IAM: "+operation+" failed", nil)
+	})
+}
+
+// Calls returns the names of all operations received so far, in order
+func (f *fakeIAM) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.calls...)
+}
+
+func (f *fakeIAM) apply(handlers *request.Handlers) {
+	handlers.Sign.Clear()
+	handlers.Send.Clear()
+	handlers.ValidateResponse.Clear()
+	handlers.Unmarshal.Clear()
+	handlers.UnmarshalMeta.Clear()
+	handlers.UnmarshalError.Clear()
+	handlers.Retry.Clear()
+	handlers.AfterRetry.Clear()
+	handlers.Send.PushBack(f.send)
+}
+
+func (f *fakeIAM) send(r *request.Request) {
+	r.HTTPResponse = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(&bytes.Buffer{})}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, r.Operation.Name)
+	fn := f.responses[r.Operation.Name]
+	f.mu.Unlock()
+
+	if fn != nil {
+		fn(r)
+	}
+}
+
+// reconcileObject runs a single reconciliation of the given object
+func reconcileObject(r reconcile.Reconciler, obj client.Object) (ctrl.Result, error) {
+	return r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+}
+
+var specCounter int64
+
+// uniqueName returns a name not used by any other spec, as all specs share the same API server
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, atomic.AddInt64(&specCounter, 1))
+}
+
+// createWithStatus creates the object and then sets its status via setStatus, as the status is ignored on create
+func createWithStatus(obj client.Object, setStatus func()) {
+	Expect(k8sClient.Create(context.Background(), obj)).To(Succeed())
+	if setStatus != nil {
+		setStatus()
+		Expect(k8sClient.Status().Update(context.Background(), obj)).To(Succeed())
+	}
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+
+// how long to wait before checking a closed gate again
+const gateRetryInterval = 30 * time.Second
+
+// gateKinds holds the kinds a gate may reference. Gates can be set by anyone who may create the resource, so they must
+// not be a way to read e.g. Secrets through the operator.
+var gateKinds = map[schema.GroupVersionKind]bool{
+	{Version: "v1", Kind: "ConfigMap"}: true,
+}
+
+// gateClosed returns why the gate is closed, or an empty string if it is open (or there is none). The gate object is
+// looked up in the given namespace of the resource. A missing object or field keeps the gate closed, as it might just
+// not have been created yet. The reason never holds the value of the field, as it ends up in the logs.
+func gateClosed(ctx context.Context, c client.Client, gate *iamv1beta1.Gate, namespace string) (string, error) {
+	if gate == nil {
+		return "", nil
+	}
+	gv, err := schema.ParseGroupVersion(gate.APIVersion)
+	if err != nil {
+		return "", fmt.Errorf("gate has an invalid apiVersion: %v", err)
+	}
+	gvk := gv.WithKind(gate.Kind)
+	if !gateKinds[gvk] {
+		return "", fmt.Errorf("gate cannot reference kind '%s' of '%s'; only ConfigMaps are supported", gate.Kind, gate.APIVersion)
+	}
+	ref := fmt.Sprintf("%s '%s/%s'", gate.Kind, namespace, gate.Name)
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	if err := c.Get(ctx, client.ObjectKey{Name: gate.Name, Namespace: namespace}, obj); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("waiting for gate: %s does not exist", ref), nil
+		}
+		return "", err
+	}
+
+	value, found, err := unstructured.NestedFieldNoCopy(obj.Object, strings.Split(gate.FieldPath, ".")...)
+	if err != nil {
+		return "", fmt.Errorf("gate field '%s' of %s cannot be read", gate.FieldPath, ref)
+	}
+	if !found || value == nil {
+		return fmt.Sprintf("waiting for gate: field '%s' of %s is not set", gate.FieldPath, ref), nil
+	}
+	if gate.Value != "" && fmt.Sprint(value) != gate.Value {
+		return fmt.Sprintf("waiting for gate: field '%s' of %s is not '%s'", gate.FieldPath, ref, gate.Value), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+var _ = Describe("gateClosed", func() {
+	var (
+		ctx  context.Context
+		gate *iamv1beta1.Gate
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		gate = &iamv1beta1.Gate{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       uniqueName("bootstrap"),
+			FieldPath:  "data.ready",
+			Value:      "true",
+		}
+	})
+
+	It("waits for the field to hold the value, and releases the resource once it does", func() {
+		reason, err := gateClosed(ctx, k8sClient, gate, "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reason).To(ContainSubstring("does not exist"))
+
+		configMap := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: gate.Name, Namespace: "default"},
+			Data:       map[string]string{"ready": "not-yet"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+
+		reason, err = gateClosed(ctx, k8sClient, gate, "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reason).To(Equal("waiting for gate: field 'data.ready' of ConfigMap 'default/" + gate.Name + "' is not 'true'"))
+		Expect(reason).NotTo(ContainSubstring("not-yet"))
+
+		configMap.Data["ready"] = "true"
+		Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+
+		reason, err = gateClosed(ctx, k8sClient, gate, "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reason).To(BeEmpty())
+	})
+
+	It("refuses to read Secrets", func() {
+		gate.Kind = "Secret"
+
+		_, err := gateClosed(ctx, k8sClient, gate, "default")
+		Expect(err).To(MatchError(ContainSubstring("cannot reference kind 'Secret'")))
+	})
+})
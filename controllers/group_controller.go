@@ -20,7 +20,12 @@ import (
 	"context"
 	"fmt"
 
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -31,6 +36,9 @@ import (
 	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
 )
 
+// annotation holding the hash of the last applied Group spec without its managed policies, which are applied in place
+const lastAppliedGroupHashAnnotation = "aws-iam.redradrat.xyz/last-applied-group-hash"
+
 // GroupReconciler reconciles a Group object
 type GroupReconciler struct {
 	client.Client
@@ -38,6 +46,12 @@ type GroupReconciler struct {
 	Region         string
 	Scheme         *runtime.Scheme
 	ResourcePrefix string
+	Debouncer      *Debouncer
+	Notifier       *Notifier
+	ReadOnly       bool
+	CreateOnly     bool
+	// MaxManagedEntities caps the number of AWS entities the operator creates; 0 disables the cap
+	MaxManagedEntities int
 }
 
 // +kubebuilder:rbac:groups=aws-iam.redradrat.xyz,resources=groups,verbs=get;list;watch;create;update;patch;delete
@@ -51,13 +65,16 @@ func (r *GroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	err := r.Get(ctx, req.NamespacedName, &group)
 	if err != nil {
 		log.V(1).Info("unable to fetch Group")
+		r.Debouncer.Forget(req.NamespacedName)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	sw := newStatusPatcher(r.Status(), &group)
+
 	// Get our actual IAM Service to communicate with AWS; we don't need to continue without it
-	iamsvc, err := IAMService(r.Region)
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
 	if err != nil {
-		return ctrl.Result{}, errWithStatus(ctx, &group, err, r.Status())
+		return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
 	}
 
 	// new group instance
@@ -66,7 +83,7 @@ func (r *GroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	if group.Status.ARN != "" {
 		parsedArn, err := aws.ARNify(group.Status.ARN)
 		if err != nil {
-			return ctrl.Result{}, errWithStatus(ctx, &group, fmt.Errorf("ARN in Group status is not valid/parsable"), r.Status())
+			return ctrl.Result{}, errWithStatus(ctx, &group, fmt.Errorf("ARN in Group status is not valid/parsable"), sw)
 		}
 		ins = iam.NewExistingGroupInstance(groupName, parsedArn[len(parsedArn)-1])
 	} else {
@@ -78,10 +95,45 @@ func (r *GroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, nil
 	}
 
+	hash, err := specHash(group.Spec)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
+	}
+	hashedSpec := group.Spec
+	hashedSpec.ManagedPolicyArns = nil
+	groupHash, err := specHash(hashedSpec)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
+	}
+
+	// return if the spec is identical to the last applied one (e.g. an unchanged manifest has been re-applied)
+	if group.ObjectMeta.DeletionTimestamp.IsZero() && lastAppliedSpecMatches(&group, hash) {
+		return ctrl.Result{}, observeGeneration(ctx, &group, sw)
+	}
+
+	// wait for rapid consecutive spec changes to settle, before we talk to AWS
+	if group.ObjectMeta.DeletionTimestamp.IsZero() {
+		if wait := r.Debouncer.Wait(req.NamespacedName, group.ObjectMeta.Generation); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	// don't act before the gate is open; deletions are never held back
+	if group.ObjectMeta.DeletionTimestamp.IsZero() {
+		reason, err := gateClosed(ctx, r.Client, group.Spec.Gate, group.Namespace)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
+		}
+		if reason != "" {
+			log.Info(reason)
+			return ctrl.Result{RequeueAfter: gateRetryInterval}, nil
+		}
+	}
+
 	// the finalizer for deleting the actual aws resources
 	groupsFinalizer := "group.aws-aws-iam.redradrat.xyz"
 
-	cleanupFunc := groupCleanup(r, ctx, group)
+	cleanupFunc := groupCleanup(r, ctx, group, iamsvc, groupName)
 
 	// Check Deletion and finalizer
 	if group.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -98,15 +150,23 @@ func (r *GroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	} else {
 		if containsString(group.ObjectMeta.Finalizers, groupsFinalizer) {
 			// our finalizer is present, so lets handle any external dependency
+			if r.ReadOnly {
+				return ctrl.Result{}, skipWithStatus(ctx, &group, fmt.Sprintf("read-only mode: would delete Group '%s'", groupName), sw)
+			}
 
-			// delete the actual AWS Object and pass the cleanup function
-			statusUpdater, err := DeleteAWSObject(iamsvc, ins, cleanupFunc)
-			// we got a StatusUpdater function returned... let's execute it
-			statusUpdater(ctx, ins, &group, r.Status(), log)
-			if err != nil {
-				// we had an error during AWS Object deletion... so we return here to retry
-				log.Error(err, "unable to delete Group")
-				return ctrl.Result{}, err
+			if r.CreateOnly {
+				// leave the AWS object untouched, but let the resource go
+				log.Info(fmt.Sprintf("create-only mode: leaving Group '%s' in AWS", groupName))
+			} else {
+				// delete the actual AWS Object and pass the cleanup function
+				statusUpdater, err := DeleteAWSObject(iamsvc, ins, cleanupFunc)
+				// we got a StatusUpdater function returned... let's execute it
+				statusUpdater(ctx, ins, &group, sw, log)
+				if err != nil {
+					// we had an error during AWS Object deletion... so we return here to retry
+					log.Error(err, "unable to delete Group")
+					return ctrl.Result{}, err
+				}
 			}
 
 			// remove our finalizer from the list and update it.
@@ -115,6 +175,9 @@ func (r *GroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 				log.Error(err, "unable to remove finalizer from Group")
 				return ctrl.Result{}, err
 			}
+			if !r.CreateOnly {
+				r.Notifier.Notify(&group, v1.EventTypeNormal, "Deleted", fmt.Sprintf("Deleted Group '%s'", group.Status.ARN))
+			}
 		}
 
 		// Stop reconciliation as the item is being deleted
@@ -123,12 +186,47 @@ func (r *GroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 
 	// RECONCILE THE RESOURCE
 
+	if r.ReadOnly {
+		action := "create"
+		if group.Status.ARN != "" {
+			action = "recreate"
+		}
+		return ctrl.Result{}, skipWithStatus(ctx, &group, fmt.Sprintf("read-only mode: would %s Group '%s' with %d users", action, groupName, len(group.Spec.Users)), sw)
+	}
+
+	if r.CreateOnly && group.Status.ARN != "" {
+		return ctrl.Result{}, skipWithStatus(ctx, &group, fmt.Sprintf("create-only mode: not recreating existing Group '%s'", groupName), sw)
+	}
+
+	if group.Status.ARN == "" {
+		if err := checkManagedEntityCap(ctx, r.Client, r.MaxManagedEntities); err != nil {
+			r.Notifier.Notify(&group, v1.EventTypeWarning, "ManagedEntityCapReached", err.Error())
+			return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
+		}
+	}
+
+	for _, policyArn := range group.Spec.ManagedPolicyArns {
+		if !awsarn.IsARN(policyArn) {
+			return ctrl.Result{}, errWithStatus(ctx, &group, fmt.Errorf("managed policy ARN '%s' is not valid/parsable", policyArn), sw)
+		}
+	}
+
+	// if nothing but the managed policies changed, they are attached and detached in place, so the Group keeps its
+	// members
+	if group.Status.ARN != "" && group.ObjectMeta.Annotations[lastAppliedGroupHashAnnotation] == groupHash {
+		if err := r.reconcileGroupPolicies(ctx, iamsvc, &group, groupName); err != nil {
+			log.Error(err, "unable to reconcile managed policies of Group")
+			return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
+		}
+		return ctrl.Result{}, r.finishGroupReconcile(ctx, &group, hash, groupHash, sw)
+	}
+
 	// if there is already an ARN in our status, then we recreate the object completely
 	// (because AWS only supports description updates)
 	if group.Status.ARN != "" {
 		// Delete the actual AWS Object and pass the cleanup function
 		statusWriter, err := DeleteAWSObject(iamsvc, ins, cleanupFunc)
-		statusWriter(ctx, ins, &group, r.Status(), log)
+		statusWriter(ctx, ins, &group, sw, log)
 		if err != nil {
 			// we had an error during AWS Object deletion... so we return here to retry
 			log.Error(err, "error while deleting Group during reconciliation")
@@ -137,44 +235,147 @@ func (r *GroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	}
 
 	statusWriter, err := CreateAWSObject(iamsvc, ins, DoNothingPreFunc)
-	statusWriter(ctx, ins, &group, r.Status(), log)
+	statusWriter(ctx, ins, &group, sw, log)
 	if err != nil {
 		log.Error(err, "error while creating Group during reconciliation")
 		return ctrl.Result{}, err
 	}
 
+	// the Group instance always creates the group in the root path; move it right after creation
+	if group.Spec.Path != "" && group.Spec.Path != "/" {
+		groupArn, err := moveGroupToPath(iamsvc, groupName, group.Spec.Path)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
+		}
+		group.Status.ARN = groupArn
+	}
+
 	// Now add all required users
 	for _, user := range group.Spec.Users {
 		// Get the User object
 		userObj := iamv1beta1.User{}
 		r.Client.Get(ctx, client.ObjectKey{Name: user.Name, Namespace: user.Namespace}, &userObj)
 		if err != nil {
-			return ctrl.Result{}, errWithStatus(ctx, &group, err, r.Status())
+			return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
 		}
 
 		// Err if ARN is not available in the user obj
 		if userObj.Status.ARN == "" {
-			return ctrl.Result{}, errWithStatus(ctx, &group, fmt.Errorf("referenced user resource '%s/%s' has not yet been created", user.Namespace, user.Name), r.Status())
+			return ctrl.Result{}, errWithStatus(ctx, &group, fmt.Errorf("referenced user resource '%s/%s' has not yet been created", user.Namespace, user.Name), sw)
 		}
 
 		// parse the user arn
 		parsedArn, err := aws.ARNify(userObj.Status.ARN)
 		if err != nil {
-			return ctrl.Result{}, errWithStatus(ctx, &group, fmt.Errorf("ARN in referenced User status is not valid/parsable"), r.Status())
+			return ctrl.Result{}, errWithStatus(ctx, &group, fmt.Errorf("ARN in referenced User status is not valid/parsable"), sw)
 		}
 
 		// Now add the user to our Group Instance
 		if err = ins.AddUser(iamsvc, parsedArn[len(parsedArn)-1]); err != nil {
-			return ctrl.Result{}, errWithStatus(ctx, &group, err, r.Status())
+			return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
 		}
 	}
 
-	group.Status.ObservedGeneration = group.ObjectMeta.Generation
-	if err := r.Status().Update(ctx, &group); err != nil {
-		return ctrl.Result{}, err
+	// the recreated Group also lost the Users that declare their membership themselves
+	declaringUsers, err := usersDeclaringGroup(ctx, r.Client, &group)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
+	}
+	for _, userArn := range declaringUsers {
+		if err = ins.AddUser(iamsvc, userArn); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
+		}
 	}
 
-	return ctrl.Result{}, nil
+	// the Group has just been created, so it comes without any attached policies
+	group.Status.ManagedPolicyArns = nil
+	for _, policyArn := range group.Spec.ManagedPolicyArns {
+		if _, err := iamsvc.AttachGroupPolicy(&awsiam.AttachGroupPolicyInput{
+			GroupName: awssdk.String(groupName),
+			PolicyArn: awssdk.String(policyArn),
+		}); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &group, err, sw)
+		}
+		group.Status.ManagedPolicyArns = append(group.Status.ManagedPolicyArns, policyArn)
+	}
+
+	return ctrl.Result{}, r.finishGroupReconcile(ctx, &group, hash, groupHash, sw)
+}
+
+// finishGroupReconcile records the successful reconcile of the Group in its status and annotations
+func (r *GroupReconciler) finishGroupReconcile(ctx context.Context, group *iamv1beta1.Group, hash, groupHash string, sw *statusPatcher) error {
+	group.Status.ObservedGeneration = sw.generation
+	if err := sw.Update(ctx, group); err != nil {
+		return err
+	}
+
+	annotations := group.GetAnnotations()
+	if annotations[lastAppliedSpecHashAnnotation] != hash || annotations[lastAppliedGroupHashAnnotation] != groupHash {
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[lastAppliedSpecHashAnnotation] = hash
+		annotations[lastAppliedGroupHashAnnotation] = groupHash
+		group.SetAnnotations(annotations)
+		if err := r.Update(ctx, group); err != nil {
+			r.Log.Error(err, "unable to store last applied spec hash for Group", "group", client.ObjectKeyFromObject(group))
+			return err
+		}
+	}
+	r.Notifier.Notify(group, v1.EventTypeNormal, "Reconciled", fmt.Sprintf("Reconciled Group '%s'", group.Status.ARN))
+	return nil
+}
+
+// reconcileGroupPolicies attaches the managed policies added to the spec of the existing Group and detaches the
+// removed ones, unless something else attaches them to the Group as well
+func (r *GroupReconciler) reconcileGroupPolicies(ctx context.Context, svc iamiface.IAMAPI, group *iamv1beta1.Group, groupName string) error {
+	for _, policyArn := range group.Spec.ManagedPolicyArns {
+		if containsString(group.Status.ManagedPolicyArns, policyArn) {
+			continue
+		}
+		if _, err := svc.AttachGroupPolicy(&awsiam.AttachGroupPolicyInput{
+			GroupName: awssdk.String(groupName),
+			PolicyArn: awssdk.String(policyArn),
+		}); err != nil {
+			return err
+		}
+		group.Status.ManagedPolicyArns = append(group.Status.ManagedPolicyArns, policyArn)
+	}
+
+	for _, policyArn := range append([]string(nil), group.Status.ManagedPolicyArns...) {
+		if containsString(group.Spec.ManagedPolicyArns, policyArn) {
+			continue
+		}
+		holder, err := attachmentHolder(ctx, r.Client, iamv1beta1.GroupTargetType, policyArn, group.Status.ARN, group)
+		if err != nil {
+			return err
+		}
+		if holder != "" {
+			r.Log.Info(fmt.Sprintf("leaving policy '%s' attached to Group '%s', as %s attaches it as well", policyArn, groupName, holder))
+		} else if _, err := svc.DetachGroupPolicy(&awsiam.DetachGroupPolicyInput{
+			GroupName: awssdk.String(groupName),
+			PolicyArn: awssdk.String(policyArn),
+		}); err != nil && !aws.IsNotExistsError(err) {
+			return err
+		}
+		group.Status.ManagedPolicyArns = removeString(group.Status.ManagedPolicyArns, policyArn)
+	}
+	return nil
+}
+
+// moveGroupToPath moves the group to the given IAM path, and returns its new ARN (the path is part of it)
+func moveGroupToPath(svc iamiface.IAMAPI, groupName string, path string) (string, error) {
+	if _, err := svc.UpdateGroup(&awsiam.UpdateGroupInput{
+		GroupName: awssdk.String(groupName),
+		NewPath:   awssdk.String(path),
+	}); err != nil {
+		return "", err
+	}
+	out, err := svc.GetGroup(&awsiam.GetGroupInput{GroupName: awssdk.String(groupName)})
+	if err != nil {
+		return "", err
+	}
+	return awssdk.StringValue(out.Group.Arn), nil
 }
 
 func (r *GroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -184,7 +385,7 @@ func (r *GroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 // Returns a function, that does everything necessary before we can delete our actual User (cleanup)
-func groupCleanup(r *GroupReconciler, ctx context.Context, group iamv1beta1.Group) func() error {
+func groupCleanup(r *GroupReconciler, ctx context.Context, group iamv1beta1.Group, svc iamiface.IAMAPI, groupName string) func() error {
 	return func() error {
 		attachments := iamv1beta1.PolicyAttachmentList{}
 		if err := r.List(ctx, &attachments); err != nil {
@@ -198,6 +399,23 @@ func groupCleanup(r *GroupReconciler, ctx context.Context, group iamv1beta1.Grou
 				}
 			}
 		}
+		sets, err := setsAttachingTo(ctx, r.Client, iamv1beta1.GroupTargetType, group.Namespace, group.Name)
+		if err != nil {
+			return err
+		}
+		if len(sets) != 0 {
+			return fmt.Errorf("cannot delete Group due to existing PolicyAttachmentSet '%s/%s'", sets[0].Name, sets[0].Namespace)
+		}
+
+		// AWS refuses to delete groups with attached policies
+		for _, policyArn := range group.Status.ManagedPolicyArns {
+			if _, err := svc.DetachGroupPolicy(&awsiam.DetachGroupPolicyInput{
+				GroupName: awssdk.String(groupName),
+				PolicyArn: awssdk.String(policyArn),
+			}); err != nil && !aws.IsNotExistsError(err) {
+				return err
+			}
+		}
 
 		return nil
 	}
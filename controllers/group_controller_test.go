@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+var _ = Describe("Group controller", func() {
+	var (
+		ctx        context.Context
+		fake       *fakeIAM
+		reconciler *GroupReconciler
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = installFakeIAM()
+		reconciler = &GroupReconciler{
+			Client: k8sClient,
+			Log:    ctrl.Log.WithName("controllers").WithName("Group"),
+			Scheme: k8sClient.Scheme(),
+			Region: "eu-west-1",
+		}
+	})
+
+	AfterEach(func() {
+		uninstallFakeIAM()
+	})
+
+	Context("when nothing but the managed policies changed", func() {
+		const (
+			readOnly = "arn:aws:iam::aws:policy/ReadOnlyAccess"
+			audit    = "arn:aws:iam::aws:policy/SecurityAudit"
+			billing  = "arn:aws:iam::aws:policy/job-function/Billing"
+		)
+
+		// newManagedPolicyGroup returns a Group applied with ReadOnlyAccess and SecurityAudit, which has been changed
+		// to SecurityAudit and Billing since
+		newManagedPolicyGroup := func() *iamv1beta1.Group {
+			group := &iamv1beta1.Group{
+				ObjectMeta: metav1.ObjectMeta{Name: uniqueName("group"), Namespace: "default"},
+				Spec:       iamv1beta1.GroupSpec{ManagedPolicyArns: []string{audit, billing}},
+			}
+			hashedSpec := group.Spec
+			hashedSpec.ManagedPolicyArns = nil
+			groupHash, err := specHash(hashedSpec)
+			Expect(err).NotTo(HaveOccurred())
+			group.Annotations = map[string]string{lastAppliedGroupHashAnnotation: groupHash}
+
+			createWithStatus(group, func() {
+				group.Status.ARN = "arn:aws:iam::123456789012:group/" + group.Name
+				group.Status.State = iamv1beta1.OkSyncState
+				group.Status.ObservedGeneration = group.Generation - 1
+				group.Status.ManagedPolicyArns = []string{readOnly, audit}
+			})
+			return group
+		}
+
+		var events []string
+
+		BeforeEach(func() {
+			events = nil
+			fake.respond("AttachGroupPolicy", func(r *request.Request) {
+				events = append(events, "attach "+awssdk.StringValue(r.Params.(*awsiam.AttachGroupPolicyInput).PolicyArn))
+			})
+			fake.respond("DetachGroupPolicy", func(r *request.Request) {
+				events = append(events, "detach "+awssdk.StringValue(r.Params.(*awsiam.DetachGroupPolicyInput).PolicyArn))
+			})
+		})
+
+		It("attaches and detaches the difference, without recreating the Group", func() {
+			group := newManagedPolicyGroup()
+
+			_, err := reconcileObject(reconciler, group)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(Equal([]string{"attach " + billing, "detach " + readOnly}))
+			Expect(fake.Calls()).NotTo(ContainElement("DeleteGroup"))
+			Expect(fake.Calls()).NotTo(ContainElement("CreateGroup"))
+			Expect(fake.Calls()).NotTo(ContainElement("AddUserToGroup"))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(group), group)).To(Succeed())
+			Expect(group.Status.State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(group.Status.ManagedPolicyArns).To(ConsistOf(audit, billing))
+			Expect(group.Status.ObservedGeneration).To(Equal(group.Generation))
+			Expect(group.Annotations).To(HaveKey(lastAppliedSpecHashAnnotation))
+		})
+
+		It("leaves a removed policy attached, while a PolicyAttachment attaches it as well", func() {
+			group := newManagedPolicyGroup()
+			attachment := &iamv1beta1.PolicyAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: uniqueName("attachment"), Namespace: "default"},
+				Spec: iamv1beta1.PolicyAttachmentSpec{
+					ExternalPolicy:  iamv1beta1.ExternalResource{ARN: readOnly},
+					TargetReference: iamv1beta1.TargetReference{Type: iamv1beta1.GroupTargetType, Name: group.Name, Namespace: group.Namespace},
+				},
+			}
+			createWithStatus(attachment, func() {
+				attachment.Status.ARN = group.Status.ARN
+				attachment.Status.State = iamv1beta1.OkSyncState
+			})
+
+			_, err := reconcileObject(reconciler, group)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(Equal([]string{"attach " + billing}))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(group), group)).To(Succeed())
+			Expect(group.Status.ManagedPolicyArns).To(ConsistOf(audit, billing))
+		})
+
+		It("holds its policies for PolicyAttachments detaching them from the Group", func() {
+			group := newManagedPolicyGroup()
+			attachment := &iamv1beta1.PolicyAttachment{ObjectMeta: metav1.ObjectMeta{Name: uniqueName("attachment"), Namespace: "default"}}
+
+			holder, err := attachmentHolder(ctx, k8sClient, iamv1beta1.GroupTargetType, audit, group.Status.ARN, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(holder).To(Equal("the managed policies of Group 'default/" + group.Name + "'"))
+
+			holder, err = attachmentHolder(ctx, k8sClient, iamv1beta1.GroupTargetType, billing, group.Status.ARN, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(holder).To(BeEmpty())
+		})
+	})
+
+	Context("with a path", func() {
+		It("creates the Group under the resource prefix, and moves it to the path", func() {
+			reconciler.ResourcePrefix = "k8s-"
+			group := &iamv1beta1.Group{
+				ObjectMeta: metav1.ObjectMeta{Name: uniqueName("group"), Namespace: "default"},
+				Spec:       iamv1beta1.GroupSpec{Path: "/teams/platform/"},
+			}
+			Expect(k8sClient.Create(ctx, group)).To(Succeed())
+			var created, moved string
+			fake.respond("CreateGroup", func(r *request.Request) {
+				created = awssdk.StringValue(r.Params.(*awsiam.CreateGroupInput).GroupName)
+				r.Data.(*awsiam.CreateGroupOutput).Group = &awsiam.Group{Arn: awssdk.String("arn:aws:iam::123456789012:group/" + created)}
+			})
+			fake.respond("UpdateGroup", func(r *request.Request) {
+				moved = awssdk.StringValue(r.Params.(*awsiam.UpdateGroupInput).NewPath)
+			})
+			fake.respond("GetGroup", func(r *request.Request) {
+				name := awssdk.StringValue(r.Params.(*awsiam.GetGroupInput).GroupName)
+				r.Data.(*awsiam.GetGroupOutput).Group = &awsiam.Group{Arn: awssdk.String("arn:aws:iam::123456789012:group" + moved + name)}
+			})
+
+			_, err := reconcileObject(reconciler, group)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(Equal("k8s-" + group.Name))
+			Expect(moved).To(Equal("/teams/platform/"))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(group), group)).To(Succeed())
+			Expect(group.Status.State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(group.Status.ARN).To(Equal("arn:aws:iam::123456789012:group/teams/platform/k8s-" + group.Name))
+		})
+	})
+
+	Context("with an annotation prefix for notifications", func() {
+		It("copies the prefixed annotations into the emitted events", func() {
+			recorder := &annotationRecorder{}
+			reconciler.Notifier = NewNotifier(recorder, "changes.example.com/")
+			group := &iamv1beta1.Group{ObjectMeta: metav1.ObjectMeta{
+				Name:        uniqueName("group"),
+				Namespace:   "default",
+				Annotations: map[string]string{"changes.example.com/ticket": "CHG-1234", "team": "platform"},
+			}}
+			Expect(k8sClient.Create(ctx, group)).To(Succeed())
+			fake.respond("CreateGroup", func(r *request.Request) {
+				name := awssdk.StringValue(r.Params.(*awsiam.CreateGroupInput).GroupName)
+				r.Data.(*awsiam.CreateGroupOutput).Group = &awsiam.Group{Arn: awssdk.String("arn:aws:iam::123456789012:group/" + name)}
+			})
+
+			_, err := reconcileObject(reconciler, group)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.events).To(HaveLen(1))
+			Expect(recorder.events[0].reason).To(Equal("Reconciled"))
+			Expect(recorder.events[0].annotations).To(Equal(map[string]string{"changes.example.com/ticket": "CHG-1234"}))
+		})
+	})
+})
+
+// annotationRecorder records the reasons and annotations of the emitted events
+type annotationRecorder struct {
+	events []recordedEvent
+}
+
+type recordedEvent struct {
+	reason      string
+	annotations map[string]string
+}
+
+func (r *annotationRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.AnnotatedEventf(object, nil, eventtype, reason, "%s", message)
+}
+
+func (r *annotationRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.AnnotatedEventf(object, nil, eventtype, reason, messageFmt, args...)
+}
+
+func (r *annotationRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.events = append(r.events, recordedEvent{reason: reason, annotations: annotations})
+}
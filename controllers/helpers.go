@@ -2,9 +2,16 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	awsiam "github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
@@ -17,6 +24,12 @@ import (
 	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
 )
 
+// annotation holding the hash of the last successfully reconciled spec
+const lastAppliedSpecHashAnnotation = "aws-iam.redradrat.xyz/last-applied-spec-hash"
+
+// annotation overriding the resync period of a single resource (e.g. "5m")
+const resyncPeriodAnnotation = "iam.aws/resync-period"
+
 type AWSObjectStatusResource interface {
 	GetStatus() *iamv1beta1.AWSObjectStatus
 	RuntimeObject() client.Object
@@ -104,7 +117,104 @@ func errWithStatus(ctx context.Context, obj AWSObjectStatusResource, err error,
 	return origerr
 }
 
-func IAMService(region string) (*awsiam.IAM, error) {
+// specHash returns a hex encoded sha256 hash of the given spec. Additional values (e.g. resource versions of
+// referenced resources) can be passed, so that a change in them also changes the hash.
+func specHash(spec interface{}, extra ...string) (string, error) {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(b)
+	for _, e := range extra {
+		h.Write([]byte(e))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lastAppliedSpecMatches checks whether the given spec hash equals the one of the last successful reconciliation.
+// This is the case for cosmetic re-applies of unchanged manifests, and for changes that have been reverted before
+// they got applied. The generation these may have bumped only needs to be observed; we don't need to talk to AWS.
+func lastAppliedSpecMatches(obj AWSObjectStatusResource, hash string) bool {
+	if obj.GetStatus().State != iamv1beta1.OkSyncState {
+		return false
+	}
+	return obj.RuntimeObject().GetAnnotations()[lastAppliedSpecHashAnnotation] == hash
+}
+
+// storeLastAppliedSpecHash saves the given spec hash in the annotations of the object
+func storeLastAppliedSpecHash(ctx context.Context, c client.Client, obj AWSObjectStatusResource, hash string) error {
+	o := obj.RuntimeObject()
+	annotations := o.GetAnnotations()
+	if annotations[lastAppliedSpecHashAnnotation] == hash {
+		return nil
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[lastAppliedSpecHashAnnotation] = hash
+	o.SetAnnotations(annotations)
+	return c.Update(ctx, o)
+}
+
+// observeGeneration marks the current generation of the object as observed, without doing anything else
+func observeGeneration(ctx context.Context, obj AWSObjectStatusResource, sw client.StatusWriter) error {
+	generation := appliedGeneration(obj, sw)
+	if obj.GetStatus().ObservedGeneration == generation {
+		return nil
+	}
+	obj.GetStatus().ObservedGeneration = generation
+	return sw.Update(ctx, obj.RuntimeObject())
+}
+
+// skipWithStatus records in the status, that the operator deliberately did not act on the object
+func skipWithStatus(ctx context.Context, obj AWSObjectStatusResource, message string, sw client.StatusWriter) error {
+	status := obj.GetStatus()
+	generation := appliedGeneration(obj, sw)
+	// don't write anything if nothing changed; otherwise every status write would trigger the next reconcile
+	if status.State == iamv1beta1.SkippedSyncState && status.Message == message && status.ObservedGeneration == generation {
+		return nil
+	}
+	status.State = iamv1beta1.SkippedSyncState
+	status.Message = message
+	status.LastSyncAttempt = time.Now().Format(time.RFC822Z)
+	status.ObservedGeneration = generation
+	return sw.Update(ctx, obj.RuntimeObject())
+}
+
+// resyncPeriod returns the resync period given via annotation, or the default if there is no valid one
+func resyncPeriod(obj AWSObjectStatusResource, defaultPeriod time.Duration) time.Duration {
+	value, ok := obj.RuntimeObject().GetAnnotations()[resyncPeriodAnnotation]
+	if !ok {
+		return defaultPeriod
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period <= 0 {
+		return defaultPeriod
+	}
+	return period
+}
+
+// ErrCodeReadOnlyMode is the error code returned for every mutating AWS call while in read-only mode
+const ErrCodeReadOnlyMode = "ReadOnlyMode"
+
+// isMutatingOperation tells whether the named IAM API operation changes anything in AWS
+func isMutatingOperation(operation string) bool {
+	for _, prefix := range []string{"Get", "List", "Simulate", "Generate"} {
+		if strings.HasPrefix(operation, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// iamClientHook, if set, is applied to every IAM client returned by IAMService; tests use it to answer the calls
+// in place of AWS
+var iamClientHook func(svc *awsiam.IAM)
+
+// IAMService returns an IAM client for the given region. If readOnly is set, the client refuses every mutating
+// operation, no matter which code path tries to call it.
+func IAMService(region string, readOnly bool) (*awsiam.IAM, error) {
 	session, err := session.NewSession(&awssdk.Config{
 		Region: awssdk.String(region)},
 	)
@@ -112,7 +222,60 @@ func IAMService(region string) (*awsiam.IAM, error) {
 		return nil, err
 	}
 
-	return iam.Client(session), nil
+	svc := iam.Client(session)
+	if readOnly {
+		svc.Handlers.Validate.PushFront(func(r *request.Request) {
+			if isMutatingOperation(r.Operation.Name) {
+				r.Error = awserr.New(ErrCodeReadOnlyMode, fmt.Sprintf("operator runs in read-only mode; refusing to call '%s'", r.Operation.Name), nil)
+			}
+		})
+	}
+	restrictOperations(&svc.Handlers, "iam")
+	if iamClientHook != nil {
+		iamClientHook(svc)
+	}
+
+	return svc, nil
+}
+
+// statusPatcher writes the status of one object for the duration of a single reconciliation. Every Update is sent
+// as merge patch of the changes made since the last write. Unlike an update, the patch doesn't carry the
+// resourceVersion, so it doesn't fail when the spec has been changed concurrently (e.g. while we were talking to
+// AWS). As the patch response carries the spec and generation of the server, the generation we are actually
+// applying is captured once, before anything is done with the object.
+type statusPatcher struct {
+	client.StatusWriter
+	base       client.Object
+	generation int64
+}
+
+// newStatusPatcher returns a statusPatcher for the freshly fetched obj
+func newStatusPatcher(sw client.StatusWriter, obj client.Object) *statusPatcher {
+	return &statusPatcher{
+		StatusWriter: sw,
+		base:         obj.DeepCopyObject().(client.Object),
+		generation:   obj.GetGeneration(),
+	}
+}
+
+// Update writes the status changes made to obj since the last write
+func (p *statusPatcher) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	base := p.base.DeepCopyObject().(client.Object)
+	// spec updates done in between (e.g. finalizers) moved the resourceVersion; it must not end up in the patch
+	base.SetResourceVersion(obj.GetResourceVersion())
+	if err := p.StatusWriter.Patch(ctx, obj, client.MergeFrom(base)); err != nil {
+		return err
+	}
+	p.base = obj.DeepCopyObject().(client.Object)
+	return nil
+}
+
+// appliedGeneration returns the generation the reconciliation writing via sw acts on
+func appliedGeneration(obj AWSObjectStatusResource, sw client.StatusWriter) int64 {
+	if p, ok := sw.(*statusPatcher); ok {
+		return p.generation
+	}
+	return obj.RuntimeObject().GetGeneration()
 }
 
 type StatusUpdater func(ctx context.Context, ins aws.Instance, obj AWSObjectStatusResource, sw client.StatusWriter, log logr.Logger)
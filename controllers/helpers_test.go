@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+var _ = Describe("IAMService", func() {
+	var fake *fakeIAM
+
+	BeforeEach(func() {
+		fake = installFakeIAM()
+	})
+
+	AfterEach(func() {
+		uninstallFakeIAM()
+	})
+
+	Context("in read-only mode", func() {
+		It("refuses mutating operations, but lets reads through", func() {
+			svc, err := IAMService("eu-west-1", true)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = svc.DeleteRole(&awsiam.DeleteRoleInput{RoleName: awssdk.String("some-role")})
+			Expect(err).To(HaveOccurred())
+			Expect(err.(awserr.Error).Code()).To(Equal(ErrCodeReadOnlyMode))
+			Expect(fake.Calls()).To(BeEmpty())
+
+			_, err = svc.ListRoles(&awsiam.ListRolesInput{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(Equal([]string{"ListRoles"}))
+		})
+	})
+
+	Context("with an allow-list of operations", func() {
+		AfterEach(func() {
+			AllowOperations(nil)
+		})
+
+		It("blocks the operations not on it", func() {
+			AllowOperations([]string{"iam:ListRoles"})
+			svc, err := IAMService("eu-west-1", false)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = svc.ListRoles(&awsiam.ListRolesInput{})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = svc.CreateRole(&awsiam.CreateRoleInput{RoleName: awssdk.String("some-role"), AssumeRolePolicyDocument: awssdk.String("{}")})
+			Expect(err).To(HaveOccurred())
+			Expect(err.(awserr.Error).Code()).To(Equal(ErrCodeOperationNotAllowed))
+			Expect(fake.Calls()).To(Equal([]string{"ListRoles"}))
+		})
+	})
+})
+
+var _ = Describe("statusPatcher", func() {
+	It("doesn't fail on or clobber a concurrent spec change, and keeps the fetched generation", func() {
+		ctx := context.Background()
+		policy := &iamv1beta1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: uniqueName("policy"), Namespace: "default"},
+			Spec:       iamv1beta1.PolicySpec{Description: "before"},
+		}
+		Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+		sw := newStatusPatcher(k8sClient.Status(), policy)
+		fetched := policy.Generation
+
+		// someone changes the spec while we are talking to AWS
+		concurrent := policy.DeepCopy()
+		concurrent.Spec.Description = "after"
+		concurrent.Generation++
+		Expect(k8sClient.Update(ctx, concurrent)).To(Succeed())
+
+		policy.Status.State = iamv1beta1.OkSyncState
+		policy.Status.ARN = "arn:aws:iam::123456789012:policy/" + policy.Name
+		Expect(errors.IsConflict(k8sClient.Status().Update(ctx, policy.DeepCopy()))).To(BeTrue())
+		Expect(sw.Update(ctx, policy)).To(Succeed())
+		Expect(appliedGeneration(policy, sw)).To(Equal(fetched))
+
+		// later writes only carry what changed since
+		policy.Status.Message = "done"
+		Expect(sw.Update(ctx, policy)).To(Succeed())
+
+		current := &iamv1beta1.Policy{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), current)).To(Succeed())
+		Expect(current.Spec.Description).To(Equal("after"))
+		Expect(current.Status.State).To(Equal(iamv1beta1.OkSyncState))
+		Expect(current.Status.ARN).To(Equal(policy.Status.ARN))
+		Expect(current.Status.Message).To(Equal("done"))
+	})
+})
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/redradrat/cloud-objects/aws"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// AWS limits the aggregated size of all inline policies per entity (whitespace is not counted)
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_iam-quotas.html
+const (
+	roleInlinePolicySizeLimit = 10240
+	userInlinePolicySizeLimit = 2048
+)
+
+type inlinePolicyDocument struct {
+	name     string
+	document string
+}
+
+// inlinePolicyDocuments marshals the given inline policies in order of their names, and validates their aggregated
+// size against the limit of the target type. It returns the documents and their aggregated size.
+func inlinePolicyDocuments(targetType iamv1beta1.TargetType, policies []iamv1beta1.InlinePolicy) ([]inlinePolicyDocument, int, error) {
+	var limit int
+	switch targetType {
+	case iamv1beta1.RoleTargetType:
+		limit = roleInlinePolicySizeLimit
+	case iamv1beta1.UserTargetType:
+		limit = userInlinePolicySizeLimit
+	default:
+		return nil, 0, fmt.Errorf("inline policies are not supported for type '%s'", targetType)
+	}
+
+	var docs []inlinePolicyDocument
+	size := 0
+	for _, policy := range policies {
+		for _, doc := range docs {
+			if doc.name == policy.Name {
+				return nil, 0, fmt.Errorf("inline policy name '%s' is not unique", policy.Name)
+			}
+		}
+		doc := policy.Statement.MarshalPolicyDocument()
+		if err := checkUniqueSids(doc); err != nil {
+			return nil, 0, fmt.Errorf("inline policy '%s': %v", policy.Name, err)
+		}
+		// duplicates would only count against the tight size limit
+		doc = dedupeStatements(doc)
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return nil, 0, err
+		}
+		docs = append(docs, inlinePolicyDocument{name: policy.Name, document: string(b)})
+		size += len(b)
+	}
+	if size > limit {
+		return nil, size, fmt.Errorf("aggregated size of inline policies (%d characters) exceeds the limit of %d characters for a %s", size, limit, targetType)
+	}
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].name < docs[j].name })
+	return docs, size, nil
+}
+
+// reconcileInlinePolicies puts the given inline policies for the named Role or User and deletes the previously
+// applied ones that are not given anymore, unless keepRemoved is set. It returns the names of the now applied inline
+// policies and their aggregated size.
+func reconcileInlinePolicies(svc iamiface.IAMAPI, targetType iamv1beta1.TargetType, name string, policies []iamv1beta1.InlinePolicy, applied []string, keepRemoved bool) ([]string, int, error) {
+	docs, size, err := inlinePolicyDocuments(targetType, policies)
+	if err != nil {
+		return applied, size, err
+	}
+
+	var names []string
+	for _, doc := range docs {
+		if err := putInlinePolicy(svc, targetType, name, doc); err != nil {
+			return applied, size, err
+		}
+		names = append(names, doc.name)
+	}
+
+	for _, old := range applied {
+		if containsString(names, old) {
+			continue
+		}
+		if keepRemoved {
+			// still applied, so it's deleted once it may go
+			names = append(names, old)
+			continue
+		}
+		if err := deleteInlinePolicy(svc, targetType, name, old); err != nil {
+			return names, size, err
+		}
+	}
+
+	return names, size, nil
+}
+
+// deleteInlinePolicies removes the given inline policies; inline policies must be gone before the entity can
+// be deleted
+func deleteInlinePolicies(svc iamiface.IAMAPI, targetType iamv1beta1.TargetType, name string, applied []string) error {
+	for _, policyName := range applied {
+		if err := deleteInlinePolicy(svc, targetType, name, policyName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putInlinePolicy(svc iamiface.IAMAPI, targetType iamv1beta1.TargetType, name string, doc inlinePolicyDocument) error {
+	var err error
+	switch targetType {
+	case iamv1beta1.RoleTargetType:
+		_, err = svc.PutRolePolicy(&awsiam.PutRolePolicyInput{
+			PolicyDocument: awssdk.String(doc.document),
+			PolicyName:     awssdk.String(doc.name),
+			RoleName:       awssdk.String(name),
+		})
+	case iamv1beta1.UserTargetType:
+		_, err = svc.PutUserPolicy(&awsiam.PutUserPolicyInput{
+			PolicyDocument: awssdk.String(doc.document),
+			PolicyName:     awssdk.String(doc.name),
+			UserName:       awssdk.String(name),
+		})
+	default:
+		err = fmt.Errorf("inline policies are not supported for type '%s'", targetType)
+	}
+	return err
+}
+
+func deleteInlinePolicy(svc iamiface.IAMAPI, targetType iamv1beta1.TargetType, name, policyName string) error {
+	var err error
+	switch targetType {
+	case iamv1beta1.RoleTargetType:
+		_, err = svc.DeleteRolePolicy(&awsiam.DeleteRolePolicyInput{
+			PolicyName: awssdk.String(policyName),
+			RoleName:   awssdk.String(name),
+		})
+	case iamv1beta1.UserTargetType:
+		_, err = svc.DeleteUserPolicy(&awsiam.DeleteUserPolicyInput{
+			PolicyName: awssdk.String(policyName),
+			UserName:   awssdk.String(name),
+		})
+	default:
+		return fmt.Errorf("inline policies are not supported for type '%s'", targetType)
+	}
+	if err != nil && !aws.IsNotExistsError(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/redradrat/cloud-objects/aws/iam"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// placeholder for the ARN of a referenced resource; IAM policy variables (e.g. ${aws:username}) are left alone
+var arnRefPlaceholder = regexp.MustCompile(`\$\{ref:([^}]*)\}`)
+
+// resolveARNReferences returns the ARNs of all resources referenced by the Policy, keyed by their alias. It fails as
+// long as a referenced resource is not yet created and in sync, so the Policy is retried until it is.
+func resolveARNReferences(ctx context.Context, c client.Client, policy *iamv1beta1.Policy) (map[string]string, error) {
+	arns := make(map[string]string)
+	for _, ref := range policy.Spec.ARNReferences {
+		if _, ok := arns[ref.Alias]; ok {
+			return nil, fmt.Errorf("ARN reference alias '%s' is not unique", ref.Alias)
+		}
+
+		var obj AWSObjectStatusResource
+		switch ref.Kind {
+		case "Role":
+			obj = &iamv1beta1.Role{}
+		case "Policy":
+			obj = &iamv1beta1.Policy{}
+		case "User":
+			obj = &iamv1beta1.User{}
+		case "Group":
+			obj = &iamv1beta1.Group{}
+		default:
+			return nil, fmt.Errorf("ARN reference '%s' has unsupported kind '%s'", ref.Alias, ref.Kind)
+		}
+
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = policy.Namespace
+		}
+		if err := c.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: namespace}, obj.RuntimeObject()); err != nil {
+			return nil, err
+		}
+		status := obj.GetStatus()
+		if status.ARN == "" || status.State != iamv1beta1.OkSyncState {
+			return nil, fmt.Errorf("referenced %s '%s/%s' is not ready yet", ref.Kind, namespace, ref.Name)
+		}
+		arns[ref.Alias] = status.ARN
+	}
+	return arns, nil
+}
+
+// policiesReferencing returns a function mapping a resource of the given kind to the Policies referencing its ARN, so
+// that they are updated as soon as it changes (e.g. once it is created or recreated with another ARN)
+func (r *PolicyReconciler) policiesReferencing(kind string) handler.MapFunc {
+	return func(o client.Object) []reconcile.Request {
+		policies := iamv1beta1.PolicyList{}
+		if err := r.List(context.Background(), &policies); err != nil {
+			r.Log.Error(err, "unable to list Policies referencing resource", "kind", kind, "name", o.GetName())
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, policy := range policies.Items {
+			for _, ref := range policy.Spec.ARNReferences {
+				namespace := ref.Namespace
+				if namespace == "" {
+					namespace = policy.Namespace
+				}
+				if ref.Kind == kind && ref.Name == o.GetName() && namespace == o.GetNamespace() {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace}})
+					break
+				}
+			}
+		}
+		return requests
+	}
+}
+
+// interpolatePolicyDocument replaces all ARN placeholders in the resources and condition values of the document.
+// Placeholders without a matching reference are rejected, as AWS would take them for a policy variable.
+func interpolatePolicyDocument(doc iam.PolicyDocument, arns map[string]string) (iam.PolicyDocument, error) {
+	var unresolved []string
+	interpolate := func(s string) string {
+		return arnRefPlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+			alias := arnRefPlaceholder.FindStringSubmatch(placeholder)[1]
+			arn, ok := arns[alias]
+			if !ok {
+				unresolved = append(unresolved, alias)
+				return placeholder
+			}
+			return arn
+		})
+	}
+
+	var statement []iam.StatementEntry
+	for _, entry := range doc.Statement {
+		// don't touch the slices and maps of the original document
+		var resources []string
+		for _, res := range entry.Resource {
+			resources = append(resources, interpolate(res))
+		}
+		entry.Resource = resources
+
+		conditions := make(map[string]map[string]string)
+		for op, comparison := range entry.Condition {
+			conditions[op] = make(map[string]string)
+			for key, value := range comparison {
+				conditions[op][key] = interpolate(value)
+			}
+		}
+		entry.Condition = conditions
+
+		statement = append(statement, entry)
+	}
+
+	if len(unresolved) > 0 {
+		sort.Strings(unresolved)
+		return doc, fmt.Errorf("policy document references unknown ARN aliases %v", unresolved)
+	}
+	doc.Statement = statement
+	return doc, nil
+}
+
+// resolvedARNs returns the resolved ARNs in order of their aliases
+func resolvedARNs(arns map[string]string) []string {
+	var aliases []string
+	for alias := range arns {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	var out []string
+	for _, alias := range aliases {
+		out = append(out, alias+"="+arns[alias])
+	}
+	return out
+}
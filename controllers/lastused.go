@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// flagUnusedRole sets the Unused condition, if the Role has not been used within the configured window, and emits a
+// Warning event when it becomes unused. Nothing is changed in AWS; unused Roles are only flagged.
+func (r *RoleReconciler) flagUnusedRole(ctx context.Context, role *iamv1beta1.Role, sw client.StatusWriter) error {
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
+	if err != nil {
+		return err
+	}
+	roleName := r.ResourcePrefix + role.RoleName()
+
+	out, err := iamsvc.GetRole(&awsiam.GetRoleInput{RoleName: awssdk.String(roleName)})
+	if err != nil {
+		return err
+	}
+
+	// a Role that has never been used counts from its creation
+	lastUsed := awssdk.TimeValue(out.Role.CreateDate)
+	lastUsedStatus := ""
+	if out.Role.RoleLastUsed != nil && out.Role.RoleLastUsed.LastUsedDate != nil {
+		lastUsed = awssdk.TimeValue(out.Role.RoleLastUsed.LastUsedDate)
+		lastUsedStatus = lastUsed.Format(time.RFC822Z)
+	}
+
+	before := role.Status.DeepCopy()
+	role.Status.LastUsed = lastUsedStatus
+
+	condition := metav1.Condition{
+		Type:               iamv1beta1.UnusedCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: role.Generation,
+		Reason:             "Used",
+		Message:            fmt.Sprintf("Role has been used within the last %s", r.UnusedWindow),
+	}
+	if time.Since(lastUsed) > r.UnusedWindow {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "NotUsed"
+		condition.Message = fmt.Sprintf("Role has not been used since %s", lastUsed.Format(time.RFC822Z))
+		if !meta.IsStatusConditionTrue(role.Status.Conditions, iamv1beta1.UnusedCondition) {
+			r.Notifier.Notify(role, v1.EventTypeWarning, "RoleUnused", condition.Message)
+		}
+	}
+	meta.SetStatusCondition(&role.Status.Conditions, condition)
+
+	// don't write anything if nothing changed; otherwise every status write would trigger the next reconcile
+	if reflect.DeepEqual(before, &role.Status) {
+		return nil
+	}
+	return sw.Update(ctx, role)
+}
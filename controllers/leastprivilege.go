@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	v1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+const (
+	// how often the services last accessed via a Policy are checked; AWS updates this data only every few hours
+	leastPrivilegeCheckInterval = 24 * time.Hour
+	// how long to wait before polling a running last accessed job again
+	leastPrivilegePollInterval = 30 * time.Second
+)
+
+// suggestLeastPrivilege checks, which of the services granted by the Policy have not been accessed within the AWS
+// tracking period (based on CloudTrail), and suggests to remove them via status and event. Nothing is enforced. The
+// check runs as IAM job, so it takes several reconciles to complete.
+func (r *PolicyReconciler) suggestLeastPrivilege(ctx context.Context, policy *iamv1beta1.Policy, sw client.StatusWriter) (ctrl.Result, error) {
+	// a Policy that hasn't been in use for a while yet, trivially hasn't accessed anything
+	if inUse := time.Since(policy.ObjectMeta.CreationTimestamp.Time); inUse < r.SuggestLeastPrivilegeAfter {
+		return ctrl.Result{RequeueAfter: r.SuggestLeastPrivilegeAfter - inUse}, nil
+	}
+	if checked, err := time.Parse(time.RFC3339, policy.Status.LeastPrivilegeCheckedAt); err == nil && policy.Status.LastAccessedJobID == "" {
+		if since := time.Since(checked); since < leastPrivilegeCheckInterval {
+			return ctrl.Result{RequeueAfter: leastPrivilegeCheckInterval - since}, nil
+		}
+	}
+
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if policy.Status.LastAccessedJobID == "" {
+		out, err := iamsvc.GenerateServiceLastAccessedDetails(&awsiam.GenerateServiceLastAccessedDetailsInput{
+			Arn: awssdk.String(policy.Status.ARN),
+		})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		policy.Status.LastAccessedJobID = awssdk.StringValue(out.JobId)
+		return ctrl.Result{RequeueAfter: leastPrivilegePollInterval}, sw.Update(ctx, policy)
+	}
+
+	var unused []string
+	input := &awsiam.GetServiceLastAccessedDetailsInput{JobId: awssdk.String(policy.Status.LastAccessedJobID)}
+	for {
+		out, err := iamsvc.GetServiceLastAccessedDetails(input)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		switch awssdk.StringValue(out.JobStatus) {
+		case awsiam.JobStatusTypeInProgress:
+			return ctrl.Result{RequeueAfter: leastPrivilegePollInterval}, nil
+		case awsiam.JobStatusTypeFailed:
+			// start over with the next check
+			message := "unknown error"
+			if out.Error != nil {
+				message = awssdk.StringValue(out.Error.Message)
+			}
+			r.Log.Info(fmt.Sprintf("last accessed job for Policy '%s' failed: %s", policy.Status.ARN, message))
+			policy.Status.LastAccessedJobID = ""
+			policy.Status.LeastPrivilegeCheckedAt = time.Now().Format(time.RFC3339)
+			return ctrl.Result{RequeueAfter: leastPrivilegeCheckInterval}, sw.Update(ctx, policy)
+		}
+		for _, service := range out.ServicesLastAccessed {
+			if service.LastAuthenticated == nil {
+				unused = append(unused, awssdk.StringValue(service.ServiceNamespace))
+			}
+		}
+		if !awssdk.BoolValue(out.IsTruncated) {
+			break
+		}
+		input.Marker = out.Marker
+	}
+	sort.Strings(unused)
+
+	if len(unused) > 0 && !reflect.DeepEqual(unused, policy.Status.UnusedServices) {
+		r.Notifier.Notify(policy, v1.EventTypeNormal, "LeastPrivilegeSuggestion", fmt.Sprintf("Policy '%s' grants access to services not accessed within the tracking period: %s; consider removing their actions", policy.Status.ARN, strings.Join(unused, ", ")))
+	}
+	policy.Status.UnusedServices = unused
+	policy.Status.LastAccessedJobID = ""
+	policy.Status.LeastPrivilegeCheckedAt = time.Now().Format(time.RFC3339)
+	return ctrl.Result{RequeueAfter: leastPrivilegeCheckInterval}, sw.Update(ctx, policy)
+}
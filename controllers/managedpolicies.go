@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/redradrat/cloud-objects/aws"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// userManagedPolicyArns resolves the managed policies of the User with the given ARN to their sorted ARNs. Every
+// entry is resolved as a PolicyAttachment to the User would be, so the same rules apply to references. The ARN is
+// passed in, as a User just created doesn't have it in the status of its cached copy yet.
+func userManagedPolicyArns(ctx context.Context, c client.Client, user *iamv1beta1.User, userArn awsarn.ARN) ([]string, error) {
+	var arns []string
+	for _, ref := range user.Spec.ManagedPolicyArns {
+		attachment := &iamv1beta1.PolicyAttachment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      user.Name,
+				Namespace: user.Namespace,
+			},
+			Spec: iamv1beta1.PolicyAttachmentSpec{
+				PolicyReference: ref.PolicyReference,
+				ExternalPolicy:  ref.ExternalPolicy,
+				TargetReference: iamv1beta1.TargetReference{
+					Type:      iamv1beta1.UserTargetType,
+					Name:      user.Name,
+					Namespace: user.Namespace,
+				},
+			},
+		}
+		policyArn, err := getPolicyAttachmentPolicyARN(ctx, attachment, c)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPolicyAttachmentAccounts(policyArn, userArn); err != nil {
+			return nil, err
+		}
+		if !containsString(arns, policyArn.String()) {
+			arns = append(arns, policyArn.String())
+		}
+	}
+	sort.Strings(arns)
+	return arns, nil
+}
+
+// heldUserPolicyArns returns the ARNs of the policies, that PolicyAttachments and PolicyAttachmentSets attach to the
+// User besides its managed policies. AWS attaches a policy only once, so these must not be detached along with them.
+func heldUserPolicyArns(ctx context.Context, c client.Client, user *iamv1beta1.User) ([]string, error) {
+	attachments := iamv1beta1.PolicyAttachmentList{}
+	if err := c.List(ctx, &attachments); err != nil {
+		return nil, err
+	}
+
+	var arns []string
+	for _, att := range attachments.Items {
+		ref := att.Spec.TargetReference
+		if ref.Type != iamv1beta1.UserTargetType || ref.Name != user.Name || ref.Namespace != user.Namespace {
+			continue
+		}
+		if !att.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if att.Spec.ExternalPolicy.ARN != "" {
+			arns = append(arns, att.Spec.ExternalPolicy.ARN)
+			continue
+		}
+		policy := iamv1beta1.Policy{}
+		polRef := att.Spec.PolicyReference
+		if err := c.Get(ctx, client.ObjectKey{Name: polRef.Name, Namespace: polRef.Namespace}, &policy); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if policy.Status.ARN != "" {
+			arns = append(arns, policy.Status.ARN)
+		}
+	}
+
+	sets, err := setsAttachingTo(ctx, c, iamv1beta1.UserTargetType, user.Namespace, user.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range sets {
+		if !set.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for _, attached := range set.Status.Attachments {
+			if attached.TargetType == iamv1beta1.UserTargetType && attached.TargetARN == user.Status.ARN && attached.State == iamv1beta1.OkSyncState {
+				arns = append(arns, attached.PolicyARN)
+			}
+		}
+	}
+	return arns, nil
+}
+
+// reconcileUserPolicies attaches the given managed policies to the named User and detaches the previously attached
+// ones that are not given anymore, unless they are held by a PolicyAttachment or PolicyAttachmentSet as well. It
+// returns the ARNs of the now attached policies.
+func reconcileUserPolicies(svc iamiface.IAMAPI, userName string, policyArns []string, attached []string, held []string) ([]string, error) {
+	var arns []string
+	for _, policyArn := range policyArns {
+		if _, err := svc.AttachUserPolicy(&awsiam.AttachUserPolicyInput{
+			PolicyArn: awssdk.String(policyArn),
+			UserName:  awssdk.String(userName),
+		}); err != nil {
+			// the previously attached ones are still attached
+			return attachedPolicies(arns, attached), err
+		}
+		arns = append(arns, policyArn)
+	}
+
+	for _, old := range attached {
+		if containsString(arns, old) || containsString(held, old) {
+			continue
+		}
+		if err := detachUserPolicies(svc, userName, []string{old}); err != nil {
+			return attachedPolicies(arns, attached), err
+		}
+	}
+
+	return arns, nil
+}
+
+// attachedPolicies returns the union of the newly and the previously attached policies, for a partial reconcile
+func attachedPolicies(arns []string, attached []string) []string {
+	for _, policyArn := range attached {
+		if !containsString(arns, policyArn) {
+			arns = append(arns, policyArn)
+		}
+	}
+	sort.Strings(arns)
+	return arns
+}
+
+// detachUserPolicies detaches the given policies; attached policies must be gone before the User can be deleted
+func detachUserPolicies(svc iamiface.IAMAPI, userName string, policyArns []string) error {
+	for _, policyArn := range policyArns {
+		if _, err := svc.DetachUserPolicy(&awsiam.DetachUserPolicyInput{
+			PolicyArn: awssdk.String(policyArn),
+			UserName:  awssdk.String(userName),
+		}); err != nil && !aws.IsNotExistsError(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/redradrat/cloud-objects/aws"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// Group membership can be declared on either side: in group.spec.users or in user.spec.groups. Declaring the same
+// membership on both sides is rejected as conflict, so there is always exactly one owner of a membership.
+
+// groupRefKey returns the namespaced name of the referenced Group, defaulting to the namespace of the User
+func groupRefKey(user *iamv1beta1.User, ref v1.ObjectReference) client.ObjectKey {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = user.Namespace
+	}
+	return client.ObjectKey{Name: ref.Name, Namespace: namespace}
+}
+
+// userDeclaresGroup checks whether the User lists the given Group in its spec
+func userDeclaresGroup(user *iamv1beta1.User, group *iamv1beta1.Group) bool {
+	for _, ref := range user.Spec.Groups {
+		if groupRefKey(user, ref) == (client.ObjectKey{Name: group.Name, Namespace: group.Namespace}) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupDeclaresUser checks whether the Group lists the given User in its spec
+func groupDeclaresUser(group *iamv1beta1.Group, user *iamv1beta1.User) bool {
+	for _, ref := range group.Spec.Users {
+		if ref.Name == user.Name && ref.Namespace == user.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileGroupMemberships adds the User to the Groups listed in its spec, and removes it from the ones it has been
+// added to before, but which are not listed anymore
+func (r *UserReconciler) reconcileGroupMemberships(ctx context.Context, svc iamiface.IAMAPI, user *iamv1beta1.User, userName string) error {
+	var groupNames []string
+	for _, ref := range user.Spec.Groups {
+		var group iamv1beta1.Group
+		key := groupRefKey(user, ref)
+		if err := r.Get(ctx, key, &group); err != nil {
+			return err
+		}
+		if groupDeclaresUser(&group, user) {
+			return fmt.Errorf("membership in Group '%s/%s' is declared by both the User and the Group", key.Namespace, key.Name)
+		}
+		if group.Status.ARN == "" {
+			return fmt.Errorf("referenced group resource '%s/%s' has not yet been created", key.Namespace, key.Name)
+		}
+		parsedArn, err := aws.ARNify(group.Status.ARN)
+		if err != nil {
+			return fmt.Errorf("ARN in referenced Group status is not valid/parsable")
+		}
+		resource := parsedArn[len(parsedArn)-1].Resource
+		groupNames = append(groupNames, resource[strings.LastIndex(resource, "/")+1:])
+	}
+
+	for _, groupName := range groupNames {
+		if _, err := svc.AddUserToGroup(&awsiam.AddUserToGroupInput{
+			GroupName: awssdk.String(groupName),
+			UserName:  awssdk.String(userName),
+		}); err != nil {
+			return err
+		}
+		if !containsString(user.Status.Groups, groupName) {
+			user.Status.Groups = append(user.Status.Groups, groupName)
+		}
+	}
+
+	for _, groupName := range user.Status.Groups {
+		if containsString(groupNames, groupName) {
+			continue
+		}
+		if err := removeUserFromGroup(svc, userName, groupName); err != nil {
+			return err
+		}
+		user.Status.Groups = removeString(user.Status.Groups, groupName)
+	}
+
+	return nil
+}
+
+func removeUserFromGroup(svc iamiface.IAMAPI, userName, groupName string) error {
+	_, err := svc.RemoveUserFromGroup(&awsiam.RemoveUserFromGroupInput{
+		GroupName: awssdk.String(groupName),
+		UserName:  awssdk.String(userName),
+	})
+	if err != nil && !aws.IsNotExistsError(err) {
+		return err
+	}
+	return nil
+}
+
+// usersDeclaringGroup returns the ARNs of all created Users, that list the given Group in their spec. As Groups
+// are recreated on change, they have to add these Users again.
+func usersDeclaringGroup(ctx context.Context, c client.Client, group *iamv1beta1.Group) ([]awsarn.ARN, error) {
+	users := iamv1beta1.UserList{}
+	if err := c.List(ctx, &users); err != nil {
+		return nil, err
+	}
+	var arns []awsarn.ARN
+	for _, user := range users.Items {
+		if user.Status.ARN == "" || !userDeclaresGroup(&user, group) || groupDeclaresUser(group, &user) {
+			continue
+		}
+		parsedArn, err := aws.ARNify(user.Status.ARN)
+		if err != nil {
+			return nil, fmt.Errorf("ARN in User '%s/%s' status is not valid/parsable", user.Namespace, user.Name)
+		}
+		arns = append(arns, parsedArn[len(parsedArn)-1])
+	}
+	return arns, nil
+}
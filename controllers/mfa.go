@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"fmt"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+const (
+	mfaConditionOperator iamv1beta1.PolicyStatementConditionOperator = "Bool"
+	mfaConditionKey      iamv1beta1.PolicyStatementConditionKey      = "aws:MultiFactorAuthPresent"
+)
+
+// injectMFACondition returns a copy of the statement, where every entry allowing an AWS principal (i.e. users and
+// roles of an account, as opposed to services or federated identities) requires MFA. Entries that explicitly allow
+// sessions without MFA are rejected.
+func injectMFACondition(statement iamv1beta1.AssumeRolePolicyStatement) (iamv1beta1.AssumeRolePolicyStatement, error) {
+	var out iamv1beta1.AssumeRolePolicyStatement
+	injected := false
+	for i, entry := range statement {
+		if _, ok := entry.Principal["AWS"]; !ok || entry.Effect != "Allow" {
+			out = append(out, entry)
+			continue
+		}
+
+		// copy the conditions, we don't want to change the spec they come from
+		merged := iamv1beta1.PolicyStatementCondition{}
+		for op, comparison := range entry.Conditions {
+			merged[op] = iamv1beta1.PolicyStatementConditionComparison{}
+			for key, value := range comparison {
+				merged[op][key] = value
+			}
+		}
+		if merged[mfaConditionOperator] == nil {
+			merged[mfaConditionOperator] = iamv1beta1.PolicyStatementConditionComparison{}
+		}
+		if existing, ok := merged[mfaConditionOperator][mfaConditionKey]; ok && existing != "true" {
+			return nil, fmt.Errorf("requireMFA conflicts with the '%s' condition on '%s' of trust policy statement %d", mfaConditionOperator, mfaConditionKey, i)
+		}
+		merged[mfaConditionOperator][mfaConditionKey] = "true"
+
+		entry.Conditions = merged
+		out = append(out, entry)
+		injected = true
+	}
+
+	if !injected {
+		return nil, fmt.Errorf("requireMFA is given, but no trust policy statement allows an AWS principal")
+	}
+	return out, nil
+}
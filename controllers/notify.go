@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"strings"
+
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Notifier emits events about the changes the operator made in AWS. Annotations of the object, that start with the
+// configured prefix, are copied into the events, so downstream automation can correlate the IAM changes with e.g.
+// ticket IDs or change request numbers. A nil Notifier emits nothing.
+type Notifier struct {
+	recorder         record.EventRecorder
+	annotationPrefix string
+}
+
+func NewNotifier(recorder record.EventRecorder, annotationPrefix string) *Notifier {
+	return &Notifier{recorder: recorder, annotationPrefix: annotationPrefix}
+}
+
+// Notify emits an event of the given type for the object, including the annotations matching the prefix
+func (n *Notifier) Notify(obj client.Object, eventtype, reason, message string) {
+	if n == nil || n.recorder == nil {
+		return
+	}
+	n.recorder.AnnotatedEventf(obj, n.correlationAnnotations(obj), eventtype, reason, "%s", message)
+}
+
+// correlationAnnotations returns the annotations of the object that start with the configured prefix. Without a
+// prefix we don't copy anything, as annotations can contain about everything.
+func (n *Notifier) correlationAnnotations(obj client.Object) map[string]string {
+	if n.annotationPrefix == "" {
+		return nil
+	}
+	annotations := make(map[string]string)
+	for k, v := range obj.GetAnnotations() {
+		if strings.HasPrefix(k, n.annotationPrefix) {
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
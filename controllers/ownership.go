@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// tag holding the name of the Kubernetes resource at the top of the owner chain of a resource
+const owningAppTagKey = "owning-app"
+
+// placeholder for the name of the owning application in the owner tag format
+const owningAppPlaceholder = "${app}"
+
+// DefaultOwnerTagFormat is the format of the owner tag value, if none is configured: the bare application name
+const DefaultOwnerTagFormat = owningAppPlaceholder
+
+// how many owners we follow at most; owner references might form a cycle
+const maxOwnerChainDepth = 10
+
+// owningApp walks up the controller owner references of the object and returns the name of the top-most owner, or
+// an empty string if the object has no owner. Owners we can't read (missing or not permitted) end the walk.
+func owningApp(ctx context.Context, c client.Reader, obj client.Object) string {
+	app := ""
+	namespace := obj.GetNamespace()
+	ref := metav1.GetControllerOf(obj)
+	for i := 0; ref != nil && i < maxOwnerChainDepth; i++ {
+		app = ref.Name
+
+		owner := &unstructured.Unstructured{}
+		owner.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+		if err := c.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: namespace}, owner); err != nil {
+			break
+		}
+		ref = metav1.GetControllerOf(owner)
+	}
+	return app
+}
+
+// ownerTagValue renders the value of the owner tag for the given application according to the configured format. Next
+// to ${app}, the format can use the placeholders of descriptions (e.g. ${namespace}), to match the tagging conventions
+// of the organisation (e.g. "my-cluster/${namespace}/${app}").
+func ownerTagValue(format, app string, obj metav1.Object) (string, error) {
+	if format == "" {
+		format = DefaultOwnerTagFormat
+	}
+	return expandTemplate(strings.Replace(format, owningAppPlaceholder, app, -1), obj)
+}
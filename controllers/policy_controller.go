@@ -19,15 +19,19 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	awsiam "github.com/aws/aws-sdk-go/service/iam"
 
 	"github.com/go-logr/logr"
 	"github.com/redradrat/cloud-objects/aws"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/redradrat/cloud-objects/aws/iam"
 
@@ -41,6 +45,18 @@ type PolicyReconciler struct {
 	Region         string
 	Scheme         *runtime.Scheme
 	ResourcePrefix string
+	Debouncer      *Debouncer
+	Notifier       *Notifier
+	ReadOnly       bool
+	CreateOnly     bool
+	// MaxManagedEntities caps the number of AWS entities the operator creates; 0 disables the cap
+	MaxManagedEntities int
+	// ValidatePolicies runs policy documents through IAM Access Analyzer before they are applied
+	ValidatePolicies bool
+	// StrictPolicyValidation refuses policy documents for which Access Analyzer reports errors
+	StrictPolicyValidation bool
+	// SuggestLeastPrivilegeAfter suggests to remove services not accessed via policies in use for this long; 0 disables it
+	SuggestLeastPrivilegeAfter time.Duration
 }
 
 // +kubebuilder:rbac:groups=aws-iam.redradrat.xyz,resources=policies,verbs=get;list;watch;create;update;patch;delete
@@ -54,16 +70,80 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	err := r.Get(ctx, req.NamespacedName, &policy)
 	if err != nil {
 		log.V(1).Info("unable to fetch Policy")
+		r.Debouncer.Forget(req.NamespacedName)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	// return if only status/metadata updated
-	if policy.Status.ObservedGeneration == policy.ObjectMeta.Generation && policy.Status.State == iamv1beta1.OkSyncState {
+	sw := newStatusPatcher(r.Status(), &policy)
+
+	// the namespace may require scoped resources at any time, so this is checked even if the Policy didn't change
+	if policy.ObjectMeta.DeletionTimestamp.IsZero() {
+		if err := checkScopedResources(ctx, r.Client, &policy); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &policy, err, sw)
+		}
+	}
+
+	// return if only status/metadata updated; annotations used by the description and the ARNs of referenced resources
+	// are checked via the spec hash below
+	templated := templateAnnotations(&policy, policy.Spec.Description)
+	if len(templated) == 0 && len(policy.Spec.ARNReferences) == 0 && policy.Status.ObservedGeneration == policy.ObjectMeta.Generation && policy.Status.State == iamv1beta1.OkSyncState {
+		if r.SuggestLeastPrivilegeAfter > 0 && policy.ObjectMeta.DeletionTimestamp.IsZero() {
+			return r.suggestLeastPrivilege(ctx, &policy, sw)
+		}
 		return ctrl.Result{}, nil
 	}
 
+	// resolve the ARNs of referenced resources into the document; for deletion we don't need them
+	doc := policy.Marshal()
+	var refArns map[string]string
+	if policy.ObjectMeta.DeletionTimestamp.IsZero() {
+		if refArns, err = resolveARNReferences(ctx, r.Client, &policy); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &policy, err, sw)
+		}
+		if doc, err = interpolatePolicyDocument(doc, refArns); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &policy, err, sw)
+		}
+		if err := checkUniqueSids(doc); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &policy, err, sw)
+		}
+		doc = canonicalPolicyDocument(doc)
+	}
+
+	// the resolved ARNs and the annotations used by templates are part of what we apply, so they go into the hash as well
+	hash, err := specHash(policy.Spec, append(resolvedARNs(refArns), templated...)...)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &policy, err, sw)
+	}
+
+	// return if the spec is identical to the last applied one (e.g. an unchanged manifest has been re-applied)
+	if policy.ObjectMeta.DeletionTimestamp.IsZero() && lastAppliedSpecMatches(&policy, hash) {
+		if r.SuggestLeastPrivilegeAfter > 0 {
+			return r.suggestLeastPrivilege(ctx, &policy, sw)
+		}
+		return ctrl.Result{}, observeGeneration(ctx, &policy, sw)
+	}
+
+	// wait for rapid consecutive spec changes to settle, before we talk to AWS
+	if policy.ObjectMeta.DeletionTimestamp.IsZero() {
+		if wait := r.Debouncer.Wait(req.NamespacedName, policy.ObjectMeta.Generation); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	// don't act before the gate is open; deletions are never held back
+	if policy.ObjectMeta.DeletionTimestamp.IsZero() {
+		reason, err := gateClosed(ctx, r.Client, policy.Spec.Gate, policy.Namespace)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &policy, err, sw)
+		}
+		if reason != "" {
+			log.Info(reason)
+			return ctrl.Result{RequeueAfter: gateRetryInterval}, nil
+		}
+	}
+
 	// Get our actual IAM Service to communicate with AWS; we don't need to continue without it
-	iamsvc, err := IAMService(r.Region)
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -71,6 +151,12 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	// the finalizer for deleting the actual aws resources
 	policiesFinalizer := "policy.aws-iam.redradrat.xyz"
 
+	// the description doesn't matter for the deletion
+	description, err := expandTemplate(policy.Spec.Description, &policy)
+	if err != nil && policy.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, errWithStatus(ctx, &policy, err, sw)
+	}
+
 	// now let's instantiate our PolicyInstance
 	var ins *iam.PolicyInstance
 	policyName := r.ResourcePrefix + policy.PolicyName()
@@ -79,9 +165,9 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		if err != nil {
 			return ctrl.Result{}, fmt.Errorf("ARN in Role status is not valid/parsable")
 		}
-		ins = iam.NewExistingPolicyInstance(policyName, policy.Spec.Description, policy.Marshal(), parsedArn[len(parsedArn)-1])
+		ins = iam.NewExistingPolicyInstance(policyName, description, doc, parsedArn[len(parsedArn)-1])
 	} else {
-		ins = iam.NewPolicyInstance(policyName, policy.Spec.Description, policy.Marshal())
+		ins = iam.NewPolicyInstance(policyName, description, doc)
 	}
 
 	cleanupFunc := policyCleanup(r, ctx, &policy)
@@ -95,20 +181,28 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			policy.ObjectMeta.Finalizers = append(policy.ObjectMeta.Finalizers, policiesFinalizer)
 			if err := r.Update(context.Background(), &policy); err != nil {
 				log.Error(err, "unable to register finalizer for Policy")
-				return ctrl.Result{}, errWithStatus(ctx, &policy, err, r.Status())
+				return ctrl.Result{}, errWithStatus(ctx, &policy, err, sw)
 			}
 		}
 	} else {
 		if containsString(policy.ObjectMeta.Finalizers, policiesFinalizer) {
 			// our finalizer is present, so lets handle any external dependency
+			if r.ReadOnly {
+				return ctrl.Result{}, skipWithStatus(ctx, &policy, fmt.Sprintf("read-only mode: would delete Policy '%s'", policyName), sw)
+			}
 
-			// delete the actual AWS Object and pass the cleanup function
-			statusWriter, err := DeleteAWSObject(iamsvc, ins, cleanupFunc)
-			statusWriter(ctx, ins, &policy, r.Status(), log)
-			if err != nil {
-				// we had an error during AWS Object deletion... so we return here to retry
-				log.Error(err, "unable to delete Policy")
-				return ctrl.Result{}, err
+			if r.CreateOnly {
+				// leave the AWS object untouched, but let the resource go
+				log.Info(fmt.Sprintf("create-only mode: leaving Policy '%s' in AWS", policyName))
+			} else {
+				// delete the actual AWS Object and pass the cleanup function
+				statusWriter, err := DeleteAWSObject(iamsvc, ins, cleanupFunc)
+				statusWriter(ctx, ins, &policy, sw, log)
+				if err != nil {
+					// we had an error during AWS Object deletion... so we return here to retry
+					log.Error(err, "unable to delete Policy")
+					return ctrl.Result{}, err
+				}
 			}
 
 			// remove our finalizer from the list and update it.
@@ -117,6 +211,9 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 				log.Error(err, "unable to remove finalizer from Policy")
 				return ctrl.Result{}, err
 			}
+			if !r.CreateOnly {
+				r.Notifier.Notify(&policy, v1.EventTypeNormal, "Deleted", fmt.Sprintf("Deleted Policy '%s'", policy.Status.ARN))
+			}
 		}
 
 		// Stop reconciliation as the item is being deleted
@@ -125,40 +222,96 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 	// RECONCILE THE RESOURCE
 
+	if r.ValidatePolicies || r.StrictPolicyValidation {
+		if err := r.checkPolicyValidation(&policy, doc); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &policy, err, sw)
+		}
+	}
+
+	if r.ReadOnly {
+		action := "create"
+		if policy.Status.ARN != "" {
+			action = "update"
+		}
+		return ctrl.Result{}, skipWithStatus(ctx, &policy, fmt.Sprintf("read-only mode: would %s Policy '%s'", action, policyName), sw)
+	}
+
+	if r.CreateOnly && policy.Status.ARN != "" {
+		return ctrl.Result{}, skipWithStatus(ctx, &policy, fmt.Sprintf("create-only mode: not updating existing Policy '%s'", policyName), sw)
+	}
+
+	if policy.Status.ARN == "" {
+		if err := checkManagedEntityCap(ctx, r.Client, r.MaxManagedEntities); err != nil {
+			r.Notifier.Notify(&policy, v1.EventTypeWarning, "ManagedEntityCapReached", err.Error())
+			return ctrl.Result{}, errWithStatus(ctx, &policy, err, sw)
+		}
+	}
+
 	// if there is already an ARN in our status, then we update the object
+	unchanged := false
 	statusWriter, err := CreateAWSObject(iamsvc, ins, DoNothingPreFunc)
-	statusWriter(ctx, ins, &policy, r.Status(), log)
+	statusWriter(ctx, ins, &policy, sw, log)
 	if err != nil {
-		// If already exists, we just update the status
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == awsiam.ErrCodeEntityAlreadyExistsException {
-				// Update the actual AWS Object and pass the DoNothing function
-				statusWriter, err := UpdateAWSObject(iamsvc, ins, DoNothingPreFunc)
-				statusWriter(ctx, ins, &policy, r.Status(), log)
-				if err != nil {
-					// we had an error during AWS Object update... so we return here to retry
-					log.Error(err, "error while updating Policy during reconciliation")
-					return ctrl.Result{}, err
-				}
+		// If already exists, we update the existing policy instead
+		aerr, ok := err.(awserr.Error)
+		if !ok || aerr.Code() != awsiam.ErrCodeEntityAlreadyExistsException {
+			log.Error(err, "error while creating Policy during reconciliation")
+			return ctrl.Result{}, err
+		}
+		// a spec that only differs in formatting must not create another policy version
+		if policy.Status.ARN != "" {
+			if unchanged, err = storedPolicyDocumentMatches(iamsvc, policy.Status.ARN, doc); err != nil {
+				log.Error(err, "unable to compare Policy with its stored document; updating it")
 			}
 		}
-		log.Error(err, "error while creating Policy during reconciliation")
+		if unchanged {
+			SuccessStatusUpdater()(ctx, ins, &policy, sw, log)
+		} else {
+			// Update the actual AWS Object and pass the DoNothing function
+			statusWriter, err := UpdateAWSObject(iamsvc, ins, DoNothingPreFunc)
+			statusWriter(ctx, ins, &policy, sw, log)
+			if err != nil {
+				// we had an error during AWS Object update... so we return here to retry
+				log.Error(err, "error while updating Policy during reconciliation")
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	// the policy has been changed in AWS; the version is nice to have, so we don't fail without it
+	if !unchanged {
+		if err := recordPolicyChange(iamsvc, &policy, sw.generation); err != nil {
+			log.Error(err, "unable to read the default version of Policy")
+		}
+	}
+	policy.Status.ObservedGeneration = sw.generation
+	if err := sw.Update(ctx, &policy); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	policy.Status.ObservedGeneration = policy.ObjectMeta.Generation
-	if err := r.Status().Update(ctx, &policy); err != nil {
+	if err := storeLastAppliedSpecHash(ctx, r.Client, &policy, hash); err != nil {
+		log.Error(err, "unable to store last applied spec hash for Policy")
 		return ctrl.Result{}, err
 	}
+	r.Notifier.Notify(&policy, v1.EventTypeNormal, "Reconciled", fmt.Sprintf("Reconciled Policy '%s'", policy.Status.ARN))
 
 	log.Info(fmt.Sprintf("Created Policy '%s'", policy.Status.ARN))
 
+	// come back to check the accessed services, once the Policy has been in use for a while
+	if r.SuggestLeastPrivilegeAfter > 0 {
+		return ctrl.Result{RequeueAfter: r.SuggestLeastPrivilegeAfter}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
 func (r *PolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&iamv1beta1.Policy{}).
+		Watches(&source.Kind{Type: &iamv1beta1.Role{}}, handler.EnqueueRequestsFromMapFunc(r.policiesReferencing("Role"))).
+		Watches(&source.Kind{Type: &iamv1beta1.Policy{}}, handler.EnqueueRequestsFromMapFunc(r.policiesReferencing("Policy"))).
+		Watches(&source.Kind{Type: &iamv1beta1.User{}}, handler.EnqueueRequestsFromMapFunc(r.policiesReferencing("User"))).
+		Watches(&source.Kind{Type: &iamv1beta1.Group{}}, handler.EnqueueRequestsFromMapFunc(r.policiesReferencing("Group"))).
+		Watches(&source.Kind{Type: &v1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.policiesForNamespace)).
 		Complete(r)
 }
 
@@ -170,11 +323,32 @@ func policyCleanup(r *PolicyReconciler, ctx context.Context, policy *iamv1beta1.
 			return err
 		}
 		for _, att := range attachments.Items {
-			if att.Spec.PolicyReference.Name == policy.Name && att.Spec.PolicyReference.Namespace == policy.Namespace {
+			// attachments detach a Policy being deleted by themselves; we only wait for them to do so
+			if att.Spec.PolicyReference.Name == policy.Name && att.Spec.PolicyReference.Namespace == policy.Namespace && att.Status.ARN != "" {
 				err := fmt.Errorf("cannot delete policy due to existing PolicyAttachment '%s/%s'", att.Name, att.Namespace)
 				return err
 			}
 		}
+		// sets don't detach a Policy being deleted by themselves; it has to be removed from them
+		sets, err := setsAttachingPolicy(ctx, r.Client, policy)
+		if err != nil {
+			return err
+		}
+		if len(sets) != 0 {
+			return fmt.Errorf("cannot delete policy due to existing PolicyAttachmentSet '%s/%s'", sets[0].Name, sets[0].Namespace)
+		}
+		// neither do Users, which attach it via their managed policies
+		users := iamv1beta1.UserList{}
+		if err := r.List(ctx, &users); err != nil {
+			return err
+		}
+		for _, user := range users.Items {
+			for _, ref := range user.Spec.ManagedPolicyArns {
+				if ref.PolicyReference.Name == policy.Name && ref.PolicyReference.Namespace == policy.Namespace {
+					return fmt.Errorf("cannot delete policy due to User '%s/%s' referencing it in managedPolicyArns", user.Name, user.Namespace)
+				}
+			}
+		}
 		return nil
 	}
 }
@@ -0,0 +1,446 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// newTestPolicy returns a Policy allowing to read from S3
+func newTestPolicy() *iamv1beta1.Policy {
+	return &iamv1beta1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: uniqueName("policy"), Namespace: "default"},
+		Spec: iamv1beta1.PolicySpec{
+			Description: "before",
+			Statement: iamv1beta1.PolicyStatement{{
+				Effect:    "Allow",
+				Actions:   []string{"s3:GetObject"},
+				Resources: []string{"*"},
+			}},
+		},
+	}
+}
+
+var _ = Describe("Policy controller", func() {
+	var (
+		ctx        context.Context
+		fake       *fakeIAM
+		reconciler *PolicyReconciler
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = installFakeIAM()
+		reconciler = &PolicyReconciler{
+			Client: k8sClient,
+			Log:    ctrl.Log.WithName("controllers").WithName("Policy"),
+			Scheme: k8sClient.Scheme(),
+			Region: "eu-west-1",
+		}
+	})
+
+	AfterEach(func() {
+		uninstallFakeIAM()
+	})
+
+	Context("when the spec changes while the Policy is created in AWS", func() {
+		It("doesn't mark the new generation as observed, and applies it next time", func() {
+			policy := newTestPolicy()
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+			applied := policy.Generation
+
+			policyArn := "arn:aws:iam::123456789012:policy/" + policy.Name
+			fake.respond("GetPolicy", func(r *request.Request) {
+				r.Data.(*awsiam.GetPolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn), DefaultVersionId: awssdk.String("v1")}
+			})
+			changed := false
+			fake.respond("CreatePolicy", func(r *request.Request) {
+				r.Data.(*awsiam.CreatePolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn)}
+				if changed {
+					return
+				}
+				changed = true
+				current := &iamv1beta1.Policy{}
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), current)).To(Succeed())
+				current.Spec.Description = "after"
+				// the API server bumps the generation on its own
+				current.Generation++
+				Expect(k8sClient.Update(ctx, current)).To(Succeed())
+			})
+
+			_, err := reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+			Expect(policy.Spec.Description).To(Equal("after"))
+			Expect(policy.Status.State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(policy.Status.ObservedGeneration).To(Equal(applied))
+			Expect(policy.Generation).To(BeNumerically(">", applied))
+
+			calls := len(fake.Calls())
+			_, err = reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(fake.Calls())).To(BeNumerically(">", calls))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+			Expect(policy.Status.ObservedGeneration).To(Equal(policy.Generation))
+		})
+	})
+
+	Context("when a referenced resource changes its ARN", func() {
+		It("is enqueued, and updates the document with the new ARN", func() {
+			role := newTestRole()
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+			})
+
+			policy := newTestPolicy()
+			policy.Spec.Statement[0].Actions = []string{"sts:AssumeRole"}
+			policy.Spec.Statement[0].Resources = []string{"${ref:target}"}
+			policy.Spec.ARNReferences = []iamv1beta1.ARNReference{{Alias: "target", Kind: "Role", Name: role.Name}}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+			policyArn := "arn:aws:iam::123456789012:policy/" + policy.Name
+			fake.respond("GetPolicy", func(r *request.Request) {
+				r.Data.(*awsiam.GetPolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn), DefaultVersionId: awssdk.String("v1")}
+			})
+			var documents []string
+			fake.respond("CreatePolicy", func(r *request.Request) {
+				documents = append(documents, awssdk.StringValue(r.Params.(*awsiam.CreatePolicyInput).PolicyDocument))
+				r.Data.(*awsiam.CreatePolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn)}
+			})
+			_, err := reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(documents).To(HaveLen(1))
+			Expect(documents[0]).To(ContainSubstring(role.Status.ARN))
+
+			// the Role is recreated in another path
+			role.Status.ARN = "arn:aws:iam::123456789012:role/team/" + role.Name
+			Expect(k8sClient.Status().Update(ctx, role)).To(Succeed())
+			Expect(reconciler.policiesReferencing("Role")(role)).To(ConsistOf(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(policy)}))
+			Expect(reconciler.policiesReferencing("User")(role)).To(BeEmpty())
+
+			fake.fail("CreatePolicy", awsiam.ErrCodeEntityAlreadyExistsException)
+			fake.respond("GetPolicyVersion", func(r *request.Request) {
+				r.Data.(*awsiam.GetPolicyVersionOutput).PolicyVersion = &awsiam.PolicyVersion{Document: awssdk.String(url.QueryEscape(documents[0]))}
+			})
+			fake.respond("CreatePolicyVersion", func(r *request.Request) {
+				documents = append(documents, awssdk.StringValue(r.Params.(*awsiam.CreatePolicyVersionInput).PolicyDocument))
+			})
+			_, err = reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(documents).To(HaveLen(2))
+			Expect(documents[1]).To(ContainSubstring(role.Status.ARN))
+		})
+	})
+
+	Context("in a namespace requiring scoped resources", func() {
+		var namespace *v1.Namespace
+
+		BeforeEach(func() {
+			namespace = &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: uniqueName("secure")}}
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+		})
+
+		requireScopedResources := func(allowed string) {
+			namespace.Annotations = map[string]string{scopedResourcesAnnotation: allowed}
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+		}
+
+		It("rejects an over-broad statement, also of a Policy applied before", func() {
+			policy := newTestPolicy()
+			policy.Namespace = namespace.Name
+			createWithStatus(policy, func() {
+				policy.Status.ARN = "arn:aws:iam::123456789012:policy/" + policy.Name
+				policy.Status.State = iamv1beta1.OkSyncState
+				policy.Status.ObservedGeneration = policy.Generation
+			})
+
+			requireScopedResources("s3:ListAllMyBuckets")
+			Expect(reconciler.policiesForNamespace(namespace)).To(ConsistOf(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(policy)}))
+
+			_, err := reconcileObject(reconciler, policy)
+			Expect(err).To(MatchError(ContainSubstring("allows 's3:GetObject' on all resources")))
+			Expect(fake.Calls()).To(BeEmpty())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+			Expect(policy.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+		})
+
+		It("allows all resources for the allow-listed actions", func() {
+			requireScopedResources("s3:ListAllMyBuckets, s3:getobject")
+			policy := newTestPolicy()
+			policy.Namespace = namespace.Name
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+			policyArn := "arn:aws:iam::123456789012:policy/" + policy.Name
+			fake.respond("CreatePolicy", func(r *request.Request) {
+				r.Data.(*awsiam.CreatePolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn)}
+			})
+			fake.respond("GetPolicy", func(r *request.Request) {
+				r.Data.(*awsiam.GetPolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn), DefaultVersionId: awssdk.String("v1")}
+			})
+
+			_, err := reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("CreatePolicy"))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+			Expect(policy.Status.State).To(Equal(iamv1beta1.OkSyncState))
+		})
+	})
+
+	Context("when statements share a SID", func() {
+		It("rejects the document naming the SID and the statements, without calling AWS", func() {
+			policy := newTestPolicy()
+			entry := policy.Spec.Statement[0]
+			entry.Sid = "ReadObjects"
+			other := entry
+			other.Actions = []string{"s3:ListBucket"}
+			unnamed := iamv1beta1.PolicyStatementEntry{Effect: "Allow", Actions: []string{"s3:GetBucketLocation"}, Resources: []string{"*"}}
+			policy.Spec.Statement = iamv1beta1.PolicyStatement{entry, unnamed, other}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, policy)
+			Expect(err).To(MatchError("statement SID 'ReadObjects' is not unique; it is used by statements 1 and 3"))
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+			Expect(policy.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+			Expect(policy.Status.Message).To(ContainSubstring("'ReadObjects'"))
+		})
+	})
+
+	Context("with a change note annotated", func() {
+		It("records the note and generation with the policy version created for them", func() {
+			policy := newTestPolicy()
+			policy.Annotations = map[string]string{changeNoteAnnotation: "CHG-42: allow reading the reports"}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+			policyArn := "arn:aws:iam::123456789012:policy/" + policy.Name
+			fake.respond("CreatePolicy", func(r *request.Request) {
+				r.Data.(*awsiam.CreatePolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn)}
+			})
+			fake.respond("GetPolicy", func(r *request.Request) {
+				r.Data.(*awsiam.GetPolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn), DefaultVersionId: awssdk.String("v3")}
+			})
+
+			_, err := reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+			Expect(policy.Status.State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(policy.Status.ChangeNote).To(Equal("CHG-42: allow reading the reports"))
+			Expect(policy.Status.ChangeGeneration).To(Equal(policy.Generation))
+			Expect(policy.Status.PolicyVersion).To(Equal("v3"))
+		})
+	})
+
+	Context("with policy validation by Access Analyzer", func() {
+		var recorder *record.FakeRecorder
+
+		BeforeEach(func() {
+			recorder = record.NewFakeRecorder(10)
+			reconciler.Notifier = NewNotifier(recorder, "")
+			reconciler.ValidatePolicies = true
+			fake.respond("ValidatePolicy", func(r *request.Request) {
+				r.Data.(*validatePolicyOutput).Findings = []*validatePolicyFinding{
+					{FindingType: awssdk.String("ERROR"), IssueCode: awssdk.String("INVALID_ACTION"), FindingDetails: awssdk.String("The action s3:GetObjekt does not exist.")},
+					{FindingType: awssdk.String("SECURITY_WARNING"), IssueCode: awssdk.String("PASS_ROLE_WITH_STAR_IN_RESOURCE"), FindingDetails: awssdk.String("Using iam:PassRole with a wildcard in the resource can be overly permissive.")},
+				}
+			})
+		})
+
+		It("blocks the Policy on errors in strict mode, surfacing all findings", func() {
+			reconciler.StrictPolicyValidation = true
+			policy := newTestPolicy()
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, policy)
+			Expect(err).To(MatchError("policy validation found 1 errors: ERROR INVALID_ACTION: The action s3:GetObjekt does not exist."))
+			Expect(fake.Calls()).To(Equal([]string{"ValidatePolicy"}))
+			Expect(recorder.Events).To(HaveLen(2))
+			Expect(<-recorder.Events).To(Equal("Warning PolicyValidationFinding ERROR INVALID_ACTION: The action s3:GetObjekt does not exist."))
+			Expect(<-recorder.Events).To(HavePrefix("Warning PolicyValidationFinding SECURITY_WARNING PASS_ROLE_WITH_STAR_IN_RESOURCE"))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+			Expect(policy.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+			Expect(policy.Status.ValidationFindings).To(HaveLen(2))
+		})
+
+		It("applies the Policy otherwise, surfacing the findings in the status", func() {
+			policy := newTestPolicy()
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+			policyArn := "arn:aws:iam::123456789012:policy/" + policy.Name
+			fake.respond("CreatePolicy", func(r *request.Request) {
+				r.Data.(*awsiam.CreatePolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn)}
+			})
+			fake.respond("GetPolicy", func(r *request.Request) {
+				r.Data.(*awsiam.GetPolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn), DefaultVersionId: awssdk.String("v1")}
+			})
+
+			_, err := reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("CreatePolicy"))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+			Expect(policy.Status.State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(policy.Status.ValidationFindings).To(Equal([]string{
+				"ERROR INVALID_ACTION: The action s3:GetObjekt does not exist.",
+				"SECURITY_WARNING PASS_ROLE_WITH_STAR_IN_RESOURCE: Using iam:PassRole with a wildcard in the resource can be overly permissive.",
+			}))
+		})
+	})
+
+	Context("with least-privilege suggestions", func() {
+		It("suggests removing the services not accessed, once the last accessed job completes", func() {
+			recorder := record.NewFakeRecorder(10)
+			reconciler.Notifier = NewNotifier(recorder, "")
+			// the Policy counts as in use for long enough right away
+			reconciler.SuggestLeastPrivilegeAfter = time.Nanosecond
+			policy := newTestPolicy()
+			createWithStatus(policy, func() {
+				policy.Status.ARN = "arn:aws:iam::123456789012:policy/" + policy.Name
+				policy.Status.State = iamv1beta1.OkSyncState
+				policy.Status.ObservedGeneration = policy.Generation
+			})
+			fake.respond("GenerateServiceLastAccessedDetails", func(r *request.Request) {
+				r.Data.(*awsiam.GenerateServiceLastAccessedDetailsOutput).JobId = awssdk.String("examplef-1305-c245-eba4-71fe298bcda7")
+			})
+			status := awsiam.JobStatusTypeInProgress
+			fake.respond("GetServiceLastAccessedDetails", func(r *request.Request) {
+				output := r.Data.(*awsiam.GetServiceLastAccessedDetailsOutput)
+				output.JobStatus = awssdk.String(status)
+				output.ServicesLastAccessed = []*awsiam.ServiceLastAccessed{
+					{ServiceNamespace: awssdk.String("s3"), LastAuthenticated: awssdk.Time(time.Now().Add(-time.Hour))},
+					{ServiceNamespace: awssdk.String("sqs")},
+					{ServiceNamespace: awssdk.String("dynamodb")},
+				}
+			})
+
+			result, err := reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(leastPrivilegePollInterval))
+			_, err = reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(BeEmpty())
+
+			status = awsiam.JobStatusTypeCompleted
+			result, err = reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(leastPrivilegeCheckInterval))
+			Expect(fake.Calls()).To(Equal([]string{"GenerateServiceLastAccessedDetails", "GetServiceLastAccessedDetails", "GetServiceLastAccessedDetails"}))
+			Expect(recorder.Events).To(Receive(ContainSubstring("not accessed within the tracking period: dynamodb, sqs;")))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+			Expect(policy.Status.UnusedServices).To(Equal([]string{"dynamodb", "sqs"}))
+			Expect(policy.Status.LastAccessedJobID).To(BeEmpty())
+			Expect(policy.Spec.Statement).To(HaveLen(1))
+		})
+	})
+
+	Context("when equivalent statements are formatted differently", func() {
+		It("stores the same document, and doesn't create a version for a reformatted spec", func() {
+			var documents, versions []string
+			fake.respond("CreatePolicy", func(r *request.Request) {
+				input := r.Params.(*awsiam.CreatePolicyInput)
+				documents = append(documents, awssdk.StringValue(input.PolicyDocument))
+				r.Data.(*awsiam.CreatePolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String("arn:aws:iam::123456789012:policy/" + awssdk.StringValue(input.PolicyName))}
+			})
+			fake.respond("GetPolicy", func(r *request.Request) {
+				policyArn := r.Params.(*awsiam.GetPolicyInput).PolicyArn
+				r.Data.(*awsiam.GetPolicyOutput).Policy = &awsiam.Policy{Arn: policyArn, DefaultVersionId: awssdk.String("v1")}
+			})
+			fake.respond("CreatePolicyVersion", func(r *request.Request) {
+				versions = append(versions, awssdk.StringValue(r.Params.(*awsiam.CreatePolicyVersionInput).PolicyDocument))
+			})
+
+			messy := newTestPolicy()
+			messy.Spec.Statement[0].Actions = []string{"s3:PutObject", "s3:GetObject", "s3:GetObject"}
+			messy.Spec.Statement[0].Resources = []string{"arn:aws:s3:::reports/*", "arn:aws:s3:::exports/*"}
+			Expect(k8sClient.Create(ctx, messy)).To(Succeed())
+			tidy := newTestPolicy()
+			tidy.Spec.Statement[0].Actions = []string{"s3:GetObject", "s3:PutObject"}
+			tidy.Spec.Statement[0].Resources = []string{"arn:aws:s3:::exports/*", "arn:aws:s3:::reports/*"}
+			Expect(k8sClient.Create(ctx, tidy)).To(Succeed())
+
+			for _, policy := range []*iamv1beta1.Policy{messy, tidy} {
+				_, err := reconcileObject(reconciler, policy)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(documents).To(HaveLen(2))
+			Expect(documents[1]).To(Equal(documents[0]))
+
+			// the messy spec is tidied up, which doesn't change what it grants
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(messy), messy)).To(Succeed())
+			messy.Spec.Statement = tidy.Spec.Statement
+			// the API server bumps the generation on its own
+			messy.Generation++
+			Expect(k8sClient.Update(ctx, messy)).To(Succeed())
+			fake.fail("CreatePolicy", awsiam.ErrCodeEntityAlreadyExistsException)
+			fake.respond("GetPolicyVersion", func(r *request.Request) {
+				r.Data.(*awsiam.GetPolicyVersionOutput).PolicyVersion = &awsiam.PolicyVersion{Document: awssdk.String(url.QueryEscape(documents[0]))}
+			})
+
+			_, err := reconcileObject(reconciler, messy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("GetPolicyVersion"))
+			Expect(versions).To(BeEmpty())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(messy), messy)).To(Succeed())
+			Expect(messy.Status.State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(messy.Status.ObservedGeneration).To(Equal(messy.Generation))
+		})
+	})
+
+	Context("when statements are duplicated", func() {
+		It("submits each statement once, keeping the ones with distinct SIDs", func() {
+			policy := newTestPolicy()
+			read := policy.Spec.Statement[0]
+			reordered := read
+			reordered.Actions = []string{"s3:GetObject", "s3:GetObject"}
+			named := read
+			named.Sid = "ReadAgain"
+			alsoNamed := read
+			alsoNamed.Sid = "ReadOnceMore"
+			list := iamv1beta1.PolicyStatementEntry{Effect: "Allow", Actions: []string{"s3:ListBucket"}, Resources: []string{"*"}}
+			policy.Spec.Statement = iamv1beta1.PolicyStatement{read, list, reordered, named, alsoNamed, list}
+			Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+			var submitted string
+			fake.respond("CreatePolicy", func(r *request.Request) {
+				submitted = awssdk.StringValue(r.Params.(*awsiam.CreatePolicyInput).PolicyDocument)
+				r.Data.(*awsiam.CreatePolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String("arn:aws:iam::123456789012:policy/" + policy.Name)}
+			})
+			fake.respond("GetPolicy", func(r *request.Request) {
+				r.Data.(*awsiam.GetPolicyOutput).Policy = &awsiam.Policy{Arn: r.Params.(*awsiam.GetPolicyInput).PolicyArn, DefaultVersionId: awssdk.String("v1")}
+			})
+
+			_, err := reconcileObject(reconciler, policy)
+			Expect(err).NotTo(HaveOccurred())
+			var doc struct {
+				Statement []struct {
+					Sid    string
+					Action interface{}
+				}
+			}
+			Expect(json.Unmarshal([]byte(submitted), &doc)).To(Succeed())
+			Expect(doc.Statement).To(HaveLen(3))
+			// the unnamed read statement is merged into the named one, at its own position
+			Expect(doc.Statement[0].Sid).To(Equal("ReadAgain"))
+			Expect(doc.Statement[1].Sid).To(BeEmpty())
+			Expect(doc.Statement[2].Sid).To(Equal("ReadOnceMore"))
+		})
+	})
+})
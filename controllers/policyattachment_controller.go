@@ -21,9 +21,12 @@ import (
 	"fmt"
 
 	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	awsarn "github.com/aws/aws-sdk-go/aws/arn"
 
@@ -38,9 +41,13 @@ const policyAttachmentFinalizer = "policyattachment.aws-iam.redradrat.xyz"
 // PolicyAttachmentReconciler reconciles a PolicyAssignment object
 type PolicyAttachmentReconciler struct {
 	client.Client
-	Region string
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Region     string
+	Log        logr.Logger
+	Scheme     *runtime.Scheme
+	Debouncer  *Debouncer
+	Notifier   *Notifier
+	ReadOnly   bool
+	CreateOnly bool
 }
 
 // Reconcile PolicyAttachment
@@ -55,30 +62,99 @@ func (r *PolicyAttachmentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	err := r.Get(ctx, req.NamespacedName, &policyattachment)
 	if err != nil {
 		log.V(1).Info("unable to fetch PolicyAttachment")
+		r.Debouncer.Forget(req.NamespacedName)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	sw := newStatusPatcher(r.Status(), &policyattachment)
+
+	// a deleted Policy waits for its attachments, so we detach it even if nothing else changed
+	if policyattachment.ObjectMeta.DeletionTimestamp.IsZero() && policyattachment.Spec.PolicyReference.Name != "" {
+		if deleted, err := r.releaseDeletedPolicy(ctx, &policyattachment, sw); deleted || err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// the referenced resources may not even exist in other environments, so check this before anything else. The
+	// environment can change without the attachment changing, and an attachment made while it still matched is
+	// detached again.
+	if policyattachment.ObjectMeta.DeletionTimestamp.IsZero() && len(policyattachment.Spec.Environments) != 0 {
+		environment, err := namespaceEnvironment(ctx, r.Client, policyattachment.Namespace)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, sw)
+		}
+		if !containsString(policyattachment.Spec.Environments, environment) {
+			message := fmt.Sprintf("environment '%s' of namespace '%s' is not one of %v", environment, policyattachment.Namespace, policyattachment.Spec.Environments)
+			if policyattachment.Status.ARN != "" && !r.CreateOnly {
+				if r.ReadOnly {
+					return ctrl.Result{}, skipWithStatus(ctx, &policyattachment, fmt.Sprintf("read-only mode: would detach policy from '%s', as the %s", policyattachment.Status.ARN, message), sw)
+				}
+				if err := r.detachFromOtherEnvironment(ctx, &policyattachment, environment); err != nil {
+					log.Error(err, "unable to detach policy of PolicyAttachment from another environment")
+					return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, sw)
+				}
+			}
+			return ctrl.Result{}, skipWithStatus(ctx, &policyattachment, message, sw)
+		}
+	}
+
 	// return if only status/metadata updated
 	if policyattachment.Status.ObservedGeneration == policyattachment.ObjectMeta.Generation && policyattachment.Status.State == iamv1beta1.OkSyncState {
 		return ctrl.Result{}, nil
 	}
 
+	hash, err := specHash(policyattachment.Spec)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, sw)
+	}
+
+	// return if the spec is identical to the last applied one (e.g. an unchanged manifest has been re-applied)
+	if policyattachment.ObjectMeta.DeletionTimestamp.IsZero() && lastAppliedSpecMatches(&policyattachment, hash) {
+		return ctrl.Result{}, observeGeneration(ctx, &policyattachment, sw)
+	}
+
+	// wait for rapid consecutive spec changes to settle, before we talk to AWS
+	if policyattachment.ObjectMeta.DeletionTimestamp.IsZero() {
+		if wait := r.Debouncer.Wait(req.NamespacedName, policyattachment.ObjectMeta.Generation); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	// don't act before the gate is open; deletions are never held back
+	if policyattachment.ObjectMeta.DeletionTimestamp.IsZero() {
+		reason, err := gateClosed(ctx, r.Client, policyattachment.Spec.Gate, policyattachment.Namespace)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, sw)
+		}
+		if reason != "" {
+			log.Info(reason)
+			return ctrl.Result{RequeueAfter: gateRetryInterval}, nil
+		}
+	}
+
 	// first let's get the ARNs from the referenced resources in the spec
 	policyArn, targetArn, err := getPolicyAttachmentARNs(ctx, &policyattachment, r.Client)
 	if err != nil {
-		return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, r.Status())
+		return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, sw)
+	}
+
+	// AWS would reject the attachment anyway, but with a far less helpful message
+	if policyattachment.ObjectMeta.DeletionTimestamp.IsZero() {
+		if err := checkPolicyAttachmentAccounts(policyArn, targetArn); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, sw)
+		}
 	}
 
 	// now we need to translate the specified target resource in the CR to an IAM AttachmentType
 	attachType, err := policyattachment.GetAttachmentType()
 	if err != nil {
-		return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, r.Status())
+		return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, sw)
 	}
 
 	// Get our actual IAM Service to communicate with AWS; we don't need to continue without it
-	iamsvc, err := IAMService(r.Region)
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
 	if err != nil {
-		return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, r.Status())
+		return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, sw)
 	}
 
 	// now let's instantiate our PolicyAttachmentInstance
@@ -99,15 +175,41 @@ func (r *PolicyAttachmentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	} else {
 		if containsString(policyattachment.ObjectMeta.Finalizers, policyAttachmentFinalizer) {
 			// our finalizer is present, so lets handle any external dependency
+			if r.ReadOnly {
+				return ctrl.Result{}, skipWithStatus(ctx, &policyattachment, fmt.Sprintf("read-only mode: would detach policy '%s' from '%s'", policyArn.String(), targetArn.String()), sw)
+			}
 
-			// delete the actual AWS Object and pass the cleanup function
-			statusUpdater, err := DeleteAWSObject(iamsvc, ins, DoNothingPreFunc)
-			// we got a StatusUpdater function returned... let's execute it
-			statusUpdater(ctx, ins, &policyattachment, r.Status(), log)
-			if err != nil {
-				// we had an error during AWS Object deletion... so we return here to retry
-				log.Error(err, "unable to delete PolicyAttachment")
-				return ctrl.Result{}, err
+			if r.CreateOnly {
+				// leave the AWS object untouched, but let the resource go
+				log.Info(fmt.Sprintf("create-only mode: leaving policy '%s' attached to '%s'", policyArn.String(), targetArn.String()))
+			} else {
+				// a policy converted to an inline one stays attached, until the target has applied its inline policies
+				pending, err := inlinePoliciesPending(ctx, r.Client, &policyattachment)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+				if pending {
+					log.Info(fmt.Sprintf("keeping policy '%s' attached until '%s' has applied its inline policies", policyArn.String(), targetArn.String()))
+					return ctrl.Result{RequeueAfter: conversionRetryInterval}, nil
+				}
+
+				holder, err := attachmentHolder(ctx, r.Client, policyattachment.Spec.TargetReference.Type, policyArn.String(), targetArn.String(), &policyattachment)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+				if holder != "" {
+					log.Info(fmt.Sprintf("leaving policy '%s' attached to '%s', as %s attaches it as well", policyArn.String(), targetArn.String(), holder))
+				} else {
+					// delete the actual AWS Object and pass the cleanup function
+					statusUpdater, err := DeleteAWSObject(iamsvc, ins, DoNothingPreFunc)
+					// we got a StatusUpdater function returned... let's execute it
+					statusUpdater(ctx, ins, &policyattachment, sw, log)
+					if err != nil {
+						// we had an error during AWS Object deletion... so we return here to retry
+						log.Error(err, "unable to delete PolicyAttachment")
+						return ctrl.Result{}, err
+					}
+				}
 			}
 
 			// remove our finalizer from the list and update it.
@@ -116,6 +218,9 @@ func (r *PolicyAttachmentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 				log.Error(err, "unable to remove finalizer from PolicyAttachment")
 				return ctrl.Result{}, err
 			}
+			if !r.CreateOnly {
+				r.Notifier.Notify(&policyattachment, v1.EventTypeNormal, "Deleted", fmt.Sprintf("Deleted PolicyAttachment '%s'", policyattachment.Status.ARN))
+			}
 		}
 
 		// Stop reconciliation as the item is being deleted
@@ -124,6 +229,14 @@ func (r *PolicyAttachmentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 
 	// RECONCILE THE RESOURCE
 
+	if r.ReadOnly {
+		return ctrl.Result{}, skipWithStatus(ctx, &policyattachment, fmt.Sprintf("read-only mode: would attach policy '%s' to '%s'", policyArn.String(), targetArn.String()), sw)
+	}
+
+	if r.CreateOnly && policyattachment.Status.ARN != "" {
+		return ctrl.Result{}, skipWithStatus(ctx, &policyattachment, fmt.Sprintf("create-only mode: not reattaching policy '%s' to '%s'", policyArn.String(), targetArn.String()), sw)
+	}
+
 	// if there is already an ARN in our status, then we remove the PolicyAttachment from that ARN:
 	// 	1) 	A user could have changed the TargetReference,
 	//		so we need to remove it from the old status ARN
@@ -134,7 +247,7 @@ func (r *PolicyAttachmentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		// delete the actual AWS Object and pass the cleanup function
 		statusUpdater, err := DeleteAWSObject(iamsvc, ins, DoNothingPreFunc)
 		// we got a StatusUpdater function returned... let's execute it
-		statusUpdater(ctx, ins, &policyattachment, r.Status(), log)
+		statusUpdater(ctx, ins, &policyattachment, sw, log)
 		if err != nil {
 			// we had an error during AWS Object deletion... so we return here to retry
 			log.Error(err, "error while deleting PolicyAttachment during reconciliation")
@@ -142,17 +255,27 @@ func (r *PolicyAttachmentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		}
 	}
 	statusUpdater, err := CreateAWSObject(iamsvc, ins, DoNothingPreFunc)
-	statusUpdater(ctx, ins, &policyattachment, r.Status(), log)
+	if err != nil && awaitsConsistency(ctx, r.Client, &policyattachment, err) {
+		log.Info(fmt.Sprintf("target '%s' is not yet visible in IAM; retrying the attachment", targetArn.String()))
+		return ctrl.Result{RequeueAfter: attachConsistencyRetryInterval}, nil
+	}
+	statusUpdater(ctx, ins, &policyattachment, sw, log)
 	if err != nil {
 		log.Error(err, "error while creating PolicyAttachment during reconciliation")
-		return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, r.Status())
+		return ctrl.Result{}, errWithStatus(ctx, &policyattachment, err, sw)
 	}
 
-	policyattachment.Status.ObservedGeneration = policyattachment.ObjectMeta.Generation
-	if err := r.Status().Update(ctx, &policyattachment); err != nil {
+	policyattachment.Status.ObservedGeneration = sw.generation
+	if err := sw.Update(ctx, &policyattachment); err != nil {
 		return ctrl.Result{}, err
 	}
 
+	if err := storeLastAppliedSpecHash(ctx, r.Client, &policyattachment, hash); err != nil {
+		log.Error(err, "unable to store last applied spec hash for PolicyAttachment")
+		return ctrl.Result{}, err
+	}
+	r.Notifier.Notify(&policyattachment, v1.EventTypeNormal, "Reconciled", fmt.Sprintf("Reconciled PolicyAttachment '%s'", policyattachment.Status.ARN))
+
 	log.Info(fmt.Sprintf("Created PolicyAttachment on target '%s'", policyattachment.Status.ARN))
 
 	return ctrl.Result{}, nil
@@ -218,45 +341,48 @@ func checkPolicyAttachmentRefs(ctx context.Context, policyAttachment *iamv1beta1
 	return nil
 }
 
-func getPolicyAttachmentARNs(ctx context.Context, policyAttachment *iamv1beta1.PolicyAttachment, c client.Client) (targetArn, policyArn awsarn.ARN, err error) {
+// getPolicyAttachmentPolicyARN resolves the ARN of the policy the PolicyAttachment attaches
+func getPolicyAttachmentPolicyARN(ctx context.Context, policyAttachment *iamv1beta1.PolicyAttachment, c client.Client) (policyArn awsarn.ARN, err error) {
 
 	if policyAttachment.Spec.ExternalPolicy.ARN == "" && policyAttachment.Spec.PolicyReference.Name == "" {
-		return policyArn, targetArn, fmt.Errorf("one of policy or externalPolicy must be set")
+		return policyArn, fmt.Errorf("one of policy or externalPolicy must be set")
 	}
 
 	// If there is Policy ARN given, we need to attach that policy to the target
 	if policyAttachment.Spec.ExternalPolicy.ARN != "" {
 
 		if policyAttachment.Spec.PolicyReference.Name != "" {
-			return policyArn, targetArn, fmt.Errorf("cannot define both policy and externalPolicy")
+			return policyArn, fmt.Errorf("cannot define both policy and externalPolicy")
 		}
 
 		// Check if valid ARN
 		if awsarn.IsARN(policyAttachment.Spec.ExternalPolicy.ARN) == false {
-			return policyArn, targetArn, fmt.Errorf("given ARN '%s' is not valid", policyAttachment.Spec.ExternalPolicy.ARN)
-		}
-		policyArn, err = awsarn.Parse(policyAttachment.Spec.ExternalPolicy.ARN)
-		if err != nil {
-			return policyArn, targetArn, err
-		}
-	} else {
-		polRef := policyAttachment.Spec.PolicyReference
-		if err := checkPolicyAttachmentRefs(ctx, policyAttachment, c); err != nil {
-			return policyArn, targetArn, err
+			return policyArn, fmt.Errorf("given ARN '%s' is not valid", policyAttachment.Spec.ExternalPolicy.ARN)
 		}
+		return awsarn.Parse(policyAttachment.Spec.ExternalPolicy.ARN)
+	}
 
-		policy := iamv1beta1.Policy{}
-		if err := c.Get(ctx, client.ObjectKey{Name: polRef.Name, Namespace: polRef.Namespace}, &policy); err != nil {
-			return policyArn, targetArn, err
-		}
+	polRef := policyAttachment.Spec.PolicyReference
+	if err := checkPolicyAttachmentRefs(ctx, policyAttachment, c); err != nil {
+		return policyArn, err
+	}
 
-		if policy.Status.ARN == "" {
-			return policyArn, targetArn, fmt.Errorf("ARN is empty in status for policy reference")
-		}
-		policyArn, err = awsarn.Parse(policy.Status.ARN)
-		if err != nil {
-			return policyArn, targetArn, err
-		}
+	policy := iamv1beta1.Policy{}
+	if err := c.Get(ctx, client.ObjectKey{Name: polRef.Name, Namespace: polRef.Namespace}, &policy); err != nil {
+		return policyArn, err
+	}
+
+	if policy.Status.ARN == "" {
+		return policyArn, fmt.Errorf("ARN is empty in status for policy reference")
+	}
+	return awsarn.Parse(policy.Status.ARN)
+}
+
+func getPolicyAttachmentARNs(ctx context.Context, policyAttachment *iamv1beta1.PolicyAttachment, c client.Client) (targetArn, policyArn awsarn.ARN, err error) {
+
+	policyArn, err = getPolicyAttachmentPolicyARN(ctx, policyAttachment, c)
+	if err != nil {
+		return policyArn, targetArn, err
 	}
 
 	targetObj := &client.ObjectKey{
@@ -309,8 +435,20 @@ func getPolicyAttachmentARNs(ctx context.Context, policyAttachment *iamv1beta1.P
 	return policyArn, targetArn, nil
 }
 
+// checkPolicyAttachmentAccounts makes sure that policy and target live in the same account, as policies cannot be
+// attached across accounts. AWS managed policies (account "aws") can be attached in every account.
+func checkPolicyAttachmentAccounts(policyArn, targetArn awsarn.ARN) error {
+	if policyArn.AccountID == "aws" || policyArn.AccountID == targetArn.AccountID {
+		return nil
+	}
+	return fmt.Errorf("policy '%s' belongs to account '%s', but target '%s' belongs to account '%s'; policies can only be attached within the same account",
+		policyArn.String(), policyArn.AccountID, targetArn.String(), targetArn.AccountID)
+}
+
 func (r *PolicyAttachmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&iamv1beta1.PolicyAttachment{}).
+		Watches(&source.Kind{Type: &iamv1beta1.Policy{}}, handler.EnqueueRequestsFromMapFunc(r.policyAttachmentsForPolicy)).
+		Watches(&source.Kind{Type: &v1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.policyAttachmentsForNamespace)).
 		Complete(r)
 }
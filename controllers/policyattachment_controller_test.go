@@ -0,0 +1,332 @@
+package controllers
+
+import (
+	"context"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+var _ = Describe("PolicyAttachment controller", func() {
+	var (
+		ctx        context.Context
+		fake       *fakeIAM
+		reconciler *PolicyAttachmentReconciler
+		role       *iamv1beta1.Role
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = installFakeIAM()
+		reconciler = &PolicyAttachmentReconciler{
+			Client: k8sClient,
+			Log:    ctrl.Log.WithName("controllers").WithName("PolicyAttachment"),
+			Scheme: k8sClient.Scheme(),
+			Region: "eu-west-1",
+		}
+
+		role = &iamv1beta1.Role{ObjectMeta: metav1.ObjectMeta{Name: uniqueName("role"), Namespace: "default"}}
+		createWithStatus(role, func() {
+			role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+			role.Status.State = iamv1beta1.OkSyncState
+		})
+	})
+
+	AfterEach(func() {
+		uninstallFakeIAM()
+	})
+
+	// newAppliedAttachment returns an attachment of an AWS managed policy to the role, which has been reconciled
+	// successfully with its current spec; observed is the generation recorded as observed
+	newAppliedAttachment := func(observed func(generation int64) int64) *iamv1beta1.PolicyAttachment {
+		attachment := &iamv1beta1.PolicyAttachment{
+			ObjectMeta: metav1.ObjectMeta{Name: uniqueName("attachment"), Namespace: "default"},
+			Spec: iamv1beta1.PolicyAttachmentSpec{
+				ExternalPolicy: iamv1beta1.ExternalResource{ARN: "arn:aws:iam::aws:policy/ReadOnlyAccess"},
+				TargetReference: iamv1beta1.TargetReference{
+					Type:      iamv1beta1.RoleTargetType,
+					Name:      role.Name,
+					Namespace: role.Namespace,
+				},
+			},
+		}
+		hash, err := specHash(attachment.Spec)
+		Expect(err).NotTo(HaveOccurred())
+		attachment.Annotations = map[string]string{lastAppliedSpecHashAnnotation: hash}
+
+		createWithStatus(attachment, func() {
+			attachment.Status.ARN = role.Status.ARN
+			attachment.Status.State = iamv1beta1.OkSyncState
+			attachment.Status.ObservedGeneration = observed(attachment.Generation)
+		})
+		return attachment
+	}
+
+	Context("when an unchanged spec is re-applied", func() {
+		It("doesn't call AWS", func() {
+			attachment := newAppliedAttachment(func(generation int64) int64 { return generation })
+
+			_, err := reconcileObject(reconciler, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(BeEmpty())
+		})
+	})
+
+	Context("when the generation changed, but the spec hash still matches", func() {
+		It("only observes the generation, without calling AWS", func() {
+			attachment := newAppliedAttachment(func(generation int64) int64 { return generation - 1 })
+
+			_, err := reconcileObject(reconciler, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(attachment), attachment)).To(Succeed())
+			Expect(attachment.Status.ObservedGeneration).To(Equal(attachment.Generation))
+		})
+	})
+
+	// newPolicyReferenceAttachment returns an attachment of the referenced Policy to the role
+	newPolicyReferenceAttachment := func(policyName string) *iamv1beta1.PolicyAttachment {
+		return &iamv1beta1.PolicyAttachment{
+			ObjectMeta: metav1.ObjectMeta{Name: uniqueName("attachment"), Namespace: "default"},
+			Spec: iamv1beta1.PolicyAttachmentSpec{
+				PolicyReference: iamv1beta1.ResourceReference{Name: policyName, Namespace: "default"},
+				TargetReference: iamv1beta1.TargetReference{
+					Type:      iamv1beta1.RoleTargetType,
+					Name:      role.Name,
+					Namespace: role.Namespace,
+				},
+			},
+		}
+	}
+
+	Context("when the referenced Policy doesn't exist yet", func() {
+		It("waits for the Policy instead of releasing it", func() {
+			attachment := newPolicyReferenceAttachment(uniqueName("policy"))
+			Expect(k8sClient.Create(ctx, attachment)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, attachment)
+			Expect(err).To(HaveOccurred())
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(attachment), attachment)).To(Succeed())
+			Expect(attachment.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+			Expect(attachment.Status.Message).To(ContainSubstring("defined references do not exist"))
+		})
+	})
+
+	Context("when the referenced Policy is deleted", func() {
+		var (
+			policy     *iamv1beta1.Policy
+			attachment *iamv1beta1.PolicyAttachment
+		)
+
+		BeforeEach(func() {
+			policy = &iamv1beta1.Policy{ObjectMeta: metav1.ObjectMeta{
+				Name:       uniqueName("policy"),
+				Namespace:  "default",
+				Finalizers: []string{"policy.aws-iam.redradrat.xyz"},
+			}}
+			createWithStatus(policy, func() {
+				policy.Status.ARN = "arn:aws:iam::123456789012:policy/" + policy.Name
+				policy.Status.State = iamv1beta1.OkSyncState
+			})
+
+			attachment = newPolicyReferenceAttachment(policy.Name)
+			attachment.Finalizers = []string{policyAttachmentFinalizer}
+			createWithStatus(attachment, func() {
+				attachment.Status.ARN = role.Status.ARN
+				attachment.Status.State = iamv1beta1.OkSyncState
+				attachment.Status.ObservedGeneration = attachment.Generation
+			})
+
+			fake.respond("ListAttachedRolePolicies", func(r *request.Request) {
+				r.Data.(*awsiam.ListAttachedRolePoliciesOutput).AttachedPolicies = []*awsiam.AttachedPolicy{{
+					PolicyArn:  awssdk.String(policy.Status.ARN),
+					PolicyName: awssdk.String(policy.Name),
+				}}
+			})
+		})
+
+		It("leaves it attached while a PolicyAttachmentSet attaches it as well, but releases the attachment", func() {
+			set := &iamv1beta1.PolicyAttachmentSet{ObjectMeta: metav1.ObjectMeta{Name: uniqueName("set"), Namespace: "default"}}
+			createWithStatus(set, func() {
+				set.Status.State = iamv1beta1.OkSyncState
+				set.Status.Attachments = []iamv1beta1.PolicyAttachmentSetEntryStatus{{
+					PolicyARN:  policy.Status.ARN,
+					TargetARN:  role.Status.ARN,
+					TargetType: iamv1beta1.RoleTargetType,
+					State:      iamv1beta1.OkSyncState,
+				}}
+			})
+			Expect(k8sClient.Delete(ctx, policy)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).NotTo(ContainElement("DetachRolePolicy"))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(attachment), attachment)).To(Succeed())
+			Expect(attachment.Status.State).To(Equal(iamv1beta1.SkippedSyncState))
+			Expect(attachment.Finalizers).NotTo(ContainElement(policyAttachmentFinalizer))
+		})
+
+		It("detaches it and releases the attachment", func() {
+			// the Policy waits for its attachments to be gone
+			Expect(k8sClient.Delete(ctx, policy)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("DetachRolePolicy"))
+			calls := len(fake.Calls())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(attachment), attachment)).To(Succeed())
+			Expect(attachment.Status.State).To(Equal(iamv1beta1.SkippedSyncState))
+			Expect(attachment.Status.Message).To(ContainSubstring("is deleted; nothing is attached"))
+			Expect(attachment.Finalizers).NotTo(ContainElement(policyAttachmentFinalizer))
+
+			// once the Policy is gone for good, the attachment stays released
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), policy)).To(Succeed())
+			policy.Finalizers = nil
+			Expect(k8sClient.Update(ctx, policy)).To(Succeed())
+
+			_, err = reconcileObject(reconciler, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(HaveLen(calls))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(attachment), attachment)).To(Succeed())
+			Expect(attachment.Status.State).To(Equal(iamv1beta1.SkippedSyncState))
+		})
+	})
+
+	Context("when the attachment is restricted to environments", func() {
+		var (
+			namespace  *v1.Namespace
+			attachment *iamv1beta1.PolicyAttachment
+		)
+
+		BeforeEach(func() {
+			namespace = &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        uniqueName("team"),
+				Annotations: map[string]string{environmentAnnotation: "staging"},
+			}}
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			attachment = &iamv1beta1.PolicyAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: uniqueName("attachment"), Namespace: namespace.Name},
+				Spec: iamv1beta1.PolicyAttachmentSpec{
+					ExternalPolicy: iamv1beta1.ExternalResource{ARN: "arn:aws:iam::aws:policy/ReadOnlyAccess"},
+					TargetReference: iamv1beta1.TargetReference{
+						Type:      iamv1beta1.RoleTargetType,
+						Name:      role.Name,
+						Namespace: role.Namespace,
+					},
+					Environments: []string{"production"},
+				},
+			}
+		})
+
+		It("isn't applied in another environment", func() {
+			Expect(k8sClient.Create(ctx, attachment)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(attachment), attachment)).To(Succeed())
+			Expect(attachment.Status.State).To(Equal(iamv1beta1.SkippedSyncState))
+			Expect(attachment.Status.Message).To(ContainSubstring("environment 'staging'"))
+		})
+
+		It("is detached once the environment of its namespace changes", func() {
+			namespace.Annotations[environmentAnnotation] = "production"
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+			createWithStatus(attachment, func() {
+				attachment.Status.ARN = role.Status.ARN
+				attachment.Status.State = iamv1beta1.OkSyncState
+				attachment.Status.ObservedGeneration = attachment.Generation
+			})
+			fake.respond("ListAttachedRolePolicies", func(r *request.Request) {
+				r.Data.(*awsiam.ListAttachedRolePoliciesOutput).AttachedPolicies = []*awsiam.AttachedPolicy{{
+					PolicyArn:  awssdk.String("arn:aws:iam::aws:policy/ReadOnlyAccess"),
+					PolicyName: awssdk.String("ReadOnlyAccess"),
+				}}
+			})
+
+			namespace.Annotations[environmentAnnotation] = "staging"
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+			Expect(reconciler.policyAttachmentsForNamespace(namespace)).To(HaveLen(1))
+
+			_, err := reconcileObject(reconciler, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("DetachRolePolicy"))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(attachment), attachment)).To(Succeed())
+			Expect(attachment.Status.State).To(Equal(iamv1beta1.SkippedSyncState))
+			Expect(attachment.Status.ARN).To(BeEmpty())
+		})
+	})
+
+	Context("when the policy belongs to another account than the target", func() {
+		It("is rejected before calling AWS", func() {
+			attachment := &iamv1beta1.PolicyAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: uniqueName("attachment"), Namespace: "default"},
+				Spec: iamv1beta1.PolicyAttachmentSpec{
+					ExternalPolicy:  iamv1beta1.ExternalResource{ARN: "arn:aws:iam::999999999999:policy/foreign"},
+					TargetReference: iamv1beta1.TargetReference{Type: iamv1beta1.RoleTargetType, Name: role.Name, Namespace: role.Namespace},
+				},
+			}
+			Expect(k8sClient.Create(ctx, attachment)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, attachment)
+			Expect(err).To(MatchError(ContainSubstring("belongs to account '999999999999', but target")))
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(attachment), attachment)).To(Succeed())
+			Expect(attachment.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+			Expect(attachment.Status.Message).To(ContainSubstring("policies can only be attached within the same account"))
+		})
+	})
+
+	Context("when the target Role has just been created", func() {
+		It("retries an attachment failing while IAM doesn't know the Role yet", func() {
+			// the API server sets the creation timestamp on its own, the fake client doesn't
+			fresh := &iamv1beta1.Role{ObjectMeta: metav1.ObjectMeta{Name: uniqueName("role"), Namespace: "default", CreationTimestamp: metav1.Now()}}
+			createWithStatus(fresh, func() {
+				fresh.Status.ARN = "arn:aws:iam::123456789012:role/" + fresh.Name
+				fresh.Status.State = iamv1beta1.OkSyncState
+			})
+			attachment := &iamv1beta1.PolicyAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: uniqueName("attachment"), Namespace: "default"},
+				Spec: iamv1beta1.PolicyAttachmentSpec{
+					ExternalPolicy:  iamv1beta1.ExternalResource{ARN: "arn:aws:iam::aws:policy/ReadOnlyAccess"},
+					TargetReference: iamv1beta1.TargetReference{Type: iamv1beta1.RoleTargetType, Name: fresh.Name, Namespace: fresh.Namespace},
+				},
+			}
+			Expect(k8sClient.Create(ctx, attachment)).To(Succeed())
+			fake.fail("AttachRolePolicy", awsiam.ErrCodeNoSuchEntityException)
+
+			result, err := reconcileObject(reconciler, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(attachConsistencyRetryInterval))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(attachment), attachment)).To(Succeed())
+			Expect(attachment.Status.State).NotTo(Equal(iamv1beta1.ErrorSyncState))
+
+			fake.respond("AttachRolePolicy", func(r *request.Request) {})
+			_, err = reconcileObject(reconciler, attachment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(Equal([]string{"AttachRolePolicy", "AttachRolePolicy"}))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(attachment), attachment)).To(Succeed())
+			Expect(attachment.Status.State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(attachment.Status.ARN).To(Equal(fresh.Status.ARN))
+		})
+	})
+})
@@ -0,0 +1,431 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/redradrat/cloud-objects/aws/iam"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// finalizer for detaching all policies of the set
+const policyAttachmentSetFinalizer = "policyattachmentset.aws-iam.redradrat.xyz"
+
+// PolicyAttachmentSetReconciler reconciles a PolicyAttachmentSet object
+type PolicyAttachmentSetReconciler struct {
+	client.Client
+	Region     string
+	Log        logr.Logger
+	Scheme     *runtime.Scheme
+	Debouncer  *Debouncer
+	Notifier   *Notifier
+	ReadOnly   bool
+	CreateOnly bool
+}
+
+// Reconcile PolicyAttachmentSet
+// +kubebuilder:rbac:groups=aws-iam.redradrat.xyz,resources=policyattachmentsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=aws-iam.redradrat.xyz,resources=policyattachmentsets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=aws-iam.redradrat.xyz,resources=policyattachmentsets/finalizers,verbs=get;update
+
+func (r *PolicyAttachmentSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("policyattachmentset", req.NamespacedName)
+
+	var set iamv1beta1.PolicyAttachmentSet
+	err := r.Get(ctx, req.NamespacedName, &set)
+	if err != nil {
+		log.V(1).Info("unable to fetch PolicyAttachmentSet")
+		r.Debouncer.Forget(req.NamespacedName)
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	sw := newStatusPatcher(r.Status(), &set)
+
+	// return if only status/metadata updated
+	if set.Status.ObservedGeneration == set.ObjectMeta.Generation && set.Status.State == iamv1beta1.OkSyncState {
+		return ctrl.Result{}, nil
+	}
+
+	hash, err := specHash(set.Spec)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &set, err, sw)
+	}
+
+	// return if the spec is identical to the last applied one (e.g. an unchanged manifest has been re-applied)
+	if set.ObjectMeta.DeletionTimestamp.IsZero() && lastAppliedSpecMatches(&set, hash) {
+		return ctrl.Result{}, observeGeneration(ctx, &set, sw)
+	}
+
+	// wait for rapid consecutive spec changes to settle, before we talk to AWS
+	if set.ObjectMeta.DeletionTimestamp.IsZero() {
+		if wait := r.Debouncer.Wait(req.NamespacedName, set.ObjectMeta.Generation); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	// don't act before the gate is open; deletions are never held back
+	if set.ObjectMeta.DeletionTimestamp.IsZero() {
+		reason, err := gateClosed(ctx, r.Client, set.Spec.Gate, set.Namespace)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &set, err, sw)
+		}
+		if reason != "" {
+			log.Info(reason)
+			return ctrl.Result{RequeueAfter: gateRetryInterval}, nil
+		}
+	}
+
+	// Get our actual IAM Service to communicate with AWS; we don't need to continue without it
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &set, err, sw)
+	}
+
+	// Check Deletion and finalizer
+	if set.ObjectMeta.DeletionTimestamp.IsZero() {
+		// The object is not being deleted, so if it does not have our finalizer,
+		// then lets add the finalizer and update the object. This is equivalent
+		// registering our finalizer.
+		if !containsString(set.ObjectMeta.Finalizers, policyAttachmentSetFinalizer) {
+			set.ObjectMeta.Finalizers = append(set.ObjectMeta.Finalizers, policyAttachmentSetFinalizer)
+			if err := r.Update(ctx, &set); err != nil {
+				log.Error(err, "unable to register finalizer for PolicyAttachmentSet")
+				return ctrl.Result{}, err
+			}
+		}
+	} else {
+		if containsString(set.ObjectMeta.Finalizers, policyAttachmentSetFinalizer) {
+			// our finalizer is present, so lets handle any external dependency
+			if r.ReadOnly {
+				return ctrl.Result{}, skipWithStatus(ctx, &set, fmt.Sprintf("read-only mode: would detach %d policies", len(set.Status.Attachments)), sw)
+			}
+
+			if r.CreateOnly {
+				// leave the AWS objects untouched, but let the resource go
+				log.Info(fmt.Sprintf("create-only mode: leaving %d policies attached", len(set.Status.Attachments)))
+			} else {
+				// detach everything we have attached
+				for _, attached := range set.Status.Attachments {
+					if err := r.detachSetEntry(ctx, iamsvc, &set, attached); err != nil {
+						log.Error(err, "unable to delete PolicyAttachmentSet")
+						return ctrl.Result{}, errWithStatus(ctx, &set, err, sw)
+					}
+				}
+			}
+
+			// remove our finalizer from the list and update it.
+			set.ObjectMeta.Finalizers = removeString(set.ObjectMeta.Finalizers, policyAttachmentSetFinalizer)
+			if err := r.Update(ctx, &set); err != nil {
+				log.Error(err, "unable to remove finalizer from PolicyAttachmentSet")
+				return ctrl.Result{}, err
+			}
+			if !r.CreateOnly {
+				r.Notifier.Notify(&set, v1.EventTypeNormal, "Deleted", fmt.Sprintf("Deleted PolicyAttachmentSet '%s/%s'", set.Namespace, set.Name))
+			}
+		}
+
+		// Stop reconciliation as the item is being deleted
+		return ctrl.Result{}, nil
+	}
+
+	// RECONCILE THE RESOURCE
+
+	if r.ReadOnly {
+		return ctrl.Result{}, skipWithStatus(ctx, &set, fmt.Sprintf("read-only mode: would attach %d policies", len(set.Spec.Attachments)), sw)
+	}
+
+	// every pair is attached on its own, so that one failing pair doesn't block the others
+	previous := map[string]iamv1beta1.PolicyAttachmentSetEntryStatus{}
+	for _, attached := range set.Status.Attachments {
+		previous[setEntryKey(attached)] = attached
+	}
+	var statuses []iamv1beta1.PolicyAttachmentSetEntryStatus
+	desired := map[string]bool{}
+	failed := 0
+	pending := false
+	resolved := true
+	for _, entry := range set.Spec.Attachments {
+		status, err := r.attachSetEntry(ctx, iamsvc, &set, entry, previous)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to attach policy to %s '%s/%s'", entry.TargetReference.Type, entry.TargetReference.Namespace, entry.TargetReference.Name))
+			failed++
+		}
+		if status.State == iamv1beta1.SyncSyncState {
+			pending = true
+		}
+		if status.PolicyARN != "" && status.TargetARN != "" {
+			desired[setEntryKey(status)] = true
+		} else {
+			resolved = false
+		}
+		statuses = append(statuses, status)
+	}
+
+	// detach the pairs that have been removed from the spec; in create-only mode we leave them attached. As long as
+	// not every pair could be resolved, we cannot tell which pairs are gone, so we keep tracking all of them.
+	for _, attached := range set.Status.Attachments {
+		if desired[setEntryKey(attached)] || r.CreateOnly {
+			continue
+		}
+		if !resolved {
+			statuses = append(statuses, attached)
+			continue
+		}
+		if err := r.detachSetEntry(ctx, iamsvc, &set, attached); err != nil {
+			log.Error(err, fmt.Sprintf("unable to detach policy '%s' from '%s'", attached.PolicyARN, attached.TargetARN))
+			attached.State = iamv1beta1.ErrorSyncState
+			attached.Message = err.Error()
+			statuses = append(statuses, attached)
+			failed++
+		}
+	}
+
+	set.Status.Attachments = statuses
+	set.Status.LastSyncAttempt = time.Now().Format(time.RFC822Z)
+	set.Status.ObservedGeneration = sw.generation
+	if failed > 0 {
+		// retry the failed pairs; the rest stays attached
+		err := fmt.Errorf("%d of %d attachments failed", failed, len(statuses))
+		return ctrl.Result{}, errWithStatus(ctx, &set, err, sw)
+	}
+	if pending {
+		set.Status.State = iamv1beta1.SyncSyncState
+		set.Status.Message = "waiting for targets to become visible in IAM"
+		if err := sw.Update(ctx, &set); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: attachConsistencyRetryInterval}, nil
+	}
+	set.Status.State = iamv1beta1.OkSyncState
+	set.Status.Message = "Succesfully reconciled"
+	if err := sw.Update(ctx, &set); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := storeLastAppliedSpecHash(ctx, r.Client, &set, hash); err != nil {
+		log.Error(err, "unable to store last applied spec hash for PolicyAttachmentSet")
+		return ctrl.Result{}, err
+	}
+	r.Notifier.Notify(&set, v1.EventTypeNormal, "Reconciled", fmt.Sprintf("Reconciled PolicyAttachmentSet '%s/%s'", set.Namespace, set.Name))
+
+	log.Info(fmt.Sprintf("Attached %d policies", len(statuses)))
+
+	return ctrl.Result{}, nil
+}
+
+// attachSetEntry attaches a single pair of the set and returns its status. The pair is resolved and attached just like
+// a PolicyAttachment.
+func (r *PolicyAttachmentSetReconciler) attachSetEntry(ctx context.Context, svc iamiface.IAMAPI, set *iamv1beta1.PolicyAttachmentSet, entry iamv1beta1.PolicyAttachmentSetEntry, previous map[string]iamv1beta1.PolicyAttachmentSetEntryStatus) (iamv1beta1.PolicyAttachmentSetEntryStatus, error) {
+	status := iamv1beta1.PolicyAttachmentSetEntryStatus{TargetType: entry.TargetReference.Type}
+	fail := func(err error) (iamv1beta1.PolicyAttachmentSetEntryStatus, error) {
+		status.State = iamv1beta1.ErrorSyncState
+		status.Message = err.Error()
+		return status, err
+	}
+
+	policyattachment := set.PolicyAttachment(entry)
+	policyArn, targetArn, err := getPolicyAttachmentARNs(ctx, policyattachment, r.Client)
+	if err != nil {
+		return fail(err)
+	}
+	status.PolicyARN = policyArn.String()
+	status.TargetARN = targetArn.String()
+
+	// AWS would reject the attachment anyway, but with a far less helpful message
+	if err := checkPolicyAttachmentAccounts(policyArn, targetArn); err != nil {
+		return fail(err)
+	}
+
+	attachType, err := policyattachment.GetAttachmentType()
+	if err != nil {
+		return fail(err)
+	}
+
+	if r.CreateOnly {
+		if prev, ok := previous[setEntryKey(status)]; ok && prev.State == iamv1beta1.OkSyncState {
+			return prev, nil
+		}
+	}
+
+	// attaching an already attached policy is a no-op in AWS, so there is no need to detach first
+	ins := iam.NewPolicyAttachmentInstance(policyArn, attachType, targetArn)
+	if err := ins.Create(svc); err != nil {
+		if awaitsConsistency(ctx, r.Client, policyattachment, err) {
+			status.State = iamv1beta1.SyncSyncState
+			status.Message = fmt.Sprintf("target '%s' is not yet visible in IAM", targetArn.String())
+			return status, nil
+		}
+		return fail(err)
+	}
+
+	status.State = iamv1beta1.OkSyncState
+	status.Message = "Succesfully attached"
+	return status, nil
+}
+
+// detachSetEntry detaches a pair that has been attached by the set; a pair that is gone already is fine, and a pair
+// still attached by someone else stays
+func (r *PolicyAttachmentSetReconciler) detachSetEntry(ctx context.Context, svc iamiface.IAMAPI, set *iamv1beta1.PolicyAttachmentSet, attached iamv1beta1.PolicyAttachmentSetEntryStatus) error {
+	if attached.PolicyARN == "" || attached.TargetARN == "" {
+		return nil
+	}
+	holder, err := attachmentHolder(ctx, r.Client, attached.TargetType, attached.PolicyARN, attached.TargetARN, set)
+	if err != nil {
+		return err
+	}
+	if holder != "" {
+		r.Log.WithValues("policyattachmentset", client.ObjectKeyFromObject(set)).Info(fmt.Sprintf("leaving policy '%s' attached to '%s', as %s attaches it as well", attached.PolicyARN, attached.TargetARN, holder))
+		return nil
+	}
+	policyArn, err := awsarn.Parse(attached.PolicyARN)
+	if err != nil {
+		return err
+	}
+	targetArn, err := awsarn.Parse(attached.TargetARN)
+	if err != nil {
+		return err
+	}
+	policyattachment := iamv1beta1.PolicyAttachment{Spec: iamv1beta1.PolicyAttachmentSpec{TargetReference: iamv1beta1.TargetReference{Type: attached.TargetType}}}
+	attachType, err := policyattachment.GetAttachmentType()
+	if err != nil {
+		return err
+	}
+
+	ins := iam.NewPolicyAttachmentInstance(policyArn, attachType, targetArn)
+	if err := ins.Delete(svc); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == awsiam.ErrCodeNoSuchEntityException {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// setEntryKey identifies an attachment of a set by its policy and target
+func setEntryKey(status iamv1beta1.PolicyAttachmentSetEntryStatus) string {
+	return status.PolicyARN + "|" + status.TargetARN
+}
+
+// setsAttachingTo returns the PolicyAttachmentSets with a pair targeting the given resource. Like PolicyAttachments,
+// they keep the target from being deleted.
+func setsAttachingTo(ctx context.Context, c client.Client, targetType iamv1beta1.TargetType, namespace, name string) ([]iamv1beta1.PolicyAttachmentSet, error) {
+	sets := iamv1beta1.PolicyAttachmentSetList{}
+	if err := c.List(ctx, &sets); err != nil {
+		return nil, err
+	}
+
+	var found []iamv1beta1.PolicyAttachmentSet
+	for _, set := range sets.Items {
+		for _, entry := range set.Spec.Attachments {
+			ref := entry.TargetReference
+			if ref.Type == targetType && ref.Name == name && ref.Namespace == namespace {
+				found = append(found, set)
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+// setsAttachingPolicy returns the PolicyAttachmentSets, that have the given Policy attached via one of their pairs
+func setsAttachingPolicy(ctx context.Context, c client.Client, policy *iamv1beta1.Policy) ([]iamv1beta1.PolicyAttachmentSet, error) {
+	if policy.Status.ARN == "" {
+		return nil, nil
+	}
+	sets := iamv1beta1.PolicyAttachmentSetList{}
+	if err := c.List(ctx, &sets); err != nil {
+		return nil, err
+	}
+
+	var found []iamv1beta1.PolicyAttachmentSet
+	for _, set := range sets.Items {
+		for _, attached := range set.Status.Attachments {
+			if attached.PolicyARN == policy.Status.ARN {
+				found = append(found, set)
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+// policyAttachmentSetsForPolicy maps a Policy to the PolicyAttachmentSets referencing it, so that pairs waiting for it
+// are retried as soon as it changes
+func (r *PolicyAttachmentSetReconciler) policyAttachmentSetsForPolicy(o client.Object) []reconcile.Request {
+	sets := iamv1beta1.PolicyAttachmentSetList{}
+	if err := r.List(context.Background(), &sets); err != nil {
+		r.Log.Error(err, "unable to list PolicyAttachmentSets for Policy", "policy", o.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, set := range sets.Items {
+		for _, entry := range set.Spec.Attachments {
+			if entry.PolicyReference.Name == o.GetName() && entry.PolicyReference.Namespace == o.GetNamespace() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: set.Name, Namespace: set.Namespace}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// policyAttachmentSetsForTarget returns a function mapping a target of the given type to the PolicyAttachmentSets
+// attaching to it, so that pairs waiting for it are retried as soon as it changes
+func (r *PolicyAttachmentSetReconciler) policyAttachmentSetsForTarget(targetType iamv1beta1.TargetType) handler.MapFunc {
+	return func(o client.Object) []reconcile.Request {
+		sets, err := setsAttachingTo(context.Background(), r.Client, targetType, o.GetNamespace(), o.GetName())
+		if err != nil {
+			r.Log.Error(err, "unable to list PolicyAttachmentSets for target", "target", o.GetName())
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, set := range sets {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: set.Name, Namespace: set.Namespace}})
+		}
+		return requests
+	}
+}
+
+func (r *PolicyAttachmentSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&iamv1beta1.PolicyAttachmentSet{}).
+		Watches(&source.Kind{Type: &iamv1beta1.Policy{}}, handler.EnqueueRequestsFromMapFunc(r.policyAttachmentSetsForPolicy)).
+		Watches(&source.Kind{Type: &iamv1beta1.Role{}}, handler.EnqueueRequestsFromMapFunc(r.policyAttachmentSetsForTarget(iamv1beta1.RoleTargetType))).
+		Watches(&source.Kind{Type: &iamv1beta1.User{}}, handler.EnqueueRequestsFromMapFunc(r.policyAttachmentSetsForTarget(iamv1beta1.UserTargetType))).
+		Watches(&source.Kind{Type: &iamv1beta1.Group{}}, handler.EnqueueRequestsFromMapFunc(r.policyAttachmentSetsForTarget(iamv1beta1.GroupTargetType))).
+		Complete(r)
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"context"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+var _ = Describe("PolicyAttachmentSet controller", func() {
+	var (
+		ctx        context.Context
+		fake       *fakeIAM
+		reconciler *PolicyAttachmentSetReconciler
+		role       *iamv1beta1.Role
+		set        *iamv1beta1.PolicyAttachmentSet
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = installFakeIAM()
+		reconciler = &PolicyAttachmentSetReconciler{
+			Client: k8sClient,
+			Log:    ctrl.Log.WithName("controllers").WithName("PolicyAttachmentSet"),
+			Scheme: k8sClient.Scheme(),
+			Region: "eu-west-1",
+		}
+
+		role = newTestRole()
+		createWithStatus(role, func() {
+			role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+			role.Status.State = iamv1beta1.OkSyncState
+		})
+
+		// the second target doesn't exist
+		set = &iamv1beta1.PolicyAttachmentSet{
+			ObjectMeta: metav1.ObjectMeta{Name: uniqueName("set"), Namespace: "default"},
+			Spec: iamv1beta1.PolicyAttachmentSetSpec{Attachments: []iamv1beta1.PolicyAttachmentSetEntry{
+				{
+					ExternalPolicy:  iamv1beta1.ExternalResource{ARN: "arn:aws:iam::aws:policy/ReadOnlyAccess"},
+					TargetReference: iamv1beta1.TargetReference{Type: iamv1beta1.RoleTargetType, Name: role.Name, Namespace: role.Namespace},
+				},
+				{
+					ExternalPolicy:  iamv1beta1.ExternalResource{ARN: "arn:aws:iam::aws:policy/ReadOnlyAccess"},
+					TargetReference: iamv1beta1.TargetReference{Type: iamv1beta1.RoleTargetType, Name: uniqueName("missing"), Namespace: "default"},
+				},
+			}},
+		}
+		Expect(k8sClient.Create(ctx, set)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		uninstallFakeIAM()
+	})
+
+	Context("when some of the pairs fail", func() {
+		It("attaches the others and reports every pair", func() {
+			_, err := reconcileObject(reconciler, set)
+			Expect(err).To(HaveOccurred())
+			Expect(fake.Calls()).To(Equal([]string{"AttachRolePolicy"}))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(set), set)).To(Succeed())
+			Expect(set.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+			Expect(set.Status.Message).To(Equal("1 of 2 attachments failed"))
+			Expect(set.Status.Attachments).To(HaveLen(2))
+			Expect(set.Status.Attachments[0].State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(set.Status.Attachments[0].TargetARN).To(Equal(role.Status.ARN))
+			Expect(set.Status.Attachments[1].State).To(Equal(iamv1beta1.ErrorSyncState))
+		})
+	})
+
+	It("keeps its targets from being deleted", func() {
+		svc, err := IAMService("eu-west-1", false)
+		Expect(err).NotTo(HaveOccurred())
+		roleReconciler := &RoleReconciler{Client: k8sClient}
+
+		err = roleCleanup(roleReconciler, ctx, *role, svc, role.Name)()
+		Expect(err).To(MatchError(ContainSubstring("existing PolicyAttachmentSet")))
+		Expect(fake.Calls()).NotTo(ContainElement("DeleteRole"))
+	})
+
+	Context("when a pair is attached by a PolicyAttachment as well", func() {
+		const (
+			heldArn = "arn:aws:iam::aws:policy/ReadOnlyAccess"
+			onlyArn = "arn:aws:iam::aws:policy/SecurityAudit"
+		)
+		var (
+			overlapping *iamv1beta1.PolicyAttachmentSet
+			detached    []string
+		)
+
+		BeforeEach(func() {
+			attachment := &iamv1beta1.PolicyAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: uniqueName("attachment"), Namespace: "default"},
+				Spec: iamv1beta1.PolicyAttachmentSpec{
+					ExternalPolicy:  iamv1beta1.ExternalResource{ARN: heldArn},
+					TargetReference: iamv1beta1.TargetReference{Type: iamv1beta1.RoleTargetType, Name: role.Name, Namespace: role.Namespace},
+				},
+			}
+			createWithStatus(attachment, func() {
+				attachment.Status.ARN = role.Status.ARN
+				attachment.Status.State = iamv1beta1.OkSyncState
+			})
+
+			// both pairs have been attached, and removed from the spec since
+			overlapping = &iamv1beta1.PolicyAttachmentSet{ObjectMeta: metav1.ObjectMeta{
+				Name:       uniqueName("set"),
+				Namespace:  "default",
+				Finalizers: []string{policyAttachmentSetFinalizer},
+			}}
+			createWithStatus(overlapping, func() {
+				overlapping.Status.State = iamv1beta1.OkSyncState
+				overlapping.Status.ObservedGeneration = overlapping.Generation - 1
+				for _, policyArn := range []string{heldArn, onlyArn} {
+					overlapping.Status.Attachments = append(overlapping.Status.Attachments, iamv1beta1.PolicyAttachmentSetEntryStatus{
+						PolicyARN:  policyArn,
+						TargetARN:  role.Status.ARN,
+						TargetType: iamv1beta1.RoleTargetType,
+						State:      iamv1beta1.OkSyncState,
+					})
+				}
+			})
+
+			fake.respond("ListAttachedRolePolicies", func(r *request.Request) {
+				output := r.Data.(*awsiam.ListAttachedRolePoliciesOutput)
+				output.AttachedPolicies = []*awsiam.AttachedPolicy{
+					{PolicyArn: awssdk.String(heldArn), PolicyName: awssdk.String("ReadOnlyAccess")},
+					{PolicyArn: awssdk.String(onlyArn), PolicyName: awssdk.String("SecurityAudit")},
+				}
+			})
+			detached = nil
+			fake.respond("DetachRolePolicy", func(r *request.Request) {
+				detached = append(detached, awssdk.StringValue(r.Params.(*awsiam.DetachRolePolicyInput).PolicyArn))
+			})
+		})
+
+		It("leaves it attached when it is removed from the set", func() {
+			_, err := reconcileObject(reconciler, overlapping)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detached).To(Equal([]string{onlyArn}))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(overlapping), overlapping)).To(Succeed())
+			Expect(overlapping.Status.State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(overlapping.Status.Attachments).To(BeEmpty())
+		})
+
+		It("leaves it attached when the set is deleted", func() {
+			Expect(k8sClient.Delete(ctx, overlapping)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, overlapping)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detached).To(Equal([]string{onlyArn}))
+		})
+	})
+
+	It("is reconciled when one of its targets changes", func() {
+		requests := reconciler.policyAttachmentSetsForTarget(iamv1beta1.RoleTargetType)(role)
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].NamespacedName).To(Equal(client.ObjectKeyFromObject(set)))
+	})
+})
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// When a policy is converted from an inline policy to a Policy with a PolicyAttachment (or vice versa), both forms
+// are reconciled independently. To never leave the target without the permission, the old form is only removed once
+// the new one is in place.
+
+// how long to wait before checking again, whether the new form of a converted policy is in place
+const conversionRetryInterval = 10 * time.Second
+
+// awaitsReconcile tells whether the latest spec of a resource is yet to be applied. Resources that failed or have
+// been skipped don't count, as they would hold back the conversion forever.
+func awaitsReconcile(generation int64, status *iamv1beta1.AWSObjectStatus) bool {
+	if status.State == iamv1beta1.ErrorSyncState || status.State == iamv1beta1.SkippedSyncState {
+		return false
+	}
+	return status.ObservedGeneration != generation || status.State != iamv1beta1.OkSyncState
+}
+
+// removedInlinePolicies returns the names of the applied inline policies, which are not given in the spec anymore
+func removedInlinePolicies(policies []iamv1beta1.InlinePolicy, applied []string) []string {
+	var removed []string
+	for _, name := range applied {
+		found := false
+		for _, policy := range policies {
+			if policy.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
+
+// pendingPolicyAttachments returns the PolicyAttachments and PolicyAttachmentSets to the given target, which are not
+// yet attached
+func pendingPolicyAttachments(ctx context.Context, c client.Client, targetType iamv1beta1.TargetType, namespace, name string) ([]string, error) {
+	attachments := iamv1beta1.PolicyAttachmentList{}
+	if err := c.List(ctx, &attachments); err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, attachment := range attachments.Items {
+		ref := attachment.Spec.TargetReference
+		if ref.Type != targetType || ref.Name != name || ref.Namespace != namespace {
+			continue
+		}
+		if !attachment.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if awaitsReconcile(attachment.Generation, &attachment.Status) {
+			pending = append(pending, attachment.Namespace+"/"+attachment.Name)
+		}
+	}
+
+	sets, err := setsAttachingTo(ctx, c, targetType, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range sets {
+		if set.ObjectMeta.DeletionTimestamp.IsZero() && awaitsReconcile(set.Generation, &set.Status.AWSObjectStatus) {
+			pending = append(pending, set.Namespace+"/"+set.Name)
+		}
+	}
+	return pending, nil
+}
+
+// inlinePoliciesPending tells whether the target of the PolicyAttachment is yet to apply its inline policies. Only
+// Roles and Users have inline policies.
+func inlinePoliciesPending(ctx context.Context, c client.Client, policyAttachment *iamv1beta1.PolicyAttachment) (bool, error) {
+	key := client.ObjectKey{Name: policyAttachment.Spec.TargetReference.Name, Namespace: policyAttachment.Spec.TargetReference.Namespace}
+
+	switch policyAttachment.Spec.TargetReference.Type {
+	case iamv1beta1.RoleTargetType:
+		target := iamv1beta1.Role{}
+		if err := c.Get(ctx, key, &target); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return target.ObjectMeta.DeletionTimestamp.IsZero() && len(target.Spec.InlinePolicies) != 0 &&
+			awaitsReconcile(target.Generation, &target.Status.AWSObjectStatus), nil
+	case iamv1beta1.UserTargetType:
+		target := iamv1beta1.User{}
+		if err := c.Get(ctx, key, &target); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return target.ObjectMeta.DeletionTimestamp.IsZero() && len(target.Spec.InlinePolicies) != 0 &&
+			awaitsReconcile(target.Generation, &target.Status.AWSObjectStatus), nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// pinPolicyVersions reads the default version of every given policy. Policies pinned before keep their pinned
+// version, all others are pinned to their current one. The ARNs of policies, which have changed since they have been
+// pinned, are returned as drifted.
+func pinPolicyVersions(svc iamiface.IAMAPI, policyArns []string, pinned []iamv1beta1.PinnedPolicyVersion) ([]iamv1beta1.PinnedPolicyVersion, []string, error) {
+	previous := make(map[string]string)
+	for _, version := range pinned {
+		previous[version.ARN] = version.PinnedVersion
+	}
+
+	var versions []iamv1beta1.PinnedPolicyVersion
+	var drifted []string
+	for _, policyArn := range policyArns {
+		out, err := svc.GetPolicy(&awsiam.GetPolicyInput{PolicyArn: awssdk.String(policyArn)})
+		if err != nil {
+			return pinned, nil, err
+		}
+		current := awssdk.StringValue(out.Policy.DefaultVersionId)
+		version := iamv1beta1.PinnedPolicyVersion{ARN: policyArn, PinnedVersion: current, CurrentVersion: current}
+		if pinnedVersion, ok := previous[policyArn]; ok {
+			version.PinnedVersion = pinnedVersion
+		}
+		if version.PinnedVersion != version.CurrentVersion {
+			drifted = append(drifted, fmt.Sprintf("%s (%s -> %s)", policyArn, version.PinnedVersion, version.CurrentVersion))
+		}
+		versions = append(versions, version)
+	}
+	return versions, drifted, nil
+}
+
+// setPolicyVersionDrift sets the PolicyVersionDrift condition of the Role and emits a Warning event when a policy
+// starts to drift
+func (r *RoleReconciler) setPolicyVersionDrift(role *iamv1beta1.Role, drifted []string) {
+	condition := metav1.Condition{
+		Type:               iamv1beta1.PolicyVersionDriftCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: role.Generation,
+		Reason:             "Pinned",
+		Message:            "All attached policies are at their pinned version",
+	}
+	if len(drifted) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Drifted"
+		condition.Message = fmt.Sprintf("Attached policies have changed since they have been pinned: %s", strings.Join(drifted, ", "))
+		if existing := meta.FindStatusCondition(role.Status.Conditions, iamv1beta1.PolicyVersionDriftCondition); existing == nil || existing.Message != condition.Message {
+			r.Notifier.Notify(role, v1.EventTypeWarning, "PolicyVersionDrifted", condition.Message)
+		}
+	}
+	meta.SetStatusCondition(&role.Status.Conditions, condition)
+}
+
+// checkPinnedPolicyVersions refreshes the current versions of the policies attached to the Role. Policies that have
+// been attached since the last check (e.g. via PolicyAttachment) are pinned now.
+func (r *RoleReconciler) checkPinnedPolicyVersions(ctx context.Context, role *iamv1beta1.Role, sw client.StatusWriter) error {
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
+	if err != nil {
+		return err
+	}
+	roleName := r.ResourcePrefix + role.RoleName()
+
+	attached, err := listAttachedRolePolicies(iamsvc, roleName)
+	if err != nil {
+		return err
+	}
+	before := role.Status.DeepCopy()
+	versions, drifted, err := pinPolicyVersions(iamsvc, attached, role.Status.PolicyVersions)
+	if err != nil {
+		return err
+	}
+	role.Status.AttachedPolicies = attached
+	role.Status.PolicyVersions = versions
+	r.setPolicyVersionDrift(role, drifted)
+
+	// don't write anything if nothing changed; otherwise every status write would trigger the next reconcile
+	if reflect.DeepEqual(before, &role.Status) {
+		return nil
+	}
+	return sw.Update(ctx, role)
+}
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/redradrat/cloud-objects/aws"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// selectedPolicyArns returns the sorted ARNs of all Policies selected by the policySelector of the Role. Policies
+// that are not yet created, or are being deleted, are left out.
+func selectedPolicyArns(ctx context.Context, c client.Client, role *iamv1beta1.Role) ([]string, error) {
+	if role.Spec.PolicySelector == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(role.Spec.PolicySelector)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := iamv1beta1.PolicyList{}
+	if err := c.List(ctx, &policies, client.InNamespace(role.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	var arns []string
+	for _, policy := range policies.Items {
+		if policy.Status.ARN == "" || !policy.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		arns = append(arns, policy.Status.ARN)
+	}
+	sort.Strings(arns)
+	return arns, nil
+}
+
+// rolesForPolicy maps a Policy to the Roles that select it, or have it attached from an earlier selection
+func (r *RoleReconciler) rolesForPolicy(o client.Object) []reconcile.Request {
+	policy := o.(*iamv1beta1.Policy)
+
+	roles := iamv1beta1.RoleList{}
+	if err := r.List(context.Background(), &roles, client.InNamespace(policy.Namespace)); err != nil {
+		r.Log.Error(err, "unable to list Roles for Policy", "policy", policy.Name)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, role := range roles.Items {
+		if role.Spec.PolicySelector == nil && len(role.Status.SelectedPolicies) == 0 {
+			continue
+		}
+		selected := false
+		if role.Spec.PolicySelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(role.Spec.PolicySelector)
+			selected = err == nil && selector.Matches(labels.Set(policy.Labels))
+		}
+		if selected || (policy.Status.ARN != "" && containsString(role.Status.SelectedPolicies, policy.Status.ARN)) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: role.Name, Namespace: role.Namespace}})
+		}
+	}
+	return requests
+}
+
+// reconcileSelectedPolicies attaches the Policies that started matching the policySelector of the existing Role, and
+// detaches the ones that stopped matching, without recreating the Role. On a newly created Role, the selected Policies
+// are attached along with everything else.
+func (r *RoleReconciler) reconcileSelectedPolicies(ctx context.Context, role *iamv1beta1.Role, selected []string, sw client.StatusWriter) error {
+	if role.Status.ARN == "" || !role.ObjectMeta.DeletionTimestamp.IsZero() || r.ReadOnly || r.CreateOnly {
+		return nil
+	}
+	if reflect.DeepEqual(role.Status.SelectedPolicies, selected) {
+		return nil
+	}
+
+	// the role in AWS keeps its old name, until a rename has been carried out
+	roleName := r.ResourcePrefix + role.RoleName()
+	oldName, err := renamedRole(role, roleName)
+	if err != nil {
+		return err
+	}
+	if oldName != "" {
+		roleName = oldName
+	}
+
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
+	if err != nil {
+		return err
+	}
+
+	var added, removed []string
+	for _, policyArn := range selected {
+		if !containsString(role.Status.SelectedPolicies, policyArn) {
+			added = append(added, policyArn)
+		}
+	}
+	for _, policyArn := range role.Status.SelectedPolicies {
+		if !containsString(selected, policyArn) {
+			removed = append(removed, policyArn)
+		}
+	}
+
+	attached, err := attachRolePolicies(iamsvc, roleName, added)
+	current := attachedPolicies(attached, role.Status.SelectedPolicies)
+	if err == nil {
+		for _, policyArn := range removed {
+			if err = detachRolePolicies(iamsvc, roleName, []string{policyArn}); err != nil {
+				break
+			}
+			current = removeString(current, policyArn)
+		}
+	}
+	role.Status.SelectedPolicies = current
+	if err != nil {
+		return err
+	}
+	return sw.Update(ctx, role)
+}
+
+func attachRolePolicies(svc iamiface.IAMAPI, roleName string, policyArns []string) ([]string, error) {
+	var attached []string
+	for _, policyArn := range policyArns {
+		if _, err := svc.AttachRolePolicy(&awsiam.AttachRolePolicyInput{
+			PolicyArn: awssdk.String(policyArn),
+			RoleName:  awssdk.String(roleName),
+		}); err != nil {
+			return attached, err
+		}
+		attached = append(attached, policyArn)
+	}
+	return attached, nil
+}
+
+// listAttachedRolePolicies returns the sorted ARNs of all managed policies attached to the Role in AWS
+func listAttachedRolePolicies(svc iamiface.IAMAPI, roleName string) ([]string, error) {
+	var arns []string
+	err := svc.ListAttachedRolePoliciesPages(&awsiam.ListAttachedRolePoliciesInput{
+		RoleName: awssdk.String(roleName),
+	}, func(page *awsiam.ListAttachedRolePoliciesOutput, lastPage bool) bool {
+		for _, policy := range page.AttachedPolicies {
+			arns = append(arns, awssdk.StringValue(policy.PolicyArn))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(arns)
+	return arns, nil
+}
+
+// refreshAttachedPolicies updates the attached policies in the status of the Role, as policies are attached and
+// detached via PolicyAttachments as well, without the Role itself changing
+func (r *RoleReconciler) refreshAttachedPolicies(ctx context.Context, role *iamv1beta1.Role, sw client.StatusWriter) error {
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
+	if err != nil {
+		return err
+	}
+
+	attached, err := listAttachedRolePolicies(iamsvc, r.ResourcePrefix+role.RoleName())
+	if err != nil {
+		return err
+	}
+	// don't write anything if nothing changed; otherwise every status write would trigger the next reconcile
+	if reflect.DeepEqual(role.Status.AttachedPolicies, attached) {
+		return nil
+	}
+	role.Status.AttachedPolicies = attached
+	return sw.Update(ctx, role)
+}
+
+// detachRolePolicies detaches the given policies; attached policies must be gone before the Role can be deleted
+func detachRolePolicies(svc iamiface.IAMAPI, roleName string, policyArns []string) error {
+	for _, policyArn := range policyArns {
+		if _, err := svc.DetachRolePolicy(&awsiam.DetachRolePolicyInput{
+			PolicyArn: awssdk.String(policyArn),
+			RoleName:  awssdk.String(roleName),
+		}); err != nil && !aws.IsNotExistsError(err) {
+			return err
+		}
+	}
+	return nil
+}
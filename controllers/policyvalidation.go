@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/accessanalyzer"
+	"github.com/redradrat/cloud-objects/aws/iam"
+	v1 "k8s.io/api/core/v1"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// finding type of Access Analyzer for policies AWS would reject or that don't work as intended
+const policyValidationErrorFinding = "ERROR"
+
+// The ValidatePolicy operation of IAM Access Analyzer is newer than the AWS SDK we use, so we define it ourselves on
+// top of the Access Analyzer client, which already knows how to sign and (un)marshal its REST/JSON requests.
+
+type validatePolicyInput struct {
+	_ struct{} `type:"structure"`
+
+	NextToken      *string `location:"querystring" locationName:"nextToken" type:"string"`
+	PolicyDocument *string `locationName:"policyDocument" type:"string" required:"true"`
+	PolicyType     *string `locationName:"policyType" type:"string" required:"true"`
+}
+
+type validatePolicyOutput struct {
+	_ struct{} `type:"structure"`
+
+	Findings  []*validatePolicyFinding `locationName:"findings" type:"list"`
+	NextToken *string                  `locationName:"nextToken" type:"string"`
+}
+
+type validatePolicyFinding struct {
+	_ struct{} `type:"structure"`
+
+	FindingDetails *string `locationName:"findingDetails" type:"string"`
+	FindingType    *string `locationName:"findingType" type:"string"`
+	IssueCode      *string `locationName:"issueCode" type:"string"`
+}
+
+// PolicyValidationFinding is a single finding of IAM Access Analyzer for a policy document
+type PolicyValidationFinding struct {
+	// Type is one of ERROR, SECURITY_WARNING, WARNING and SUGGESTION
+	Type      string
+	IssueCode string
+	Details   string
+}
+
+func (f PolicyValidationFinding) String() string {
+	return fmt.Sprintf("%s %s: %s", f.Type, f.IssueCode, f.Details)
+}
+
+// accessAnalyzerClientHook, if set, is applied to every Access Analyzer client used for validation, like iamClientHook
+var accessAnalyzerClientHook func(svc *accessanalyzer.AccessAnalyzer)
+
+// validatePolicyDocument runs the identity policy document through IAM Access Analyzer's ValidatePolicy
+func validatePolicyDocument(region string, doc iam.PolicyDocument) ([]PolicyValidationFinding, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := session.NewSession(&awssdk.Config{Region: awssdk.String(region)})
+	if err != nil {
+		return nil, err
+	}
+	svc := accessanalyzer.New(sess)
+	restrictOperations(&svc.Handlers, "access-analyzer")
+	if accessAnalyzerClientHook != nil {
+		accessAnalyzerClientHook(svc)
+	}
+
+	input := &validatePolicyInput{
+		PolicyDocument: awssdk.String(string(b)),
+		PolicyType:     awssdk.String("IDENTITY_POLICY"),
+	}
+	var findings []PolicyValidationFinding
+	for {
+		output := &validatePolicyOutput{}
+		req := svc.NewRequest(&request.Operation{
+			Name:       "ValidatePolicy",
+			HTTPMethod: "POST",
+			HTTPPath:   "/policy/validation",
+		}, input, output)
+		if err := req.Send(); err != nil {
+			return nil, err
+		}
+		for _, f := range output.Findings {
+			findings = append(findings, PolicyValidationFinding{
+				Type:      awssdk.StringValue(f.FindingType),
+				IssueCode: awssdk.StringValue(f.IssueCode),
+				Details:   awssdk.StringValue(f.FindingDetails),
+			})
+		}
+		if awssdk.StringValue(output.NextToken) == "" {
+			return findings, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// checkPolicyValidation records the findings of Access Analyzer for the document in the status and emits them as
+// events. In strict mode, findings of type ERROR refuse the Policy; otherwise AWS would reject it anyway, or it would
+// not work as intended.
+func (r *PolicyReconciler) checkPolicyValidation(policy *iamv1beta1.Policy, doc iam.PolicyDocument) error {
+	findings, err := validatePolicyDocument(r.Region, doc)
+	if err != nil {
+		return err
+	}
+
+	policy.Status.ValidationFindings = nil
+	var errs []string
+	for _, f := range findings {
+		policy.Status.ValidationFindings = append(policy.Status.ValidationFindings, f.String())
+		eventtype := v1.EventTypeWarning
+		if f.Type == "SUGGESTION" {
+			eventtype = v1.EventTypeNormal
+		}
+		r.Notifier.Notify(policy, eventtype, "PolicyValidationFinding", f.String())
+		if f.Type == policyValidationErrorFinding {
+			errs = append(errs, f.String())
+		}
+	}
+
+	if r.StrictPolicyValidation && len(errs) > 0 {
+		return fmt.Errorf("policy validation found %d errors: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
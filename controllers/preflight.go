@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// RequiredIAMActions lists every IAM action the operator may call while reconciling
+var RequiredIAMActions = []string{
+	"iam:AddUserToGroup",
+	"iam:AttachGroupPolicy",
+	"iam:AttachRolePolicy",
+	"iam:AttachUserPolicy",
+	"iam:CreateAccessKey",
+	"iam:CreateGroup",
+	"iam:CreateLoginProfile",
+	"iam:CreatePolicy",
+	"iam:CreatePolicyVersion",
+	"iam:CreateRole",
+	"iam:CreateServiceSpecificCredential",
+	"iam:CreateUser",
+	"iam:DeleteAccessKey",
+	"iam:DeleteGroup",
+	"iam:DeleteLoginProfile",
+	"iam:DeletePolicy",
+	"iam:DeletePolicyVersion",
+	"iam:DeleteRole",
+	"iam:DeleteRolePermissionsBoundary",
+	"iam:DeleteRolePolicy",
+	"iam:DeleteServiceSpecificCredential",
+	"iam:DeleteUser",
+	"iam:DeleteUserPermissionsBoundary",
+	"iam:DeleteUserPolicy",
+	"iam:DetachGroupPolicy",
+	"iam:DetachRolePolicy",
+	"iam:DetachUserPolicy",
+	"iam:GenerateServiceLastAccessedDetails",
+	"iam:GetAccountSummary",
+	"iam:GetGroup",
+	"iam:GetPolicy",
+	"iam:GetPolicyVersion",
+	"iam:GetRole",
+	"iam:GetServiceLastAccessedDetails",
+	"iam:GetUser",
+	"iam:ListAccessKeys",
+	"iam:ListAttachedGroupPolicies",
+	"iam:ListAttachedRolePolicies",
+	"iam:ListAttachedUserPolicies",
+	"iam:ListPolicyVersions",
+	"iam:ListRolePolicies",
+	"iam:ListRoleTags",
+	"iam:ListServiceSpecificCredentials",
+	"iam:ListUserTags",
+	"iam:PutRolePermissionsBoundary",
+	"iam:PutRolePolicy",
+	"iam:PutUserPermissionsBoundary",
+	"iam:PutUserPolicy",
+	"iam:RemoveUserFromGroup",
+	"iam:TagRole",
+	"iam:TagUser",
+	"iam:UntagUser",
+	"iam:UpdateGroup",
+	"iam:UpdateRole",
+	"iam:UpdateUser",
+}
+
+// PolicyValidationActions lists the actions the operator additionally calls with --validate-policies. Like the IAM
+// actions, they are subject to the allow-list of operations.
+var PolicyValidationActions = []string{
+	"access-analyzer:ValidatePolicy",
+}
+
+// PreflightResult holds the simulated decision for a single action
+type PreflightResult struct {
+	Action   string
+	Decision string
+}
+
+func (r PreflightResult) Allowed() bool {
+	return r.Decision == awsiam.PolicyEvaluationDecisionTypeAllowed
+}
+
+// matches the ARN of an assumed role session, e.g. arn:aws:sts::0000000000:assumed-role/the-role/the-session
+var assumedRoleARN = regexp.MustCompile(`^arn:([^:]+):sts::(\d+):assumed-role/([^/]+)/.+$`)
+
+// RunPreflight simulates all required actions for the identity the operator runs as, and writes an allow/deny report.
+// With validatePolicies, the actions needed for policy validation are simulated as well. It returns whether all
+// actions are allowed.
+func RunPreflight(region string, validatePolicies bool, out io.Writer) (bool, error) {
+	sess, err := session.NewSession(&awssdk.Config{Region: awssdk.String(region)})
+	if err != nil {
+		return false, err
+	}
+	iamsvc := awsiam.New(sess)
+
+	principal, err := preflightPrincipal(sts.New(sess), iamsvc)
+	if err != nil {
+		return false, err
+	}
+	actions := RequiredIAMActions
+	if validatePolicies {
+		actions = append(append([]string{}, actions...), PolicyValidationActions...)
+	}
+	results, err := Preflight(iamsvc, principal, actions)
+	if err != nil {
+		return false, err
+	}
+	return WritePreflightReport(out, principal, results), nil
+}
+
+// preflightPrincipal returns the IAM ARN of the calling identity. As policies can't be simulated for an assumed
+// role session, we resolve it to the ARN of its role.
+func preflightPrincipal(stssvc stsiface.STSAPI, iamsvc iamiface.IAMAPI) (string, error) {
+	identity, err := stssvc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	arn := awssdk.StringValue(identity.Arn)
+
+	m := assumedRoleARN.FindStringSubmatch(arn)
+	if m == nil {
+		return arn, nil
+	}
+	// the role might live in a path, which isn't part of the session ARN
+	if role, err := iamsvc.GetRole(&awsiam.GetRoleInput{RoleName: awssdk.String(m[3])}); err == nil {
+		return awssdk.StringValue(role.Role.Arn), nil
+	}
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", m[1], m[2], m[3]), nil
+}
+
+// Preflight simulates the given actions for the principal via SimulatePrincipalPolicy
+func Preflight(svc iamiface.IAMAPI, principalArn string, actions []string) ([]PreflightResult, error) {
+	var results []PreflightResult
+	err := svc.SimulatePrincipalPolicyPages(&awsiam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: awssdk.String(principalArn),
+		ActionNames:     awssdk.StringSlice(actions),
+	}, func(page *awsiam.SimulatePolicyResponse, lastPage bool) bool {
+		for _, res := range page.EvaluationResults {
+			results = append(results, PreflightResult{
+				Action:   awssdk.StringValue(res.EvalActionName),
+				Decision: awssdk.StringValue(res.EvalDecision),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Action < results[j].Action })
+	return results, nil
+}
+
+// WritePreflightReport writes one line per action and a summary. It returns whether all actions are allowed.
+func WritePreflightReport(out io.Writer, principalArn string, results []PreflightResult) bool {
+	denied := 0
+	fmt.Fprintf(out, "Simulated %d actions for '%s':\n", len(results), principalArn)
+	for _, res := range results {
+		verdict := "ALLOW"
+		if !res.Allowed() {
+			verdict = "DENY "
+			denied++
+		}
+		fmt.Fprintf(out, "  %s %s (%s)\n", verdict, res.Action, res.Decision)
+	}
+	if denied > 0 {
+		fmt.Fprintf(out, "%d of %d required actions are denied\n", denied, len(results))
+		return false
+	}
+	fmt.Fprintln(out, "All required actions are allowed")
+	return true
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"bytes"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Preflight", func() {
+	var (
+		fake *fakeIAM
+		svc  *awsiam.IAM
+	)
+
+	BeforeEach(func() {
+		fake = installFakeIAM()
+		var err error
+		svc, err = IAMService("eu-west-1", true)
+		Expect(err).NotTo(HaveOccurred())
+
+		// everything but deleting roles is allowed
+		fake.respond("SimulatePrincipalPolicy", func(r *request.Request) {
+			input := r.Params.(*awsiam.SimulatePrincipalPolicyInput)
+			output := r.Data.(*awsiam.SimulatePolicyResponse)
+			for _, action := range input.ActionNames {
+				decision := awsiam.PolicyEvaluationDecisionTypeAllowed
+				if awssdk.StringValue(action) == "iam:DeleteRole" {
+					decision = awsiam.PolicyEvaluationDecisionTypeImplicitDeny
+				}
+				output.EvaluationResults = append(output.EvaluationResults, &awsiam.EvaluationResult{
+					EvalActionName: action,
+					EvalDecision:   awssdk.String(decision),
+				})
+			}
+		})
+	})
+
+	AfterEach(func() {
+		uninstallFakeIAM()
+	})
+
+	It("reports the denied actions", func() {
+		principal := "arn:aws:iam::123456789012:role/operator"
+		results, err := Preflight(svc, principal, RequiredIAMActions)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(len(RequiredIAMActions)))
+		Expect(fake.Calls()).To(Equal([]string{"SimulatePrincipalPolicy"}))
+
+		out := &bytes.Buffer{}
+		Expect(WritePreflightReport(out, principal, results)).To(BeFalse())
+		Expect(out.String()).To(ContainSubstring("DENY  iam:DeleteRole (implicitDeny)"))
+		Expect(out.String()).To(ContainSubstring("ALLOW iam:CreateRole (allowed)"))
+		Expect(out.String()).To(ContainSubstring("1 of %d required actions are denied", len(RequiredIAMActions)))
+	})
+
+	It("reports success if all actions are allowed", func() {
+		results, err := Preflight(svc, "arn:aws:iam::123456789012:role/operator", []string{"iam:CreateRole", "iam:GetRole"})
+		Expect(err).NotTo(HaveOccurred())
+
+		out := &bytes.Buffer{}
+		Expect(WritePreflightReport(out, "arn:aws:iam::123456789012:role/operator", results)).To(BeTrue())
+		Expect(out.String()).To(ContainSubstring("All required actions are allowed"))
+	})
+})
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// usage ratio of an IAM quota, from which on a warning is logged
+const quotaWarningRatio = 0.9
+
+var (
+	quotaUsage = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "aws_iam_quota_usage",
+		Help: "Number of IAM entities in use in the account, as reported by GetAccountSummary",
+	}, []string{"entity"})
+	quotaLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "aws_iam_quota_limit",
+		Help: "IAM quota of the account for the entity, as reported by GetAccountSummary",
+	}, []string{"entity"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(quotaUsage, quotaLimit)
+}
+
+// QuotaMonitor periodically reads the IAM account summary and exposes the usage of every IAM quota as metrics, so
+// one can alert before creations start failing with LimitExceeded.
+type QuotaMonitor struct {
+	Region   string
+	Interval time.Duration
+	Log      logr.Logger
+}
+
+// Start implements manager.Runnable; it reads the account summary every interval until the context is done
+func (m *QuotaMonitor) Start(ctx context.Context) error {
+	// the account summary is read only, so read-only mode doesn't matter here
+	iamsvc, err := IAMService(m.Region, true)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		if err := recordQuotaUsage(iamsvc, m.Log); err != nil {
+			m.Log.Error(err, "unable to read IAM account summary")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordQuotaUsage sets the usage and limit metrics of every entity in the account summary that comes with a quota
+// (e.g. Roles and RolesQuota), and logs the ones close to their limit
+func recordQuotaUsage(svc iamiface.IAMAPI, log logr.Logger) error {
+	out, err := svc.GetAccountSummary(&awsiam.GetAccountSummaryInput{})
+	if err != nil {
+		return err
+	}
+
+	for key, limit := range out.SummaryMap {
+		if !strings.HasSuffix(key, "Quota") {
+			continue
+		}
+		entity := strings.TrimSuffix(key, "Quota")
+		usage, ok := out.SummaryMap[entity]
+		if !ok {
+			// quotas per entity (e.g. AttachedPoliciesPerRoleQuota) have no account-wide usage
+			continue
+		}
+		quotaUsage.WithLabelValues(entity).Set(float64(awssdk.Int64Value(usage)))
+		quotaLimit.WithLabelValues(entity).Set(float64(awssdk.Int64Value(limit)))
+
+		if awssdk.Int64Value(limit) > 0 && float64(awssdk.Int64Value(usage)) >= quotaWarningRatio*float64(awssdk.Int64Value(limit)) {
+			log.Info(fmt.Sprintf("IAM quota for %s is almost exhausted: %d of %d in use", entity, awssdk.Int64Value(usage), awssdk.Int64Value(limit)))
+		}
+	}
+	return nil
+}
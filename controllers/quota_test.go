@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("QuotaMonitor", func() {
+	var fake *fakeIAM
+
+	BeforeEach(func() {
+		fake = installFakeIAM()
+	})
+
+	AfterEach(func() {
+		uninstallFakeIAM()
+	})
+
+	It("exposes the usage and limit of every account-wide quota from the account summary", func() {
+		fake.respond("GetAccountSummary", func(r *request.Request) {
+			r.Data.(*awsiam.GetAccountSummaryOutput).SummaryMap = map[string]*int64{
+				"Roles":                        awssdk.Int64(950),
+				"RolesQuota":                   awssdk.Int64(1000),
+				"Policies":                     awssdk.Int64(12),
+				"PoliciesQuota":                awssdk.Int64(1500),
+				"AttachedPoliciesPerRoleQuota": awssdk.Int64(10),
+			}
+		})
+		monitor := &QuotaMonitor{Region: "eu-west-1", Interval: time.Hour, Log: ctrl.Log.WithName("QuotaMonitor")}
+
+		// a done context makes the monitor return after reading the summary once
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(monitor.Start(ctx)).To(Succeed())
+		Expect(fake.Calls()).To(Equal([]string{"GetAccountSummary"}))
+
+		Expect(testutil.ToFloat64(quotaUsage.WithLabelValues("Roles"))).To(Equal(950.0))
+		Expect(testutil.ToFloat64(quotaLimit.WithLabelValues("Roles"))).To(Equal(1000.0))
+		Expect(testutil.ToFloat64(quotaUsage.WithLabelValues("Policies"))).To(Equal(12.0))
+		Expect(testutil.ToFloat64(quotaLimit.WithLabelValues("Policies"))).To(Equal(1500.0))
+		Expect(testutil.CollectAndCount(quotaLimit)).To(Equal(2))
+	})
+})
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/redradrat/cloud-objects/aws"
+	"github.com/redradrat/cloud-objects/aws/iam"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// renamedRole returns the name of the role in AWS, if it differs from the given (new) name
+func renamedRole(role *iamv1beta1.Role, roleName string) (string, error) {
+	if role.Status.ARN == "" {
+		return "", nil
+	}
+	parsedArn, err := awsarn.Parse(role.Status.ARN)
+	if err != nil {
+		return "", fmt.Errorf("ARN in Role status is not valid/parsable")
+	}
+	if oldName := iam.FriendlyNamefromARN(parsedArn); oldName != roleName {
+		return oldName, nil
+	}
+	return "", nil
+}
+
+// migrateRoleAttachments attaches all managed policies of the old role to the new one, so the renamed role is
+// granted the same permissions before the old one is gone
+func migrateRoleAttachments(svc iamiface.IAMAPI, oldArn, newName string) error {
+	parsedArn, err := awsarn.Parse(oldArn)
+	if err != nil {
+		return err
+	}
+	arns, err := listAttachedRolePolicies(svc, iam.FriendlyNamefromARN(parsedArn))
+	if err != nil {
+		if aws.IsNotExistsError(err) {
+			return nil
+		}
+		return err
+	}
+	_, err = attachRolePolicies(svc, newName, arns)
+	return err
+}
+
+// deleteRenamedRole deletes the role replaced by a renamed one, including its attached and inline policies, as AWS
+// refuses to delete roles with policies. Deletion protection applies to the old role as well.
+func deleteRenamedRole(svc iamiface.IAMAPI, roleArn string, protectionTag string) error {
+	parsedArn, err := awsarn.Parse(roleArn)
+	if err != nil {
+		return err
+	}
+	roleName := iam.FriendlyNamefromARN(parsedArn)
+
+	if err := checkDeletionProtection(svc, iamv1beta1.RoleTargetType, roleName, protectionTag); err != nil {
+		return err
+	}
+
+	arns, err := listAttachedRolePolicies(svc, roleName)
+	if err != nil {
+		if aws.IsNotExistsError(err) {
+			return nil
+		}
+		return err
+	}
+	if err := detachRolePolicies(svc, roleName, arns); err != nil {
+		return err
+	}
+
+	var inline []string
+	if err := svc.ListRolePoliciesPages(&awsiam.ListRolePoliciesInput{
+		RoleName: awssdk.String(roleName),
+	}, func(page *awsiam.ListRolePoliciesOutput, lastPage bool) bool {
+		inline = append(inline, awssdk.StringValueSlice(page.PolicyNames)...)
+		return true
+	}); err != nil {
+		return err
+	}
+	if err := deleteInlinePolicies(svc, iamv1beta1.RoleTargetType, roleName, inline); err != nil {
+		return err
+	}
+
+	if _, err := svc.DeleteRole(&awsiam.DeleteRoleInput{RoleName: awssdk.String(roleName)}); err != nil && !aws.IsNotExistsError(err) {
+		return err
+	}
+	return nil
+}
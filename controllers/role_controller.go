@@ -23,20 +23,29 @@ import (
 	"strings"
 	"time"
 
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/go-logr/logr"
 	"github.com/redradrat/cloud-objects/aws"
 	"github.com/redradrat/cloud-objects/aws/iam"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
 )
 
+// annotation holding the hash of the last applied Role spec without its inline policies, which are applied in place
+const lastAppliedRoleHashAnnotation = "aws-iam.redradrat.xyz/last-applied-role-hash"
+
 // RoleReconciler reconciles a Role object
 type RoleReconciler struct {
 	client.Client
@@ -46,6 +55,24 @@ type RoleReconciler struct {
 	Scheme          *runtime.Scheme
 	ResourcePrefix  string
 	OidcProviderARN string
+	Debouncer       *Debouncer
+	Notifier        *Notifier
+	ReadOnly        bool
+	CreateOnly      bool
+	// MaxManagedEntities caps the number of AWS entities the operator creates; 0 disables the cap
+	MaxManagedEntities int
+	// GuardBoundaryRemoval requires the removal of a permissions boundary to be confirmed via annotation
+	GuardBoundaryRemoval bool
+	// ProtectionTag protects AWS entities carrying this tag key from deletion; empty disables the protection
+	ProtectionTag string
+	// OwnerTagFormat is the format of the owning-app tag value; ${app} stands for the name of the owning application
+	OwnerTagFormat string
+	// UniqueRoleNames refuses Roles whose AWS name is already used by another Role in the cluster
+	UniqueRoleNames bool
+	// UnusedWindow flags Roles that have not been used within this duration; 0 disables the check
+	UnusedWindow time.Duration
+	// TrustPolicySizeLimit is the size (in characters) AWS accepts for trust policies; 0 means the default limit
+	TrustPolicySizeLimit int
 }
 
 // +kubebuilder:rbac:groups=aws-iam.redradrat.xyz,resources=roles,verbs=get;list;watch;create;update;patch;delete
@@ -66,33 +93,142 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	err := r.Get(ctx, req.NamespacedName, &role)
 	if err != nil {
 		log.V(1).Info("unable to fetch Role")
+		r.Debouncer.Forget(req.NamespacedName)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	sw := newStatusPatcher(r.Status(), &role)
+
+	// critical Roles may want to be checked more often than others
+	interval := resyncPeriod(&role, r.Interval)
+
+	// ephemeral Roles, that haven't been refreshed in time, are cleaned up instead of reconciled
+	if role.ObjectMeta.DeletionTimestamp.IsZero() && roleExpired(&role) {
+		return ctrl.Result{RequeueAfter: interval}, r.expireRole(ctx, &role, sw)
+	}
+	meta.RemoveStatusCondition(&role.Status.Conditions, iamv1beta1.ExpiredCondition)
+
+	// the namespace may cap the session duration at any time, so this is checked even if the Role didn't change
+	if role.ObjectMeta.DeletionTimestamp.IsZero() {
+		if err := checkMaxSessionDuration(ctx, r.Client, &role); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+	}
+
 	// get the policy doc
 	polDoc, resVer, err := getPolicyDoc(&role, r.OidcProviderARN, r.Client, ctx)
 	if err != nil {
-		return ctrl.Result{}, errWithStatus(ctx, &role, err, r.Status())
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+
+	// the expected session policies are advisory, so they only go into the status
+	role.Status.SessionPolicies, err = sessionPolicyArns(&role)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
 	}
 
+	// the set of selected Policies can change without the Role changing, so they are attached and detached in place
+	selectedPolicies, err := selectedPolicyArns(ctx, r.Client, &role)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+	if err := r.reconcileSelectedPolicies(ctx, &role, selectedPolicies, sw); err != nil {
+		log.Error(err, "unable to attach selected Policies to Role")
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+
+	// the referenced trust policy and the annotations used by templates are part of what we apply, so they go into the
+	// hash as well; the session policies are not applied to the role, so changing them must not recreate it
+	hashedSpec := role.Spec
+	hashedSpec.SessionPolicies = nil
+	templated := templateAnnotations(&role, role.Spec.Description, r.OwnerTagFormat)
+	extra := append([]string{resVer}, templated...)
+	hash, err := specHash(hashedSpec, extra...)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+	// inline policies can be changed without recreating the role, so we tell their changes from the rest
+	hashedSpec.InlinePolicies = nil
+	roleHash, err := specHash(hashedSpec, extra...)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+
+	// annotations don't change the generation, so changes of the ones used by templates are told by the hash
 	reconcileUnneccessary :=
 		role.Status.ObservedGeneration == role.ObjectMeta.Generation &&
 			role.Status.State == iamv1beta1.OkSyncState &&
-			role.Status.ReadAssumeRolePolicyVersion == resVer
+			role.Status.ReadAssumeRolePolicyVersion == resVer &&
+			(len(templated) == 0 || role.ObjectMeta.Annotations[lastAppliedSpecHashAnnotation] == hash)
 
 	if reconcileUnneccessary {
-		return ctrl.Result{RequeueAfter: r.Interval}, nil
+		// someone removing or changing the boundary (e.g. in the console) is a privilege escalation
+		if role.Spec.PermissionsBoundary != "" {
+			if err := r.reapplyDriftedBoundary(ctx, &role, sw); err != nil {
+				log.Error(err, "unable to check permissions boundary of Role for drift")
+				return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+			}
+		}
+		if r.UnusedWindow > 0 {
+			if err := r.flagUnusedRole(ctx, &role, sw); err != nil {
+				log.Error(err, "unable to check when Role has last been used")
+				return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+			}
+		}
+		// checking the pinned versions refreshes the attached policies as well
+		if role.Spec.PinPolicyVersions {
+			if err := r.checkPinnedPolicyVersions(ctx, &role, sw); err != nil {
+				log.Error(err, "unable to check pinned policy versions of Role")
+				return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+			}
+		} else if err := r.refreshAttachedPolicies(ctx, &role, sw); err != nil {
+			log.Error(err, "unable to list attached policies of Role")
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+		return ctrl.Result{RequeueAfter: interval}, nil
 	} else {
 		role.Status.ReadAssumeRolePolicyVersion = resVer
 	}
 
+	// return if the spec is identical to the last applied one (e.g. an unchanged manifest has been re-applied)
+	if role.ObjectMeta.DeletionTimestamp.IsZero() && lastAppliedSpecMatches(&role, hash) {
+		return ctrl.Result{RequeueAfter: interval}, observeGeneration(ctx, &role, sw)
+	}
+
+	// wait for rapid consecutive spec changes to settle, before we talk to AWS
+	if role.ObjectMeta.DeletionTimestamp.IsZero() {
+		if wait := r.Debouncer.Wait(req.NamespacedName, role.ObjectMeta.Generation); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	// don't act before the gate is open; deletions are never held back
+	if role.ObjectMeta.DeletionTimestamp.IsZero() {
+		reason, err := gateClosed(ctx, r.Client, role.Spec.Gate, role.Namespace)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+		if reason != "" {
+			log.Info(reason)
+			return ctrl.Result{RequeueAfter: gateRetryInterval}, nil
+		}
+	}
+
+	// AWS rejects trust policies over the limit, so there's no point in sending one; compaction can't help any further
+	if role.ObjectMeta.DeletionTimestamp.IsZero() {
+		if err := r.checkTrustPolicySize(polDoc); err != nil {
+			r.Notifier.Notify(&role, v1.EventTypeWarning, "TrustPolicyTooLarge", err.Error())
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+	}
+
 	// the finalizer for deleting the actual aws resources
 	rolesFinalizer := "role.aws-iam.redradrat.xyz"
 
 	// Get our actual IAM Service to communicate with AWS; we don't need to continue without it
-	iamsvc, err := IAMService(r.Region)
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
 	if err != nil {
-		return ctrl.Result{}, errWithStatus(ctx, &role, err, r.Status())
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
 	}
 
 	// new role instance
@@ -102,17 +238,32 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	if role.Spec.MaxSessionDuration != nil {
 		duration = *role.Spec.MaxSessionDuration
 	}
+	// the description doesn't matter for the deletion
+	description, err := expandTemplate(role.Spec.Description, &role)
+	if err != nil && role.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
 	if role.Status.ARN != "" {
 		parsedArn, err := aws.ARNify(role.Status.ARN)
 		if err != nil {
-			return ctrl.Result{}, errWithStatus(ctx, &role, fmt.Errorf("ARN in Role status is not valid/parsable"), r.Status())
+			return ctrl.Result{}, errWithStatus(ctx, &role, fmt.Errorf("ARN in Role status is not valid/parsable"), sw)
 		}
-		ins = iam.NewExistingRoleInstance(roleName, role.Spec.Description, duration, polDoc, parsedArn[len(parsedArn)-1])
+		ins = iam.NewExistingRoleInstance(roleName, description, duration, polDoc, parsedArn[len(parsedArn)-1])
 	} else {
-		ins = iam.NewRoleInstance(roleName, role.Spec.Description, duration, polDoc)
+		ins = iam.NewRoleInstance(roleName, description, duration, polDoc)
 	}
 
-	cleanupFunc := roleCleanup(r, ctx, role)
+	// the role in AWS keeps its old name, until a rename has been carried out
+	oldName, err := renamedRole(&role, roleName)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+	awsRoleName := roleName
+	if oldName != "" {
+		awsRoleName = oldName
+	}
+
+	cleanupFunc := roleCleanup(r, ctx, role, iamsvc, awsRoleName)
 
 	// Check Deletion and finalizer
 	if role.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -129,15 +280,31 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	} else {
 		if containsString(role.ObjectMeta.Finalizers, rolesFinalizer) {
 			// our finalizer is present, so lets handle any external dependency
+			if r.ReadOnly {
+				return ctrl.Result{RequeueAfter: interval}, skipWithStatus(ctx, &role, fmt.Sprintf("read-only mode: would delete Role '%s'", roleName), sw)
+			}
 
-			// delete the actual AWS Object and pass the cleanup function
-			statusUpdater, err := DeleteAWSObject(iamsvc, ins, cleanupFunc)
-			// we got a StatusUpdater function returned... let's execute it
-			statusUpdater(ctx, ins, &role, r.Status(), log)
-			if err != nil {
-				// we had an error during AWS Object deletion... so we return here to retry
-				log.Error(err, "unable to delete Role")
-				return ctrl.Result{}, err
+			if r.CreateOnly {
+				// leave the AWS object untouched, but let the resource go
+				log.Info(fmt.Sprintf("create-only mode: leaving Role '%s' in AWS", roleName))
+			} else {
+				// a role that is being replaced by a renamed one has to go as well
+				if role.Status.RenamedFromARN != "" {
+					if err := deleteRenamedRole(iamsvc, role.Status.RenamedFromARN, r.ProtectionTag); err != nil {
+						log.Error(err, "unable to delete Role replaced by renamed Role")
+						return ctrl.Result{}, err
+					}
+				}
+
+				// delete the actual AWS Object and pass the cleanup function
+				statusUpdater, err := DeleteAWSObject(iamsvc, ins, cleanupFunc)
+				// we got a StatusUpdater function returned... let's execute it
+				statusUpdater(ctx, ins, &role, sw, log)
+				if err != nil {
+					// we had an error during AWS Object deletion... so we return here to retry
+					log.Error(err, "unable to delete Role")
+					return ctrl.Result{}, err
+				}
 			}
 
 			// remove our finalizer from the list and update it.
@@ -146,21 +313,84 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 				log.Error(err, "unable to remove finalizer from Role")
 				return ctrl.Result{}, err
 			}
+			if !r.CreateOnly {
+				r.Notifier.Notify(&role, v1.EventTypeNormal, "Deleted", fmt.Sprintf("Deleted Role '%s'", role.Status.ARN))
+			}
 		}
 
 		// Stop reconciliation as the item is being deleted
-		return ctrl.Result{RequeueAfter: r.Interval}, nil
+		return ctrl.Result{RequeueAfter: interval}, nil
 	}
 
 	// RECONCILE THE RESOURCE
 
+	if r.ReadOnly {
+		action := "create"
+		if role.Status.ARN != "" {
+			action = "recreate"
+		}
+		return ctrl.Result{RequeueAfter: interval}, skipWithStatus(ctx, &role, fmt.Sprintf("read-only mode: would %s Role '%s'", action, roleName), sw)
+	}
+
+	if r.CreateOnly && role.Status.ARN != "" {
+		return ctrl.Result{RequeueAfter: interval}, skipWithStatus(ctx, &role, fmt.Sprintf("create-only mode: not recreating existing Role '%s'", roleName), sw)
+	}
+
+	// AWS can't rename roles in place, so a renamed role has to be replaced by a new one
+	if oldName != "" && role.Spec.RenameStrategy != iamv1beta1.RecreateRenameStrategy {
+		return ctrl.Result{}, errWithStatus(ctx, &role, fmt.Errorf("cannot rename Role '%s' to '%s' in place; set renameStrategy to Recreate to replace it", oldName, roleName), sw)
+	}
+
+	// a renamed Role replaces its old role, so it doesn't count as another entity
+	if role.Status.ARN == "" {
+		if err := checkManagedEntityCap(ctx, r.Client, r.MaxManagedEntities); err != nil {
+			r.Notifier.Notify(&role, v1.EventTypeWarning, "ManagedEntityCapReached", err.Error())
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+	}
+
+	if r.UniqueRoleNames {
+		if err := checkRoleNameUnique(ctx, r.Client, &role); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+	}
+
+	// this has to happen before the Role is recreated, as the new Role would come without the boundary anyway
+	if r.GuardBoundaryRemoval {
+		if err := checkBoundaryRemoval(&role, role.Spec.PermissionsBoundary); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+	}
+
+	// refuse invalid inline policies before we touch the existing Role
+	if _, _, err := inlinePolicyDocuments(iamv1beta1.RoleTargetType, role.Spec.InlinePolicies); err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+
+	// if nothing but the inline policies changed, they are updated in place, so the Role never lacks a permission
+	if role.Status.ARN != "" && oldName == "" && role.ObjectMeta.Annotations[lastAppliedRoleHashAnnotation] == roleHash {
+		return r.reconcileRoleInlinePolicies(ctx, &role, iamsvc, ins, roleName, hash, roleHash, interval, sw)
+	}
+
+	// the old role stays until the new one has taken over its policies, so we track both; only now that every check
+	// has passed, as a refused rename must leave the Role as it is
+	if oldName != "" {
+		role.Status.RenamedFromARN = role.Status.ARN
+		role.Status.ARN = ""
+		if err := sw.Update(ctx, &role); err != nil {
+			return ctrl.Result{}, err
+		}
+		ins = iam.NewRoleInstance(roleName, description, duration, polDoc)
+		log.Info(fmt.Sprintf("Replacing Role '%s' with renamed Role '%s'", oldName, roleName))
+	}
+
 	// if there is already an ARN in our status, then we recreate the object completely
 	// (because AWS only supports description updates)
 	if role.Status.ARN != "" {
 		// delete the actual AWS Object and pass the cleanup function
 		statusUpdater, err := DeleteAWSObject(iamsvc, ins, cleanupFunc)
 		// we got a StatusUpdater function returned... let's execute it
-		statusUpdater(ctx, ins, &role, r.Status(), log)
+		statusUpdater(ctx, ins, &role, sw, log)
 		if err != nil {
 			// we had an error during AWS Object deletion... so we return here to retry
 			log.Error(err, "error while deleting Role during reconciliation")
@@ -169,7 +399,7 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	}
 
 	statusUpdater, err := CreateAWSObject(iamsvc, ins, DoNothingPreFunc)
-	statusUpdater(ctx, ins, &role, r.Status(), log)
+	statusUpdater(ctx, ins, &role, sw, log)
 	if err != nil {
 		log.Error(err, "error while creating Role during reconciliation")
 		return ctrl.Result{}, err
@@ -177,6 +407,67 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 
 	log.Info(fmt.Sprintf("Created Role '%s'", role.Status.ARN))
 
+	if err := reconcilePermissionsBoundary(iamsvc, &role, iamv1beta1.RoleTargetType, roleName, role.Spec.PermissionsBoundary); err != nil {
+		log.Error(err, "unable to apply permissions boundary to Role")
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+
+	// the renamed Role takes over the managed policies attached to the one it replaces
+	if role.Status.RenamedFromARN != "" {
+		if err := migrateRoleAttachments(iamsvc, role.Status.RenamedFromARN, roleName); err != nil {
+			log.Error(err, "unable to migrate attached policies to renamed Role")
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+	}
+
+	// the Role has just been created, so there are no previously applied inline policies left
+	applied, size, err := reconcileInlinePolicies(iamsvc, iamv1beta1.RoleTargetType, roleName, role.Spec.InlinePolicies, nil, false)
+	role.Status.InlinePolicies = applied
+	role.Status.InlinePolicySize = size
+	if err != nil {
+		log.Error(err, "unable to apply inline policies to Role")
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+
+	role.Status.SelectedPolicies, err = attachRolePolicies(iamsvc, roleName, selectedPolicies)
+	if err != nil {
+		log.Error(err, "unable to attach selected Policies to Role")
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+
+	if role.Status.AttachedPolicies, err = listAttachedRolePolicies(iamsvc, roleName); err != nil {
+		log.Error(err, "unable to list attached policies of Role")
+		return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+	}
+
+	// the Role has just been (re)created, so every attached policy is pinned anew
+	role.Status.PolicyVersions = nil
+	meta.RemoveStatusCondition(&role.Status.Conditions, iamv1beta1.PolicyVersionDriftCondition)
+	if role.Spec.PinPolicyVersions {
+		versions, drifted, err := pinPolicyVersions(iamsvc, role.Status.AttachedPolicies, nil)
+		if err != nil {
+			log.Error(err, "unable to pin policy versions of Role")
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+		role.Status.PolicyVersions = versions
+		r.setPolicyVersionDrift(&role, drifted)
+	}
+
+	// attribute the Role in AWS to the application it belongs to in Kubernetes
+	if app := owningApp(ctx, r.Client, &role); app != "" {
+		value, err := ownerTagValue(r.OwnerTagFormat, app, &role)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+		if _, err := iamsvc.TagRole(&awsiam.TagRoleInput{
+			RoleName: awssdk.String(roleName),
+			Tags:     []*awsiam.Tag{{Key: awssdk.String(owningAppTagKey), Value: awssdk.String(value)}},
+		}); err != nil {
+			log.Error(err, "unable to tag Role with its owning application")
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+	}
+
 	truevar := true
 	gvk, err := apiutil.GVKForObject(&role, r.Scheme)
 	if err != nil {
@@ -197,18 +488,133 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// only now that the renamed Role is complete, the one it replaces can go
+	if role.Status.RenamedFromARN != "" {
+		if err := deleteRenamedRole(iamsvc, role.Status.RenamedFromARN, r.ProtectionTag); err != nil {
+			log.Error(err, "unable to delete Role replaced by renamed Role")
+			return ctrl.Result{}, errWithStatus(ctx, &role, err, sw)
+		}
+		r.Notifier.Notify(&role, v1.EventTypeNormal, "Renamed", fmt.Sprintf("Replaced Role '%s' with '%s'", role.Status.RenamedFromARN, role.Status.ARN))
+		role.Status.RenamedFromARN = ""
+	}
+
 	// Update Generation
-	role.Status.ObservedGeneration = role.ObjectMeta.Generation
-	if err := r.Status().Update(ctx, &role); err != nil {
+	role.Status.ObservedGeneration = sw.generation
+	if err := sw.Update(ctx, &role); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := storeLastAppliedRoleHashes(ctx, r.Client, &role, hash, roleHash); err != nil {
+		log.Error(err, "unable to store last applied spec hash for Role")
 		return ctrl.Result{}, err
 	}
+	r.Notifier.Notify(&role, v1.EventTypeNormal, "Reconciled", fmt.Sprintf("Reconciled Role '%s'", role.Status.ARN))
 
-	return ctrl.Result{RequeueAfter: r.Interval}, nil
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// reconcileRoleInlinePolicies applies the inline policies of the spec to the existing Role. New and changed
+// policies are put before removed ones are deleted; an inline policy converted to a managed one stays in place, until
+// its replacement is attached.
+func (r *RoleReconciler) reconcileRoleInlinePolicies(ctx context.Context, role *iamv1beta1.Role, svc iamiface.IAMAPI, ins *iam.RoleInstance, roleName, hash, roleHash string, interval time.Duration, sw client.StatusWriter) (ctrl.Result, error) {
+	log := r.Log.WithValues("role", client.ObjectKeyFromObject(role))
+
+	keepRemoved := false
+	if removed := removedInlinePolicies(role.Spec.InlinePolicies, role.Status.InlinePolicies); len(removed) != 0 {
+		pending, err := pendingPolicyAttachments(ctx, r.Client, iamv1beta1.RoleTargetType, role.Namespace, role.Name)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, role, err, sw)
+		}
+		if len(pending) != 0 {
+			log.Info(fmt.Sprintf("keeping inline policies %v of Role until PolicyAttachments %v are attached", removed, pending))
+			keepRemoved = true
+		}
+	}
+
+	applied, size, err := reconcileInlinePolicies(svc, iamv1beta1.RoleTargetType, roleName, role.Spec.InlinePolicies, role.Status.InlinePolicies, keepRemoved)
+	if err != nil {
+		role.Status.InlinePolicies = applied
+		role.Status.InlinePolicySize = size
+		log.Error(err, "unable to apply inline policies to Role")
+		return ctrl.Result{}, errWithStatus(ctx, role, err, sw)
+	}
+
+	// the spec is only applied completely, once the kept inline policies are gone
+	if !keepRemoved {
+		SuccessStatusUpdater()(ctx, ins, role, sw, log)
+		role.Status.ObservedGeneration = appliedGeneration(role, sw)
+	}
+	role.Status.InlinePolicies = applied
+	role.Status.InlinePolicySize = size
+	if err := sw.Update(ctx, role); err != nil {
+		return ctrl.Result{}, err
+	}
+	if keepRemoved {
+		return ctrl.Result{RequeueAfter: conversionRetryInterval}, nil
+	}
+
+	if err := storeLastAppliedRoleHashes(ctx, r.Client, role, hash, roleHash); err != nil {
+		log.Error(err, "unable to store last applied spec hash for Role")
+		return ctrl.Result{}, err
+	}
+	r.Notifier.Notify(role, v1.EventTypeNormal, "Reconciled", fmt.Sprintf("Updated inline policies of Role '%s'", role.Status.ARN))
+
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// storeLastAppliedRoleHashes saves the hash of the applied spec, and the one of the spec without its inline policies,
+// in the annotations of the Role
+func storeLastAppliedRoleHashes(ctx context.Context, c client.Client, role *iamv1beta1.Role, hash, roleHash string) error {
+	annotations := role.GetAnnotations()
+	if annotations[lastAppliedSpecHashAnnotation] == hash && annotations[lastAppliedRoleHashAnnotation] == roleHash {
+		return nil
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[lastAppliedSpecHashAnnotation] = hash
+	annotations[lastAppliedRoleHashAnnotation] = roleHash
+	role.SetAnnotations(annotations)
+	return c.Update(ctx, role)
+}
+
+// reapplyDriftedBoundary sets the boundary of the spec again, if it has been removed or changed outside of the
+// operator. In read-only and create-only mode, the drift is only reported.
+func (r *RoleReconciler) reapplyDriftedBoundary(ctx context.Context, role *iamv1beta1.Role, sw client.StatusWriter) error {
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
+	if err != nil {
+		return err
+	}
+	roleName := r.ResourcePrefix + role.RoleName()
+
+	current, err := currentPermissionsBoundary(iamsvc, iamv1beta1.RoleTargetType, roleName)
+	if err != nil || current == role.Spec.PermissionsBoundary {
+		return err
+	}
+
+	message := fmt.Sprintf("permissions boundary of Role '%s' has drifted from '%s' to '%s'", roleName, role.Spec.PermissionsBoundary, current)
+	if current == "" {
+		message = fmt.Sprintf("permissions boundary '%s' has been removed from Role '%s'", role.Spec.PermissionsBoundary, roleName)
+	}
+	if r.ReadOnly || r.CreateOnly {
+		r.Notifier.Notify(role, v1.EventTypeWarning, "BoundaryDrifted", message)
+		return nil
+	}
+	r.Notifier.Notify(role, v1.EventTypeWarning, "BoundaryDrifted", message+"; reapplying it")
+
+	if err := reconcilePermissionsBoundary(iamsvc, role, iamv1beta1.RoleTargetType, roleName, role.Spec.PermissionsBoundary); err != nil {
+		return err
+	}
+	return sw.Update(ctx, role)
 }
 
 // Returns a function, that does everything necessary before we can delete our actual Role (cleanup)
-func roleCleanup(r *RoleReconciler, ctx context.Context, role iamv1beta1.Role) func() error {
+func roleCleanup(r *RoleReconciler, ctx context.Context, role iamv1beta1.Role, svc iamiface.IAMAPI, roleName string) func() error {
 	return func() error {
+		if err := checkDeletionProtection(svc, iamv1beta1.RoleTargetType, roleName, r.ProtectionTag); err != nil {
+			r.Notifier.Notify(&role, v1.EventTypeWarning, "DeletionProtected", err.Error())
+			return err
+		}
 		attachments := iamv1beta1.PolicyAttachmentList{}
 		if err := r.List(ctx, &attachments); err != nil {
 			return err
@@ -221,13 +627,31 @@ func roleCleanup(r *RoleReconciler, ctx context.Context, role iamv1beta1.Role) f
 				}
 			}
 		}
-		return nil
+		sets, err := setsAttachingTo(ctx, r.Client, iamv1beta1.RoleTargetType, role.Namespace, role.Name)
+		if err != nil {
+			return err
+		}
+		if len(sets) != 0 {
+			return fmt.Errorf("cannot delete Role due to existing PolicyAttachmentSet '%s/%s'", sets[0].Name, sets[0].Namespace)
+		}
+		// AWS refuses to delete a Role with inline or attached policies
+		if err := deleteInlinePolicies(svc, iamv1beta1.RoleTargetType, roleName, role.Status.InlinePolicies); err != nil {
+			return err
+		}
+		return detachRolePolicies(svc, roleName, role.Status.SelectedPolicies)
 	}
 }
 
 func (r *RoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.UniqueRoleNames {
+		if err := indexRoleNames(mgr); err != nil {
+			return err
+		}
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&iamv1beta1.Role{}).
+		Watches(&source.Kind{Type: &iamv1beta1.Policy{}}, handler.EnqueueRequestsFromMapFunc(r.rolesForPolicy)).
+		Watches(&source.Kind{Type: &v1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.rolesForNamespace)).
 		Complete(r)
 }
 
@@ -285,11 +709,72 @@ func getPolicyDoc(role *iamv1beta1.Role, oidcProviderARN string, c client.Client
 		})
 	}
 
+	if len(role.Spec.TrustConditions) != 0 {
+		var err error
+		if statement, err = injectTrustConditions(statement, role.Spec.TrustConditions); err != nil {
+			return p, "", err
+		}
+	}
+
+	// humans assume roles as AWS principals; services and federated identities can't present MFA anyway
+	if role.Spec.RequireMFA {
+		var err error
+		if statement, err = injectMFACondition(statement); err != nil {
+			return p, "", err
+		}
+	}
+
 	p = statement.MarshalPolicyDocument()
+	if err := checkUniqueSids(p); err != nil {
+		return p, "", err
+	}
+	p = canonicalPolicyDocument(compactTrustPolicy(p))
 
 	return p, resourceVersion, nil
 }
 
+// injectTrustConditions returns a copy of the statement, with the given conditions merged into every entry that
+// trusts a service principal. A condition can't be injected, if the entry already compares the same key differently.
+func injectTrustConditions(statement iamv1beta1.AssumeRolePolicyStatement, conditions iamv1beta1.PolicyStatementCondition) (iamv1beta1.AssumeRolePolicyStatement, error) {
+	var out iamv1beta1.AssumeRolePolicyStatement
+	injected := false
+	for i, entry := range statement {
+		if _, ok := entry.Principal["Service"]; !ok {
+			out = append(out, entry)
+			continue
+		}
+
+		// copy the conditions, we don't want to change the spec they come from
+		merged := iamv1beta1.PolicyStatementCondition{}
+		for op, comparison := range entry.Conditions {
+			merged[op] = iamv1beta1.PolicyStatementConditionComparison{}
+			for key, value := range comparison {
+				merged[op][key] = value
+			}
+		}
+		for op, comparison := range conditions {
+			if merged[op] == nil {
+				merged[op] = iamv1beta1.PolicyStatementConditionComparison{}
+			}
+			for key, value := range comparison {
+				if existing, ok := merged[op][key]; ok && existing != value {
+					return nil, fmt.Errorf("trust condition '%s' on '%s' conflicts with the one of trust policy statement %d", op, key, i)
+				}
+				merged[op][key] = value
+			}
+		}
+
+		entry.Conditions = merged
+		out = append(out, entry)
+		injected = true
+	}
+
+	if !injected {
+		return nil, fmt.Errorf("trustConditions are given, but no trust policy statement trusts a service principal")
+	}
+	return out, nil
+}
+
 func createRoleServiceAccount(role iamv1beta1.Role, ctx context.Context, client client.Client, ownerRef metav1.OwnerReference) error {
 	if role.Spec.CreateServiceAccount {
 		sa := v1.ServiceAccount{
@@ -0,0 +1,1078 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// newTestRole returns a Role that may be assumed by EC2
+func newTestRole() *iamv1beta1.Role {
+	return &iamv1beta1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: uniqueName("role"), Namespace: "default"},
+		Spec: iamv1beta1.RoleSpec{
+			AssumeRolePolicy: iamv1beta1.AssumeRolePolicyStatement{{
+				PolicyStatementEntry: iamv1beta1.PolicyStatementEntry{
+					Effect:  "Allow",
+					Actions: []string{"sts:AssumeRole"},
+				},
+				Principal: map[string]string{"Service": "ec2.amazonaws.com"},
+			}},
+		},
+	}
+}
+
+// respondRoleCreated makes CreateRole return the ARN of the created role, like AWS does
+func respondRoleCreated(fake *fakeIAM) {
+	fake.respond("CreateRole", func(r *request.Request) {
+		name := awssdk.StringValue(r.Params.(*awsiam.CreateRoleInput).RoleName)
+		r.Data.(*awsiam.CreateRoleOutput).Role = &awsiam.Role{Arn: awssdk.String("arn:aws:iam::123456789012:role/" + name)}
+	})
+}
+
+var _ = Describe("Role controller", func() {
+	var (
+		ctx        context.Context
+		fake       *fakeIAM
+		reconciler *RoleReconciler
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = installFakeIAM()
+		reconciler = &RoleReconciler{
+			Client: k8sClient,
+			Log:    ctrl.Log.WithName("controllers").WithName("Role"),
+			Scheme: k8sClient.Scheme(),
+			Region: "eu-west-1",
+		}
+	})
+
+	AfterEach(func() {
+		uninstallFakeIAM()
+	})
+
+	Context("in read-only mode", func() {
+		It("reports what it would do without any mutating AWS call", func() {
+			reconciler.ReadOnly = true
+			role := newTestRole()
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			for _, call := range fake.Calls() {
+				Expect(isMutatingOperation(call)).To(BeFalse(), "unexpected call to %s", call)
+			}
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.SkippedSyncState))
+			Expect(role.Status.Message).To(ContainSubstring("read-only mode: would create Role"))
+		})
+	})
+
+	Context("when policies are attached and detached outside the Role", func() {
+		It("lists the currently attached policies in the status", func() {
+			role := newTestRole()
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation
+			})
+
+			attached := []string{"arn:aws:iam::aws:policy/ReadOnlyAccess", "arn:aws:iam::123456789012:policy/app"}
+			fake.respond("ListAttachedRolePolicies", func(r *request.Request) {
+				output := r.Data.(*awsiam.ListAttachedRolePoliciesOutput)
+				for _, policyArn := range attached {
+					output.AttachedPolicies = append(output.AttachedPolicies, &awsiam.AttachedPolicy{PolicyArn: awssdk.String(policyArn)})
+				}
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.AttachedPolicies).To(Equal([]string{"arn:aws:iam::123456789012:policy/app", "arn:aws:iam::aws:policy/ReadOnlyAccess"}))
+
+			attached = attached[:1]
+			_, err = reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.AttachedPolicies).To(Equal([]string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}))
+			Expect(fake.Calls()).To(Equal([]string{"ListAttachedRolePolicies", "ListAttachedRolePolicies"}))
+		})
+	})
+
+	Context("when Policies start and stop matching the policySelector", func() {
+		It("attaches and detaches them without recreating the Role", func() {
+			role := newTestRole()
+			role.Spec.PolicySelector = &metav1.LabelSelector{MatchLabels: map[string]string{"policy-group": uniqueName("readonly")}}
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation
+			})
+
+			policy := &iamv1beta1.Policy{ObjectMeta: metav1.ObjectMeta{
+				Name:      uniqueName("policy"),
+				Namespace: "default",
+				Labels:    role.Spec.PolicySelector.MatchLabels,
+			}}
+			createWithStatus(policy, func() {
+				policy.Status.ARN = "arn:aws:iam::123456789012:policy/" + policy.Name
+				policy.Status.State = iamv1beta1.OkSyncState
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("AttachRolePolicy"))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.SelectedPolicies).To(Equal([]string{policy.Status.ARN}))
+
+			Expect(k8sClient.Delete(ctx, policy)).To(Succeed())
+			_, err = reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("DetachRolePolicy"))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.SelectedPolicies).To(BeEmpty())
+
+			Expect(fake.Calls()).NotTo(ContainElement("DeleteRole"))
+			Expect(fake.Calls()).NotTo(ContainElement("CreateRole"))
+		})
+	})
+
+	Context("when a Role is renamed with the Recreate strategy", func() {
+		It("moves the attached policies over to the new Role before deleting the old one", func() {
+			role := newTestRole()
+			role.Spec.RenameStrategy = iamv1beta1.RecreateRenameStrategy
+			oldName := "old-" + role.Name
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + oldName
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation - 1
+			})
+
+			var events []string
+			respondRoleCreated(fake)
+			fake.respond("ListAttachedRolePolicies", func(r *request.Request) {
+				if awssdk.StringValue(r.Params.(*awsiam.ListAttachedRolePoliciesInput).RoleName) != oldName {
+					return
+				}
+				r.Data.(*awsiam.ListAttachedRolePoliciesOutput).AttachedPolicies = []*awsiam.AttachedPolicy{{
+					PolicyArn:  awssdk.String("arn:aws:iam::aws:policy/ReadOnlyAccess"),
+					PolicyName: awssdk.String("ReadOnlyAccess"),
+				}}
+			})
+			fake.respond("AttachRolePolicy", func(r *request.Request) {
+				input := r.Params.(*awsiam.AttachRolePolicyInput)
+				events = append(events, "attach "+awssdk.StringValue(input.PolicyArn)+" to "+awssdk.StringValue(input.RoleName))
+			})
+			fake.respond("DeleteRole", func(r *request.Request) {
+				events = append(events, "delete "+awssdk.StringValue(r.Params.(*awsiam.DeleteRoleInput).RoleName))
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(Equal([]string{
+				"attach arn:aws:iam::aws:policy/ReadOnlyAccess to " + role.Name,
+				"delete " + oldName,
+			}))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.ARN).To(Equal("arn:aws:iam::123456789012:role/" + role.Name))
+			Expect(role.Status.RenamedFromARN).To(BeEmpty())
+		})
+
+		It("leaves the Role as it is, if a check before the rename fails", func() {
+			role := newTestRole()
+			role.Spec.RenameStrategy = iamv1beta1.RecreateRenameStrategy
+			statement := iamv1beta1.PolicyStatement{{Effect: "Allow", Actions: []string{"s3:GetObject"}, Resources: []string{"*"}}}
+			role.Spec.InlinePolicies = []iamv1beta1.InlinePolicy{{Name: "twice", Statement: statement}, {Name: "twice", Statement: statement}}
+			oldArn := "arn:aws:iam::123456789012:role/old-" + role.Name
+			createWithStatus(role, func() {
+				role.Status.ARN = oldArn
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation - 1
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(MatchError(ContainSubstring("is not unique")))
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+			Expect(role.Status.ARN).To(Equal(oldArn))
+			Expect(role.Status.RenamedFromARN).To(BeEmpty())
+		})
+	})
+
+	Context("when nothing but the inline policies changed", func() {
+		// newInlinePolicyRole returns a Role, whose inline policy 'old' has been replaced by 'new' in the spec since it
+		// was last applied
+		newInlinePolicyRole := func() *iamv1beta1.Role {
+			role := newTestRole()
+			role.Spec.InlinePolicies = []iamv1beta1.InlinePolicy{{
+				Name: "new",
+				Statement: iamv1beta1.PolicyStatement{{
+					Effect:    "Allow",
+					Actions:   []string{"s3:GetObject"},
+					Resources: []string{"*"},
+				}},
+			}}
+			hashedSpec := role.Spec
+			hashedSpec.InlinePolicies = nil
+			roleHash, err := specHash(hashedSpec, "")
+			Expect(err).NotTo(HaveOccurred())
+			role.Annotations = map[string]string{lastAppliedRoleHashAnnotation: roleHash}
+
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation - 1
+				role.Status.InlinePolicies = []string{"old"}
+			})
+			return role
+		}
+
+		It("puts the new inline policies before deleting the old ones, without recreating the Role", func() {
+			role := newInlinePolicyRole()
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(Equal([]string{"PutRolePolicy", "DeleteRolePolicy"}))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(role.Status.InlinePolicies).To(Equal([]string{"new"}))
+			Expect(role.Status.ObservedGeneration).To(Equal(role.Generation))
+			Expect(role.Annotations).To(HaveKey(lastAppliedSpecHashAnnotation))
+		})
+
+		It("keeps the old inline policies while a PolicyAttachment to the Role is pending", func() {
+			role := newInlinePolicyRole()
+			attachment := &iamv1beta1.PolicyAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: uniqueName("attachment"), Namespace: "default"},
+				Spec: iamv1beta1.PolicyAttachmentSpec{
+					ExternalPolicy:  iamv1beta1.ExternalResource{ARN: "arn:aws:iam::aws:policy/ReadOnlyAccess"},
+					TargetReference: iamv1beta1.TargetReference{Type: iamv1beta1.RoleTargetType, Name: role.Name, Namespace: role.Namespace},
+				},
+			}
+			Expect(k8sClient.Create(ctx, attachment)).To(Succeed())
+
+			result, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(conversionRetryInterval))
+			Expect(fake.Calls()).To(Equal([]string{"PutRolePolicy"}))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.InlinePolicies).To(Equal([]string{"new", "old"}))
+		})
+	})
+
+	Context("when only the session policies change", func() {
+		// newSessionPolicyRole returns a Role applied without session policies, which have been added to the spec since
+		newSessionPolicyRole := func(sessionPolicies ...string) *iamv1beta1.Role {
+			role := newTestRole()
+			hash, err := specHash(role.Spec, "")
+			Expect(err).NotTo(HaveOccurred())
+			role.Annotations = map[string]string{lastAppliedSpecHashAnnotation: hash}
+			role.Spec.SessionPolicies = sessionPolicies
+
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation - 1
+			})
+			return role
+		}
+
+		It("lists them in the status, without calling AWS", func() {
+			role := newSessionPolicyRole("arn:aws:iam::123456789012:policy/session-read", "arn:aws:iam::aws:policy/ReadOnlyAccess")
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.OkSyncState))
+			Expect(role.Status.SessionPolicies).To(Equal(role.Spec.SessionPolicies))
+			Expect(role.Status.ObservedGeneration).To(Equal(role.Generation))
+		})
+
+		It("refuses ARNs which are not the ones of managed policies", func() {
+			role := newSessionPolicyRole("arn:aws:iam::123456789012:role/not-a-policy")
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(HaveOccurred())
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+			Expect(role.Status.Message).To(ContainSubstring("is not the ARN of a managed policy"))
+			Expect(role.Status.SessionPolicies).To(BeEmpty())
+		})
+
+		It("refuses the same ARN given twice", func() {
+			role := newSessionPolicyRole("arn:aws:iam::aws:policy/ReadOnlyAccess", "arn:aws:iam::aws:policy/ReadOnlyAccess")
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(HaveOccurred())
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.Message).To(ContainSubstring("is given more than once"))
+		})
+	})
+
+	Context("when an ephemeral Role isn't refreshed within its TTL", func() {
+		It("deletes the AWS role, and recreates it once the spec changes", func() {
+			role := newTestRole()
+			role.Spec.TTLAfterLastSync = &metav1.Duration{Duration: 2 * time.Second}
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation
+			})
+			respondRoleCreated(fake)
+			time.Sleep(role.Spec.TTLAfterLastSync.Duration)
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("DeleteRole"))
+			Expect(fake.Calls()).NotTo(ContainElement("CreateRole"))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.ARN).To(BeEmpty())
+			Expect(meta.IsStatusConditionTrue(role.Status.Conditions, iamv1beta1.ExpiredCondition)).To(BeTrue())
+
+			// reconciling again doesn't bring it back
+			_, err = reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).NotTo(ContainElement("CreateRole"))
+
+			role.Spec.Description = "refreshed"
+			role.Generation++
+			Expect(k8sClient.Update(ctx, role)).To(Succeed())
+
+			_, err = reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("CreateRole"))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.ARN).To(Equal("arn:aws:iam::123456789012:role/" + role.Name))
+			Expect(role.Status.SpecChangedAt).NotTo(BeEmpty())
+			Expect(meta.FindStatusCondition(role.Status.Conditions, iamv1beta1.ExpiredCondition)).To(BeNil())
+		})
+	})
+
+	Context("when the trust policy is generated from many statements", func() {
+		It("merges the statements for the same principal to stay within the size limit", func() {
+			role := newTestRole()
+			for i := 0; i < 60; i++ {
+				entry := role.Spec.AssumeRolePolicy[0]
+				entry.Actions = []string{fmt.Sprintf("sts:Action%d", i)}
+				role.Spec.AssumeRolePolicy = append(role.Spec.AssumeRolePolicy, entry)
+			}
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+			var submitted string
+			fake.respond("CreateRole", func(r *request.Request) {
+				input := r.Params.(*awsiam.CreateRoleInput)
+				submitted = awssdk.StringValue(input.AssumeRolePolicyDocument)
+				r.Data.(*awsiam.CreateRoleOutput).Role = &awsiam.Role{Arn: awssdk.String("arn:aws:iam::123456789012:role/" + awssdk.StringValue(input.RoleName))}
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(submitted)).To(BeNumerically("<=", DefaultTrustPolicySizeLimit))
+			Expect(strings.Count(submitted, `"Effect"`)).To(Equal(1))
+			Expect(submitted).To(ContainSubstring(`"sts:Action59"`))
+		})
+
+		It("refuses a trust policy still over the limit, without calling AWS", func() {
+			role := newTestRole()
+			role.Spec.AssumeRolePolicy = nil
+			for i := 0; i < 60; i++ {
+				role.Spec.AssumeRolePolicy = append(role.Spec.AssumeRolePolicy, iamv1beta1.AssumeRolePolicyStatementEntry{
+					PolicyStatementEntry: iamv1beta1.PolicyStatementEntry{Effect: "Allow", Actions: []string{"sts:AssumeRole"}},
+					Principal:            map[string]string{"AWS": fmt.Sprintf("arn:aws:iam::%012d:root", i)},
+				})
+			}
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(MatchError(ContainSubstring("exceeding the limit of 2048 characters")))
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+			Expect(role.Status.Message).To(ContainSubstring("after compaction"))
+		})
+	})
+
+	Context("in a namespace capping the session duration", func() {
+		var namespace *v1.Namespace
+
+		BeforeEach(func() {
+			namespace = &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        uniqueName("short-sessions"),
+				Annotations: map[string]string{maxSessionDurationAnnotation: "2h"},
+			}}
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+		})
+
+		It("rejects a longer duration, also for a Role applied before the cap", func() {
+			role := newTestRole()
+			role.Namespace = namespace.Name
+			duration := int64(12 * 3600)
+			role.Spec.MaxSessionDuration = &duration
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation
+			})
+			Expect(reconciler.rolesForNamespace(namespace)).To(ConsistOf(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(role)}))
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(MatchError(ContainSubstring("exceeds the limit of 2h0m0s")))
+			Expect(fake.Calls()).To(BeEmpty())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+		})
+
+		It("allows the default duration of an hour", func() {
+			role := newTestRole()
+			role.Namespace = namespace.Name
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+			respondRoleCreated(fake)
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("CreateRole"))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.OkSyncState))
+		})
+	})
+
+	Context("with a permissions boundary", func() {
+		const boundary = "arn:aws:iam::123456789012:policy/boundary"
+
+		It("reflects the applied boundary in the BoundaryApplied condition", func() {
+			role := newTestRole()
+			role.Spec.PermissionsBoundary = boundary
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+			respondRoleCreated(fake)
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("PutRolePermissionsBoundary"))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			condition := meta.FindStatusCondition(role.Status.Conditions, iamv1beta1.BoundaryAppliedCondition)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal("Applied"))
+		})
+
+		It("reflects a boundary AWS refused in the BoundaryApplied condition", func() {
+			role := newTestRole()
+			role.Spec.PermissionsBoundary = boundary
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+			respondRoleCreated(fake)
+			fake.fail("PutRolePermissionsBoundary", "AccessDenied")
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+			condition := meta.FindStatusCondition(role.Status.Conditions, iamv1beta1.BoundaryAppliedCondition)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("ApplyFailed"))
+		})
+
+		It("reapplies a boundary removed outside the operator, with a warning", func() {
+			recorder := record.NewFakeRecorder(10)
+			reconciler.Notifier = NewNotifier(recorder, "")
+			role := newTestRole()
+			role.Spec.PermissionsBoundary = boundary
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation
+			})
+			fake.respond("GetRole", func(r *request.Request) {
+				r.Data.(*awsiam.GetRoleOutput).Role = &awsiam.Role{RoleName: awssdk.String(role.Name)}
+			})
+			var applied string
+			fake.respond("PutRolePermissionsBoundary", func(r *request.Request) {
+				applied = awssdk.StringValue(r.Params.(*awsiam.PutRolePermissionsBoundaryInput).PermissionsBoundary)
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(applied).To(Equal(boundary))
+			Expect(fake.Calls()).NotTo(ContainElement("CreateRole"))
+			Expect(recorder.Events).To(Receive(Equal(fmt.Sprintf("Warning BoundaryDrifted permissions boundary '%s' has been removed from Role '%s'; reapplying it", boundary, role.Name))))
+		})
+
+		Context("when it's removed from the spec with the removal guard enabled", func() {
+			// newBoundedRole returns a Role whose boundary has been applied, and removed from the spec since
+			newBoundedRole := func(annotations map[string]string) *iamv1beta1.Role {
+				role := newTestRole()
+				role.Annotations = annotations
+				createWithStatus(role, func() {
+					role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+					role.Status.State = iamv1beta1.OkSyncState
+					role.Status.ObservedGeneration = role.Generation - 1
+					setBoundaryCondition(&role.Status.AWSObjectStatus, role.Generation-1, metav1.ConditionTrue, "Applied", "applied")
+				})
+				return role
+			}
+
+			BeforeEach(func() {
+				reconciler.GuardBoundaryRemoval = true
+				respondRoleCreated(fake)
+			})
+
+			It("refuses to remove it without confirmation", func() {
+				role := newBoundedRole(nil)
+
+				_, err := reconcileObject(reconciler, role)
+				Expect(err).To(MatchError(ContainSubstring("refusing to remove the permissions boundary")))
+				Expect(fake.Calls()).To(BeEmpty())
+
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+				Expect(role.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+				Expect(meta.IsStatusConditionTrue(role.Status.Conditions, iamv1beta1.BoundaryAppliedCondition)).To(BeTrue())
+			})
+
+			It("removes it once confirmed via annotation", func() {
+				role := newBoundedRole(map[string]string{allowBoundaryRemovalAnnotation: "true"})
+
+				_, err := reconcileObject(reconciler, role)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fake.Calls()).To(ContainElement("DeleteRolePermissionsBoundary"))
+
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+				Expect(meta.FindStatusCondition(role.Status.Conditions, iamv1beta1.BoundaryAppliedCondition)).To(BeNil())
+			})
+		})
+	})
+
+	Context("with several inline policies", func() {
+		statement := func(actions ...string) iamv1beta1.PolicyStatement {
+			return iamv1beta1.PolicyStatement{{Effect: "Allow", Actions: actions, Resources: []string{"*"}}}
+		}
+
+		It("applies them in order of their names, and reports their aggregated size", func() {
+			role := newTestRole()
+			role.Spec.InlinePolicies = []iamv1beta1.InlinePolicy{
+				{Name: "zeta", Statement: statement("s3:GetObject")},
+				{Name: "alpha", Statement: statement("sqs:SendMessage")},
+			}
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+			respondRoleCreated(fake)
+			var put []string
+			size := 0
+			fake.respond("PutRolePolicy", func(r *request.Request) {
+				input := r.Params.(*awsiam.PutRolePolicyInput)
+				put = append(put, awssdk.StringValue(input.PolicyName))
+				size += len(awssdk.StringValue(input.PolicyDocument))
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(put).To(Equal([]string{"alpha", "zeta"}))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.InlinePolicies).To(Equal([]string{"alpha", "zeta"}))
+			Expect(role.Status.InlinePolicySize).To(Equal(size))
+		})
+
+		It("refuses them before touching the Role, if they exceed the aggregated size limit", func() {
+			role := newTestRole()
+			var actions []string
+			for i := 0; i < 400; i++ {
+				actions = append(actions, fmt.Sprintf("s3:SomeRatherLongAction%d", i))
+			}
+			role.Spec.InlinePolicies = []iamv1beta1.InlinePolicy{
+				{Name: "first", Statement: statement(actions[:200]...)},
+				{Name: "second", Statement: statement(actions[200:]...)},
+			}
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation - 1
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(MatchError(ContainSubstring("exceeds the limit of 10240 characters for a Role")))
+			Expect(fake.Calls()).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+			Expect(role.Status.ARN).NotTo(BeEmpty())
+		})
+	})
+
+	Context("with unique role names enforced", func() {
+		It("refuses a Role whose AWS name is used by another Role under a different path", func() {
+			existing := newTestRole()
+			existing.Namespace = "team-a"
+			existing.UID = "existing-uid"
+			existing.Spec.AWSRoleName = "shared-deployer"
+			existing.Status.ARN = "arn:aws:iam::123456789012:role/team-a/shared-deployer"
+			role := newTestRole()
+			role.UID = "role-uid"
+			role.Spec.AWSRoleName = "shared-deployer"
+
+			// the fake client ignores field selectors and doesn't set UIDs, so it may only hold the Roles in question
+			reconciler.Client = fakeclient.NewClientBuilder().
+				WithScheme(k8sClient.Scheme()).
+				WithObjects(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, existing, role).
+				Build()
+			reconciler.UniqueRoleNames = true
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(MatchError("AWS role name 'shared-deployer' is already used by Role 'team-a/" + existing.Name + "'"))
+			Expect(fake.Calls()).NotTo(ContainElement("CreateRole"))
+
+			Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+		})
+	})
+
+	Context("with a resync period annotation", func() {
+		It("requeues the Role after its own period instead of the global one", func() {
+			reconciler.Interval = time.Hour
+			role := newTestRole()
+			role.Annotations = map[string]string{resyncPeriodAnnotation: "5m"}
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation
+			})
+
+			result, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(5 * time.Minute))
+
+			role.Annotations[resyncPeriodAnnotation] = "soon"
+			Expect(k8sClient.Update(ctx, role)).To(Succeed())
+			result, err = reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(time.Hour))
+		})
+	})
+
+	Context("when the Role is owned by another resource", func() {
+		var tags []*awsiam.Tag
+
+		// newOwnedRole returns a Role owned by a ConfigMap, which is in turn owned by the billing-app Deployment
+		newOwnedRole := func() *iamv1beta1.Role {
+			controller := true
+			configMap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:      uniqueName("config"),
+				Namespace: "default",
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "Deployment", Name: "billing-app", UID: "billing-app-uid", Controller: &controller},
+				},
+			}}
+			Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+			role := newTestRole()
+			role.OwnerReferences = []metav1.OwnerReference{
+				{APIVersion: "v1", Kind: "ConfigMap", Name: configMap.Name, UID: "config-uid", Controller: &controller},
+			}
+			return role
+		}
+
+		BeforeEach(func() {
+			respondRoleCreated(fake)
+			tags = nil
+			fake.respond("TagRole", func(r *request.Request) {
+				tags = append(tags, r.Params.(*awsiam.TagRoleInput).Tags...)
+			})
+		})
+
+		It("tags it with the name of the application at the top of the owner chain", func() {
+			role := newOwnedRole()
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tags).To(Equal([]*awsiam.Tag{{Key: awssdk.String(owningAppTagKey), Value: awssdk.String("billing-app")}}))
+		})
+
+		It("renders the tag value in the configured format, and retags once an annotation it uses changes", func() {
+			reconciler.OwnerTagFormat = "${annotation:cluster}/${namespace}/${app}/${name}"
+			role := newOwnedRole()
+			role.Annotations = map[string]string{"cluster": "eu-prod"}
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tags).To(Equal([]*awsiam.Tag{{Key: awssdk.String(owningAppTagKey), Value: awssdk.String("eu-prod/default/billing-app/" + role.Name)}}))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			role.Annotations["cluster"] = "eu-dr"
+			Expect(k8sClient.Update(ctx, role)).To(Succeed())
+			tags = nil
+			_, err = reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tags).To(Equal([]*awsiam.Tag{{Key: awssdk.String(owningAppTagKey), Value: awssdk.String("eu-dr/default/billing-app/" + role.Name)}}))
+		})
+
+		It("refuses a format referring to a missing annotation", func() {
+			reconciler.OwnerTagFormat = "${annotation:cluster}/${app}"
+			role := newOwnedRole()
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(MatchError(ContainSubstring("refers to missing annotations: cluster")))
+			Expect(tags).To(BeEmpty())
+		})
+	})
+
+	Context("with trust conditions", func() {
+		It("injects them into the statements trusting a service, next to their own conditions", func() {
+			role := newTestRole()
+			role.Spec.AssumeRolePolicy[0].Conditions = iamv1beta1.PolicyStatementCondition{
+				"ArnLike": {"aws:SourceArn": "arn:aws:ec2:eu-west-1:123456789012:instance/*"},
+			}
+			role.Spec.AssumeRolePolicy = append(role.Spec.AssumeRolePolicy, iamv1beta1.AssumeRolePolicyStatementEntry{
+				PolicyStatementEntry: iamv1beta1.PolicyStatementEntry{Effect: "Allow", Actions: []string{"sts:AssumeRole"}},
+				Principal:            map[string]string{"AWS": "arn:aws:iam::123456789012:root"},
+			})
+			role.Spec.TrustConditions = iamv1beta1.PolicyStatementCondition{
+				"StringEquals": {"aws:SourceAccount": "123456789012"},
+			}
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+			var submitted string
+			fake.respond("CreateRole", func(r *request.Request) {
+				input := r.Params.(*awsiam.CreateRoleInput)
+				submitted = awssdk.StringValue(input.AssumeRolePolicyDocument)
+				r.Data.(*awsiam.CreateRoleOutput).Role = &awsiam.Role{Arn: awssdk.String("arn:aws:iam::123456789012:role/" + awssdk.StringValue(input.RoleName))}
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			var doc struct {
+				Statement []struct {
+					Principal map[string]interface{}
+					Condition map[string]map[string]interface{}
+				}
+			}
+			Expect(json.Unmarshal([]byte(submitted), &doc)).To(Succeed())
+			Expect(doc.Statement).To(HaveLen(2))
+			for _, statement := range doc.Statement {
+				if _, ok := statement.Principal["Service"]; ok {
+					Expect(statement.Condition).To(Equal(map[string]map[string]interface{}{
+						"ArnLike":      {"aws:SourceArn": "arn:aws:ec2:eu-west-1:123456789012:instance/*"},
+						"StringEquals": {"aws:SourceAccount": "123456789012"},
+					}))
+				} else {
+					Expect(statement.Condition).To(BeEmpty())
+				}
+			}
+		})
+
+		It("refuses a trust condition conflicting with the one of a statement", func() {
+			role := newTestRole()
+			role.Spec.AssumeRolePolicy[0].Conditions = iamv1beta1.PolicyStatementCondition{
+				"StringEquals": {"aws:SourceAccount": "210987654321"},
+			}
+			role.Spec.TrustConditions = iamv1beta1.PolicyStatementCondition{
+				"StringEquals": {"aws:SourceAccount": "123456789012"},
+			}
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(MatchError(ContainSubstring("trust condition 'StringEquals' on 'aws:SourceAccount' conflicts")))
+			Expect(fake.Calls()).NotTo(ContainElement("CreateRole"))
+		})
+	})
+
+	Context("when MFA is required", func() {
+		It("requires it from the AWS principals only, next to the trust conditions for services", func() {
+			role := newTestRole()
+			role.Spec.AssumeRolePolicy = append(role.Spec.AssumeRolePolicy, iamv1beta1.AssumeRolePolicyStatementEntry{
+				PolicyStatementEntry: iamv1beta1.PolicyStatementEntry{Effect: "Allow", Actions: []string{"sts:AssumeRole"}},
+				Principal:            map[string]string{"AWS": "arn:aws:iam::123456789012:root"},
+			})
+			role.Spec.RequireMFA = true
+			role.Spec.TrustConditions = iamv1beta1.PolicyStatementCondition{
+				"StringEquals": {"aws:SourceAccount": "123456789012"},
+			}
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+			var submitted string
+			fake.respond("CreateRole", func(r *request.Request) {
+				input := r.Params.(*awsiam.CreateRoleInput)
+				submitted = awssdk.StringValue(input.AssumeRolePolicyDocument)
+				r.Data.(*awsiam.CreateRoleOutput).Role = &awsiam.Role{Arn: awssdk.String("arn:aws:iam::123456789012:role/" + awssdk.StringValue(input.RoleName))}
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			var doc struct {
+				Statement []struct {
+					Principal map[string]interface{}
+					Condition map[string]map[string]interface{}
+				}
+			}
+			Expect(json.Unmarshal([]byte(submitted), &doc)).To(Succeed())
+			Expect(doc.Statement).To(HaveLen(2))
+			for _, statement := range doc.Statement {
+				if _, ok := statement.Principal["AWS"]; ok {
+					Expect(statement.Condition).To(Equal(map[string]map[string]interface{}{"Bool": {"aws:MultiFactorAuthPresent": "true"}}))
+				} else {
+					Expect(statement.Condition).To(Equal(map[string]map[string]interface{}{"StringEquals": {"aws:SourceAccount": "123456789012"}}))
+				}
+			}
+		})
+
+		It("refuses a statement explicitly allowing sessions without MFA", func() {
+			role := newTestRole()
+			role.Spec.AssumeRolePolicy[0].Principal = map[string]string{"AWS": "arn:aws:iam::123456789012:root"}
+			role.Spec.AssumeRolePolicy[0].Conditions = iamv1beta1.PolicyStatementCondition{
+				"Bool": {"aws:MultiFactorAuthPresent": "false"},
+			}
+			role.Spec.RequireMFA = true
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(MatchError(ContainSubstring("requireMFA conflicts with the 'Bool' condition on 'aws:MultiFactorAuthPresent'")))
+			Expect(fake.Calls()).NotTo(ContainElement("CreateRole"))
+		})
+	})
+
+	Context("in create-only mode", func() {
+		BeforeEach(func() {
+			reconciler.CreateOnly = true
+		})
+
+		It("leaves a changed Role as it is, and notes that in the status", func() {
+			role := newTestRole()
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation - 1
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			for _, call := range fake.Calls() {
+				Expect(isMutatingOperation(call)).To(BeFalse(), "unexpected call to %s", call)
+			}
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.SkippedSyncState))
+			Expect(role.Status.Message).To(Equal("create-only mode: not recreating existing Role '" + role.Name + "'"))
+		})
+
+		It("lets a deleted Role go, while leaving it in AWS", func() {
+			role := newTestRole()
+			role.Finalizers = []string{"role.aws-iam.redradrat.xyz"}
+			// the API server bumps the generation on deletion, the fake client doesn't
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation - 1
+			})
+			Expect(k8sClient.Delete(ctx, role)).To(Succeed())
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			for _, call := range fake.Calls() {
+				Expect(isMutatingOperation(call)).To(BeFalse(), "unexpected call to %s", call)
+			}
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role))).To(BeTrue())
+		})
+	})
+
+	Context("with a cap on the managed entities", func() {
+		It("refuses to create a Role once the cap is reached, and creates it below the cap", func() {
+			recorder := record.NewFakeRecorder(10)
+			reconciler.Notifier = NewNotifier(recorder, "")
+			existing := newTestRole()
+			existing.Status.ARN = "arn:aws:iam::123456789012:role/" + existing.Name
+			policy := &iamv1beta1.Policy{ObjectMeta: metav1.ObjectMeta{Name: uniqueName("policy"), Namespace: "default"}}
+			policy.Status.ARN = "arn:aws:iam::123456789012:policy/" + policy.Name
+			role := newTestRole()
+
+			// the shared API server holds the entities of all other specs, so this one counts on its own
+			reconciler.Client = fakeclient.NewClientBuilder().
+				WithScheme(k8sClient.Scheme()).
+				WithObjects(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, existing, policy, role).
+				Build()
+			reconciler.MaxManagedEntities = 2
+			respondRoleCreated(fake)
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(MatchError("refusing to create another AWS entity; the operator already manages 2 of at most 2"))
+			Expect(fake.Calls()).NotTo(ContainElement("CreateRole"))
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning ManagedEntityCapReached")))
+			Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.State).To(Equal(iamv1beta1.ErrorSyncState))
+
+			reconciler.MaxManagedEntities = 3
+			_, err = reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("CreateRole"))
+		})
+	})
+
+	Context("with a window for unused Roles", func() {
+		var (
+			recorder *record.FakeRecorder
+			lastUsed time.Time
+		)
+
+		BeforeEach(func() {
+			recorder = record.NewFakeRecorder(10)
+			reconciler.Notifier = NewNotifier(recorder, "")
+			reconciler.UnusedWindow = 30 * 24 * time.Hour
+			fake.respond("GetRole", func(r *request.Request) {
+				r.Data.(*awsiam.GetRoleOutput).Role = &awsiam.Role{
+					CreateDate:   awssdk.Time(time.Now().Add(-365 * 24 * time.Hour)),
+					RoleLastUsed: &awsiam.RoleLastUsed{LastUsedDate: awssdk.Time(lastUsed)},
+				}
+			})
+		})
+
+		newAppliedRole := func() *iamv1beta1.Role {
+			role := newTestRole()
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation
+			})
+			return role
+		}
+
+		It("flags a Role not used within the window once, without changing it in AWS", func() {
+			lastUsed = time.Now().Add(-90 * 24 * time.Hour)
+			role := newAppliedRole()
+
+			for i := 0; i < 2; i++ {
+				_, err := reconcileObject(reconciler, role)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			for _, call := range fake.Calls() {
+				Expect(isMutatingOperation(call)).To(BeFalse(), "unexpected call to %s", call)
+			}
+			Expect(recorder.Events).To(HaveLen(1))
+			Expect(<-recorder.Events).To(HavePrefix("Warning RoleUnused Role has not been used since"))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(role.Status.Conditions, iamv1beta1.UnusedCondition)).To(BeTrue())
+			Expect(role.Status.LastUsed).To(Equal(lastUsed.Format(time.RFC822Z)))
+		})
+
+		It("doesn't flag a Role used within the window", func() {
+			lastUsed = time.Now().Add(-24 * time.Hour)
+			role := newAppliedRole()
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			condition := meta.FindStatusCondition(role.Status.Conditions, iamv1beta1.UnusedCondition)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		})
+	})
+
+	Context("with a deletion protection tag", func() {
+		It("keeps a deleted Role, until the tag is removed in AWS", func() {
+			recorder := record.NewFakeRecorder(10)
+			reconciler.Notifier = NewNotifier(recorder, "")
+			reconciler.ProtectionTag = "protected"
+			role := newTestRole()
+			role.Finalizers = []string{"role.aws-iam.redradrat.xyz"}
+			// the API server bumps the generation on deletion, the fake client doesn't
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation - 1
+			})
+			Expect(k8sClient.Delete(ctx, role)).To(Succeed())
+			tags := []*awsiam.Tag{{Key: awssdk.String("protected"), Value: awssdk.String("true")}}
+			fake.respond("ListRoleTags", func(r *request.Request) {
+				r.Data.(*awsiam.ListRoleTagsOutput).Tags = tags
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).To(MatchError(ContainSubstring("is protected from deletion by its tag 'protected'")))
+			Expect(fake.Calls()).NotTo(ContainElement("DeleteRole"))
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning DeletionProtected")))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Finalizers).To(ContainElement("role.aws-iam.redradrat.xyz"))
+
+			tags = nil
+			_, err = reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fake.Calls()).To(ContainElement("DeleteRole"))
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role))).To(BeTrue())
+		})
+	})
+
+	Context("with pinned policy versions", func() {
+		It("pins the versions of the attached policies, and flags a policy changed since", func() {
+			recorder := record.NewFakeRecorder(10)
+			reconciler.Notifier = NewNotifier(recorder, "")
+			const policyArn = "arn:aws:iam::123456789012:policy/app"
+			role := newTestRole()
+			role.Spec.PinPolicyVersions = true
+			createWithStatus(role, func() {
+				role.Status.ARN = "arn:aws:iam::123456789012:role/" + role.Name
+				role.Status.State = iamv1beta1.OkSyncState
+				role.Status.ObservedGeneration = role.Generation
+			})
+			fake.respond("ListAttachedRolePolicies", func(r *request.Request) {
+				r.Data.(*awsiam.ListAttachedRolePoliciesOutput).AttachedPolicies = []*awsiam.AttachedPolicy{{PolicyArn: awssdk.String(policyArn)}}
+			})
+			defaultVersion := "v1"
+			fake.respond("GetPolicy", func(r *request.Request) {
+				r.Data.(*awsiam.GetPolicyOutput).Policy = &awsiam.Policy{Arn: awssdk.String(policyArn), DefaultVersionId: awssdk.String(defaultVersion)}
+			})
+
+			_, err := reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.PolicyVersions).To(Equal([]iamv1beta1.PinnedPolicyVersion{{ARN: policyArn, PinnedVersion: "v1", CurrentVersion: "v1"}}))
+			Expect(meta.IsStatusConditionFalse(role.Status.Conditions, iamv1beta1.PolicyVersionDriftCondition)).To(BeTrue())
+
+			defaultVersion = "v2"
+			_, err = reconcileObject(reconciler, role)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(role), role)).To(Succeed())
+			Expect(role.Status.PolicyVersions).To(Equal([]iamv1beta1.PinnedPolicyVersion{{ARN: policyArn, PinnedVersion: "v1", CurrentVersion: "v2"}}))
+			Expect(meta.IsStatusConditionTrue(role.Status.Conditions, iamv1beta1.PolicyVersionDriftCondition)).To(BeTrue())
+			Expect(recorder.Events).To(Receive(Equal("Warning PolicyVersionDrifted Attached policies have changed since they have been pinned: " + policyArn + " (v1 -> v2)")))
+			for _, call := range fake.Calls() {
+				Expect(isMutatingOperation(call)).To(BeFalse(), "unexpected call to %s", call)
+			}
+		})
+	})
+})
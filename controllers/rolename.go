@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// field index over the AWS names of all Roles
+const roleNameIndexKey = ".awsRoleName"
+
+func indexRoleNames(mgr ctrl.Manager) error {
+	return mgr.GetFieldIndexer().IndexField(context.Background(), &iamv1beta1.Role{}, roleNameIndexKey, func(o client.Object) []string {
+		return []string{o.(*iamv1beta1.Role).RoleName()}
+	})
+}
+
+// checkRoleNameUnique refuses a Role, whose AWS name is already used by another Role in the cluster, regardless of
+// namespace or path. A Role that already exists in AWS keeps the name over one that doesn't; otherwise the oldest one does.
+func checkRoleNameUnique(ctx context.Context, c client.Client, role *iamv1beta1.Role) error {
+	roles := iamv1beta1.RoleList{}
+	if err := c.List(ctx, &roles, client.MatchingFields{roleNameIndexKey: role.RoleName()}); err != nil {
+		return err
+	}
+	for _, other := range roles.Items {
+		if other.UID == role.UID {
+			continue
+		}
+		if takesRoleNamePrecedence(&other, role) {
+			return fmt.Errorf("AWS role name '%s' is already used by Role '%s/%s'", role.RoleName(), other.Namespace, other.Name)
+		}
+	}
+	return nil
+}
+
+func takesRoleNamePrecedence(a, b *iamv1beta1.Role) bool {
+	if (a.Status.ARN != "") != (b.Status.ARN != "") {
+		return a.Status.ARN != ""
+	}
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.UID < b.UID
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// namespace annotation requiring all Allow statements of the Policies in the namespace to be scoped to specific
+// resources. Its value holds a comma-separated list of actions, that may still be allowed on "*".
+const scopedResourcesAnnotation = "aws-iam.redradrat.xyz/require-scoped-resources"
+
+// checkScopedResources rejects Allow statements on all resources ("*") in namespaces that require scoped resources,
+// unless every action of the statement is allow-listed in the namespace annotation
+func checkScopedResources(ctx context.Context, c client.Client, policy *iamv1beta1.Policy) error {
+	var ns v1.Namespace
+	if err := c.Get(ctx, client.ObjectKey{Name: policy.Namespace}, &ns); err != nil {
+		return err
+	}
+	value, ok := ns.Annotations[scopedResourcesAnnotation]
+	if !ok {
+		return nil
+	}
+
+	var allowed []string
+	for _, action := range strings.Split(value, ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			allowed = append(allowed, strings.ToLower(action))
+		}
+	}
+
+	for i, entry := range policy.Spec.Statement {
+		if entry.Effect != iamv1beta1.AllowPolicyStatementEffect || !containsString(entry.Resources, "*") {
+			continue
+		}
+		for _, action := range entry.Actions {
+			if !containsString(allowed, strings.ToLower(action)) {
+				return fmt.Errorf("statement %d allows '%s' on all resources, which namespace '%s' forbids; scope the resources or allow-list the action in the annotation '%s'", i, action, policy.Namespace, scopedResourcesAnnotation)
+			}
+		}
+	}
+	return nil
+}
+
+// policiesForNamespace maps a Namespace to the Policies in it, so that they are checked against changed requirements
+// right away
+func (r *PolicyReconciler) policiesForNamespace(o client.Object) []reconcile.Request {
+	policies := iamv1beta1.PolicyList{}
+	if err := r.List(context.Background(), &policies, client.InNamespace(o.GetName())); err != nil {
+		r.Log.Error(err, "unable to list Policies for Namespace", "namespace", o.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, policy := range policies.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace}})
+	}
+	return requests
+}
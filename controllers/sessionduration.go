@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// namespace annotation capping the maximum session duration (e.g. "1h") of the Roles in the namespace
+const maxSessionDurationAnnotation = "aws-iam.redradrat.xyz/max-session-duration"
+
+// checkMaxSessionDuration rejects Roles whose maximum session duration exceeds the cap of their namespace. Roles
+// without an explicit duration get the AWS default of one hour, which has to be within the cap as well.
+func checkMaxSessionDuration(ctx context.Context, c client.Client, role *iamv1beta1.Role) error {
+	var ns v1.Namespace
+	if err := c.Get(ctx, client.ObjectKey{Name: role.Namespace}, &ns); err != nil {
+		return err
+	}
+	value, ok := ns.Annotations[maxSessionDurationAnnotation]
+	if !ok {
+		return nil
+	}
+	limit, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("annotation '%s' of namespace '%s' is not a valid duration: %v", maxSessionDurationAnnotation, role.Namespace, err)
+	}
+
+	var duration int64 = 3600
+	if role.Spec.MaxSessionDuration != nil {
+		duration = *role.Spec.MaxSessionDuration
+	}
+	if requested := time.Duration(duration) * time.Second; requested > limit {
+		return fmt.Errorf("maximum session duration of %s exceeds the limit of %s for namespace '%s'; set maxSessionDuration to at most %d seconds", requested, limit, role.Namespace, int64(limit/time.Second))
+	}
+	return nil
+}
+
+// rolesForNamespace maps a Namespace to the Roles in it, so that they are checked against a changed cap right away
+func (r *RoleReconciler) rolesForNamespace(o client.Object) []reconcile.Request {
+	roles := iamv1beta1.RoleList{}
+	if err := r.List(context.Background(), &roles, client.InNamespace(o.GetName())); err != nil {
+		r.Log.Error(err, "unable to list Roles for Namespace", "namespace", o.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, role := range roles.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: role.Name, Namespace: role.Namespace}})
+	}
+	return requests
+}
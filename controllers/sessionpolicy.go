@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// sessionPolicyArns validates the expected session policies of the Role, which have to be ARNs of managed policies,
+// as AssumeRole takes nothing else
+func sessionPolicyArns(role *iamv1beta1.Role) ([]string, error) {
+	var arns []string
+	for _, policyArn := range role.Spec.SessionPolicies {
+		parsed, err := awsarn.Parse(policyArn)
+		if err != nil || parsed.Service != "iam" || !strings.HasPrefix(parsed.Resource, "policy/") {
+			return nil, fmt.Errorf("session policy '%s' is not the ARN of a managed policy", policyArn)
+		}
+		if containsString(arns, policyArn) {
+			return nil, fmt.Errorf("session policy '%s' is given more than once", policyArn)
+		}
+		arns = append(arns, policyArn)
+	}
+	return arns, nil
+}
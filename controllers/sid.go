@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/redradrat/cloud-objects/aws/iam"
+)
+
+// checkUniqueSids rejects policy documents in which more than one statement carries the same SID. AWS requires SIDs
+// to be unique within a document, but its error doesn't say which one is duplicated. Statements without SID are
+// ignored.
+func checkUniqueSids(doc iam.PolicyDocument) error {
+	seen := make(map[string]int)
+	for i, entry := range doc.Statement {
+		if entry.Sid == "" {
+			continue
+		}
+		if first, ok := seen[entry.Sid]; ok {
+			return fmt.Errorf("statement SID '%s' is not unique; it is used by statements %d and %d", entry.Sid, first+1, i+1)
+		}
+		seen[entry.Sid] = i
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"sigs.k8s.io/yaml"
+)
+
+// SimulationCase is a single assertion on a principal, e.g. "may call s3:GetObject on the bucket"
+type SimulationCase struct {
+	Action string `json:"action"`
+	// Resource defaults to all resources ("*")
+	Resource string `json:"resource,omitempty"`
+	// Expect is either "allow" or "deny"
+	Expect string `json:"expect"`
+}
+
+// SimulationResult holds the simulated decision for a single case
+type SimulationResult struct {
+	Case     SimulationCase
+	Decision string
+}
+
+func (r SimulationResult) Allowed() bool {
+	return r.Decision == awsiam.PolicyEvaluationDecisionTypeAllowed
+}
+
+// Passed tells whether the simulated decision is the expected one; explicit and implicit denies both count as deny
+func (r SimulationResult) Passed() bool {
+	return r.Allowed() == (r.Case.Expect == "allow")
+}
+
+// RunSimulation simulates the cases in the given file for the principal, and writes a pass/fail report. It returns
+// whether all cases passed.
+func RunSimulation(region string, principalArn string, casesFile string, out io.Writer) (bool, error) {
+	f, err := os.Open(casesFile)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	cases, err := ReadSimulationCases(f)
+	if err != nil {
+		return false, err
+	}
+
+	sess, err := session.NewSession(&awssdk.Config{Region: awssdk.String(region)})
+	if err != nil {
+		return false, err
+	}
+	results, err := Simulate(awsiam.New(sess), principalArn, cases)
+	if err != nil {
+		return false, err
+	}
+	return WriteSimulationReport(out, principalArn, results), nil
+}
+
+// ReadSimulationCases reads a YAML (or JSON) list of cases
+func ReadSimulationCases(in io.Reader) ([]SimulationCase, error) {
+	b, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	var cases []SimulationCase
+	if err := yaml.UnmarshalStrict(b, &cases); err != nil {
+		return nil, err
+	}
+	for i, c := range cases {
+		if c.Action == "" {
+			return nil, fmt.Errorf("case %d has no action", i+1)
+		}
+		c.Expect = strings.ToLower(c.Expect)
+		if c.Expect != "allow" && c.Expect != "deny" {
+			return nil, fmt.Errorf("case %d expects '%s'; must be 'allow' or 'deny'", i+1, c.Expect)
+		}
+		if c.Resource == "" {
+			c.Resource = "*"
+		}
+		cases[i] = c
+	}
+	return cases, nil
+}
+
+// Simulate evaluates every case for the principal via SimulatePrincipalPolicy, in order of the cases
+func Simulate(svc iamiface.IAMAPI, principalArn string, cases []SimulationCase) ([]SimulationResult, error) {
+	var results []SimulationResult
+	for _, c := range cases {
+		decision := ""
+		err := svc.SimulatePrincipalPolicyPages(&awsiam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: awssdk.String(principalArn),
+			ActionNames:     awssdk.StringSlice([]string{c.Action}),
+			ResourceArns:    awssdk.StringSlice([]string{c.Resource}),
+		}, func(page *awsiam.SimulatePolicyResponse, lastPage bool) bool {
+			for _, res := range page.EvaluationResults {
+				decision = awssdk.StringValue(res.EvalDecision)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if decision == "" {
+			return nil, fmt.Errorf("simulation returned no decision for '%s' on '%s'", c.Action, c.Resource)
+		}
+		results = append(results, SimulationResult{Case: c, Decision: decision})
+	}
+	return results, nil
+}
+
+// WriteSimulationReport writes one line per case and a summary. It returns whether all cases passed.
+func WriteSimulationReport(out io.Writer, principalArn string, results []SimulationResult) bool {
+	failed := 0
+	fmt.Fprintf(out, "Simulated %d cases for '%s':\n", len(results), principalArn)
+	for _, res := range results {
+		verdict := "PASS"
+		if !res.Passed() {
+			verdict = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(out, "  %s %s %s on %s (%s)\n", verdict, res.Case.Expect, res.Case.Action, res.Case.Resource, res.Decision)
+	}
+	if failed > 0 {
+		fmt.Fprintf(out, "%d of %d cases failed\n", failed, len(results))
+		return false
+	}
+	fmt.Fprintln(out, "All cases passed")
+	return true
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"bytes"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Simulate", func() {
+	var fake *fakeIAM
+
+	BeforeEach(func() {
+		fake = installFakeIAM()
+		decisions := map[string]string{
+			"s3:GetObject":    awsiam.PolicyEvaluationDecisionTypeAllowed,
+			"s3:DeleteObject": awsiam.PolicyEvaluationDecisionTypeExplicitDeny,
+			"iam:CreateUser":  awsiam.PolicyEvaluationDecisionTypeImplicitDeny,
+		}
+		fake.respond("SimulatePrincipalPolicy", func(r *request.Request) {
+			action := awssdk.StringValue(r.Params.(*awsiam.SimulatePrincipalPolicyInput).ActionNames[0])
+			r.Data.(*awsiam.SimulatePolicyResponse).EvaluationResults = []*awsiam.EvaluationResult{
+				{EvalActionName: awssdk.String(action), EvalDecision: awssdk.String(decisions[action])},
+			}
+		})
+	})
+
+	AfterEach(func() {
+		uninstallFakeIAM()
+	})
+
+	It("reports each case as passed or failed, counting explicit and implicit denies as deny", func() {
+		cases, err := ReadSimulationCases(strings.NewReader(`
+- action: s3:GetObject
+  resource: arn:aws:s3:::reports/*
+  expect: allow
+- action: s3:DeleteObject
+  resource: arn:aws:s3:::reports/*
+  expect: Deny
+- action: iam:CreateUser
+  expect: allow
+`))
+		Expect(err).NotTo(HaveOccurred())
+		svc, err := IAMService("eu-west-1", false)
+		Expect(err).NotTo(HaveOccurred())
+
+		results, err := Simulate(svc, "arn:aws:iam::123456789012:role/reporter", cases)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(3))
+		Expect(results[0].Passed()).To(BeTrue())
+		Expect(results[1].Passed()).To(BeTrue())
+		Expect(results[2].Passed()).To(BeFalse())
+
+		out := &bytes.Buffer{}
+		Expect(WriteSimulationReport(out, "arn:aws:iam::123456789012:role/reporter", results)).To(BeFalse())
+		Expect(out.String()).To(Equal(`Simulated 3 cases for 'arn:aws:iam::123456789012:role/reporter':
+  PASS allow s3:GetObject on arn:aws:s3:::reports/* (allowed)
+  PASS deny s3:DeleteObject on arn:aws:s3:::reports/* (explicitDeny)
+  FAIL allow iam:CreateUser on * (implicitDeny)
+1 of 3 cases failed
+`))
+	})
+
+	It("fails on a case the simulation returns no decision for", func() {
+		svc, err := IAMService("eu-west-1", false)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = Simulate(svc, "arn:aws:iam::123456789012:role/reporter", []SimulationCase{{Action: "s3:PutObject", Resource: "*", Expect: "deny"}})
+		Expect(err).To(MatchError("simulation returned no decision for 's3:PutObject' on '*'"))
+	})
+})
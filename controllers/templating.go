@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// placeholder for the namespace, name or an annotation of the resource itself, e.g. "${namespace}/${name}" or
+// "${annotation:team}"; anything else (e.g. ${ref:<alias>} or IAM policy variables) is left alone
+var templatePlaceholder = regexp.MustCompile(`\$\{(namespace|name|annotation:([^}]*))\}`)
+
+// expandTemplate replaces the placeholders in s with the namespace, name or annotations of obj. Descriptions and tag
+// values of all resources are expanded by it, so they can be templated the same way. Placeholders of missing
+// annotations are rejected, rather than ending up in AWS.
+func expandTemplate(s string, obj metav1.Object) (string, error) {
+	var missing []string
+	expanded := templatePlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+		match := templatePlaceholder.FindStringSubmatch(placeholder)
+		switch match[1] {
+		case "namespace":
+			return obj.GetNamespace()
+		case "name":
+			return obj.GetName()
+		}
+		value, ok := obj.GetAnnotations()[match[2]]
+		if !ok {
+			missing = append(missing, match[2])
+			return placeholder
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template '%s' refers to missing annotations: %s", s, strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// expandTemplateValues expands the placeholders in all values of the given map (e.g. tags); the keys stay untouched
+func expandTemplateValues(values map[string]string, obj metav1.Object) (map[string]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	expanded := make(map[string]string, len(values))
+	for key, value := range values {
+		v, err := expandTemplate(value, obj)
+		if err != nil {
+			return nil, fmt.Errorf("tag '%s': %v", key, err)
+		}
+		expanded[key] = v
+	}
+	return expanded, nil
+}
+
+// templateAnnotations returns the annotations used by the placeholders in the given templates, as sorted "key=value"
+// pairs. Templated values change with these annotations, without the spec (and the generation) changing, so they go
+// into the spec hash.
+func templateAnnotations(obj metav1.Object, templates ...string) []string {
+	var pairs []string
+	for _, s := range templates {
+		for _, match := range templatePlaceholder.FindAllStringSubmatch(s, -1) {
+			if match[1] == "namespace" || match[1] == "name" {
+				continue
+			}
+			pair := match[2] + "=" + obj.GetAnnotations()[match[2]]
+			if !containsString(pairs, pair) {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	sort.Strings(pairs)
+	return pairs
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+const truncationMarker = "..."
+
+// WithStatusMessageLimit returns a client, that truncates the status message of our resources to the given number
+// of bytes when writing the status. Long AWS errors otherwise bloat etcd and clutter kubectl. The full message is
+// emitted as event instead, once per message. A limit <= 0 returns the client as is.
+func WithStatusMessageLimit(c client.Client, limit int, notifier *Notifier) client.Client {
+	if limit <= 0 {
+		return c
+	}
+	return &statusMessageLimitClient{Client: c, limit: limit, notifier: notifier, emitted: map[string]string{}}
+}
+
+type statusMessageLimitClient struct {
+	client.Client
+	limit    int
+	notifier *Notifier
+
+	// the full message last emitted for an object; the same message is written on every resync, but only the first
+	// write needs an event
+	mu      sync.Mutex
+	emitted map[string]string
+}
+
+func (c *statusMessageLimitClient) Status() client.StatusWriter {
+	return &statusMessageLimitWriter{StatusWriter: c.Client.Status(), limit: c.limit, client: c}
+}
+
+// changedMessage records the full message as the current one of the object, and tells whether it differs from the
+// one before
+func (c *statusMessageLimitClient) changedMessage(obj client.Object, message string) bool {
+	key := fmt.Sprintf("%T/%s", obj, client.ObjectKeyFromObject(obj))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if message == "" {
+		delete(c.emitted, key)
+		return false
+	}
+	if c.emitted[key] == message {
+		return false
+	}
+	c.emitted[key] = message
+	return true
+}
+
+type statusMessageLimitWriter struct {
+	client.StatusWriter
+	limit  int
+	client *statusMessageLimitClient
+}
+
+func (w *statusMessageLimitWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	w.truncate(obj)
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *statusMessageLimitWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	w.truncate(obj)
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+func (w *statusMessageLimitWriter) truncate(obj client.Object) {
+	res, ok := obj.(AWSObjectStatusResource)
+	if !ok {
+		return
+	}
+	status := res.GetStatus()
+	truncated := truncateMessage(status.Message, w.limit)
+	if truncated == status.Message {
+		w.client.changedMessage(obj, "")
+		return
+	}
+
+	if w.client.changedMessage(obj, status.Message) {
+		eventtype := v1.EventTypeNormal
+		if status.State == iamv1beta1.ErrorSyncState {
+			eventtype = v1.EventTypeWarning
+		}
+		w.client.notifier.Notify(obj, eventtype, "StatusMessageTruncated", status.Message)
+	}
+	status.Message = truncated
+}
+
+// truncateMessage cuts the message to at most limit bytes, keeping its beginning (where AWS puts the error code)
+// and marking the cut. It never splits a multi-byte character.
+func truncateMessage(message string, limit int) string {
+	if len(message) <= limit {
+		return message
+	}
+	if limit <= len(truncationMarker) {
+		return truncationMarker[:limit]
+	}
+	cut := limit - len(truncationMarker)
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut] + truncationMarker
+}
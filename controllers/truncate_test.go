@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+var _ = Describe("WithStatusMessageLimit", func() {
+	It("truncates the message in the status, and emits the full one once per message", func() {
+		ctx := context.Background()
+		recorder := record.NewFakeRecorder(10)
+		c := WithStatusMessageLimit(k8sClient, 20, NewNotifier(recorder, ""))
+
+		policy := &iamv1beta1.Policy{ObjectMeta: metav1.ObjectMeta{Name: uniqueName("policy"), Namespace: "default"}}
+		Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+		long := "AccessDenied: " + strings.Repeat("x", 100)
+		for i := 0; i < 3; i++ {
+			policy.Status.State = iamv1beta1.ErrorSyncState
+			policy.Status.Message = long
+			Expect(c.Status().Update(ctx, policy)).To(Succeed())
+		}
+
+		current := &iamv1beta1.Policy{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(policy), current)).To(Succeed())
+		Expect(current.Status.Message).To(Equal("AccessDenied: xxx..."))
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(<-recorder.Events).To(Equal("Warning StatusMessageTruncated " + long))
+
+		changed := "Throttling: " + strings.Repeat("y", 100)
+		policy.Status.Message = changed
+		Expect(c.Status().Update(ctx, policy)).To(Succeed())
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(<-recorder.Events).To(Equal("Warning StatusMessageTruncated " + changed))
+	})
+})
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/redradrat/cloud-objects/aws/iam"
+)
+
+// DefaultTrustPolicySizeLimit is the default maximum size (in characters) of a trust policy; it can be raised up to
+// 4096 via a quota increase
+const DefaultTrustPolicySizeLimit = 2048
+
+// compactTrustPolicy merges statements that only differ in their actions, which keeps trust policies with many
+// statements for the same principal (e.g. generated ones) within the size limit. Statements with a SID are kept as
+// they are, so they can still be told apart.
+func compactTrustPolicy(doc iam.PolicyDocument) iam.PolicyDocument {
+	var statement []iam.StatementEntry
+	for _, entry := range doc.Statement {
+		merged := false
+		if entry.Sid == "" {
+			for i, existing := range statement {
+				if existing.Sid == "" && existing.Effect == entry.Effect &&
+					reflect.DeepEqual(existing.Principal, entry.Principal) &&
+					reflect.DeepEqual(existing.Condition, entry.Condition) &&
+					reflect.DeepEqual(existing.Resource, entry.Resource) {
+					statement[i].Action = mergeActions(existing.Action, entry.Action)
+					merged = true
+					break
+				}
+			}
+		}
+		if !merged {
+			// don't share the actions with the original document, we might append to them
+			entry.Action = append([]string(nil), entry.Action...)
+			statement = append(statement, entry)
+		}
+	}
+	doc.Statement = statement
+	return doc
+}
+
+// mergeActions appends the actions not yet contained, keeping their order
+func mergeActions(actions []string, more []string) []string {
+	for _, action := range more {
+		if !containsString(actions, action) {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// checkTrustPolicySize fails for a (compacted) trust policy exceeding the size limit. Statements trusting different
+// principals can't be merged, as a statement holds a single principal of each type; it's up to the spec to trust
+// fewer of them, e.g. whole accounts instead of single roles.
+func (r *RoleReconciler) checkTrustPolicySize(doc iam.PolicyDocument) error {
+	limit := r.TrustPolicySizeLimit
+	if limit == 0 {
+		limit = DefaultTrustPolicySizeLimit
+	}
+	size, err := trustPolicySize(doc)
+	if err != nil {
+		return err
+	}
+	if size > limit {
+		return fmt.Errorf("trust policy has %d characters after compaction, exceeding the limit of %d characters; trust fewer principals, or raise the limit along with the quota of the account", size, limit)
+	}
+	return nil
+}
+
+// trustPolicySize returns the size of the trust policy as submitted to AWS
+func trustPolicySize(doc iam.PolicyDocument) (int, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
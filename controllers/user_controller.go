@@ -19,7 +19,12 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/go-logr/logr"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -41,6 +46,10 @@ const (
 	AccesskeySecretSuffix    = "-accesskey"
 	AccesskeySecretIdKey     = "id"
 	AccesskeySecretSecretKey = "secret"
+
+	ServiceCredentialSecretSuffix  = "-credential"
+	ServiceCredentialSecretUserKey = "username"
+	ServiceCredentialSecretPassKey = "password"
 )
 
 // UserReconciler reconciles a User object
@@ -50,6 +59,16 @@ type UserReconciler struct {
 	Region         string
 	Scheme         *runtime.Scheme
 	ResourcePrefix string
+	Debouncer      *Debouncer
+	Notifier       *Notifier
+	ReadOnly       bool
+	CreateOnly     bool
+	// MaxManagedEntities caps the number of AWS entities the operator creates; 0 disables the cap
+	MaxManagedEntities int
+	// GuardBoundaryRemoval requires the removal of a permissions boundary to be confirmed via annotation
+	GuardBoundaryRemoval bool
+	// ProtectionTag protects AWS entities carrying this tag key from deletion; empty disables the protection
+	ProtectionTag string
 }
 
 // +kubebuilder:rbac:groups=aws-iam.redradrat.xyz,resources=users,verbs=get;list;watch;create;update;patch;delete
@@ -69,37 +88,81 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	err := r.Get(ctx, req.NamespacedName, &user)
 	if err != nil {
 		log.V(1).Info("unable to fetch User")
+		r.Debouncer.Forget(req.NamespacedName)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	// return if only status/metadata updated
-	if user.Status.ObservedGeneration == user.ObjectMeta.Generation && user.Status.State == iamv1beta1.OkSyncState {
-		return ctrl.Result{}, nil
+	sw := newStatusPatcher(r.Status(), &user)
+
+	// console access that outlived its TTL has to be removed, even if nothing else changed
+	consoleExpired := user.ObjectMeta.DeletionTimestamp.IsZero() && loginProfileExpired(&user)
+
+	// return if only status/metadata updated; time-boxed console access still has to be removed once it expires, and
+	// annotations used by the tags are checked via the spec hash below
+	var tagTemplates []string
+	for _, value := range user.Spec.Tags {
+		tagTemplates = append(tagTemplates, value)
+	}
+	templated := templateAnnotations(&user, tagTemplates...)
+	if !consoleExpired && len(templated) == 0 && user.Status.ObservedGeneration == user.ObjectMeta.Generation && user.Status.State == iamv1beta1.OkSyncState {
+		return ctrl.Result{RequeueAfter: loginProfileExpiresIn(&user)}, nil
+	}
+
+	hash, err := specHash(user.Spec, templated...)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+
+	// return if the spec is identical to the last applied one (e.g. an unchanged manifest has been re-applied)
+	if user.ObjectMeta.DeletionTimestamp.IsZero() && !consoleExpired && lastAppliedSpecMatches(&user, hash) {
+		return ctrl.Result{RequeueAfter: loginProfileExpiresIn(&user)}, observeGeneration(ctx, &user, sw)
+	}
+
+	// wait for rapid consecutive spec changes to settle, before we talk to AWS
+	if user.ObjectMeta.DeletionTimestamp.IsZero() {
+		if wait := r.Debouncer.Wait(req.NamespacedName, user.ObjectMeta.Generation); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	// don't act before the gate is open; deletions are never held back
+	if user.ObjectMeta.DeletionTimestamp.IsZero() {
+		reason, err := gateClosed(ctx, r.Client, user.Spec.Gate, user.Namespace)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+		}
+		if reason != "" {
+			log.Info(reason)
+			return ctrl.Result{RequeueAfter: gateRetryInterval}, nil
+		}
 	}
 
 	// the finalizer for deleting the actual aws resources
 	usersFinalizer := "user.aws-iam.redradrat.xyz"
 
 	// Get our actual IAM Service to communicate with AWS; we don't need to continue without it
-	iamsvc, err := IAMService(r.Region)
+	iamsvc, err := IAMService(r.Region, r.ReadOnly)
 	if err != nil {
-		return ctrl.Result{}, errWithStatus(ctx, &user, err, r.Status())
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
 	}
 
+	// an expired Login Profile stays removed, until it is requested anew
+	loginProfile := user.Spec.CreateLoginProfile && !consoleExpired && !user.Status.LoginProfileExpired
+
 	// new user instance
 	userName := r.ResourcePrefix + user.Name
 	var ins *iam.UserInstance
 	if user.Status.ARN != "" {
 		parsedArn, err := aws.ARNify(user.Status.ARN)
 		if err != nil {
-			return ctrl.Result{}, errWithStatus(ctx, &user, fmt.Errorf("ARN in User status is not valid/parsable"), r.Status())
+			return ctrl.Result{}, errWithStatus(ctx, &user, fmt.Errorf("ARN in User status is not valid/parsable"), sw)
 		}
-		ins = iam.NewExistingUserInstance(userName, user.Spec.CreateLoginProfile, user.Status.LoginProfileCreated, user.Spec.CreateProgrammaticAccess, user.Status.ProgrammaticAccessCreated, parsedArn[len(parsedArn)-1])
+		ins = iam.NewExistingUserInstance(userName, loginProfile, user.Status.LoginProfileCreated, user.Spec.CreateProgrammaticAccess, user.Status.ProgrammaticAccessCreated, parsedArn[len(parsedArn)-1])
 	} else {
-		ins = iam.NewUserInstance(userName, user.Spec.CreateLoginProfile, user.Spec.CreateProgrammaticAccess)
+		ins = iam.NewUserInstance(userName, loginProfile, user.Spec.CreateProgrammaticAccess)
 	}
 
-	cleanupFunc := userCleanup(r, ctx, user)
+	cleanupFunc := userCleanup(r, ctx, user, iamsvc, userName)
 
 	// Check Deletion and finalizer
 	if user.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -116,15 +179,23 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	} else {
 		if containsString(user.ObjectMeta.Finalizers, usersFinalizer) {
 			// our finalizer is present, so lets handle any external dependency
+			if r.ReadOnly {
+				return ctrl.Result{}, skipWithStatus(ctx, &user, fmt.Sprintf("read-only mode: would delete User '%s'", userName), sw)
+			}
 
-			// delete the actual AWS Object and pass the cleanup function
-			statusUpdater, err := DeleteAWSObject(iamsvc, ins, cleanupFunc)
-			// we got a StatusUpdater function returned... let's execute it
-			statusUpdater(ctx, ins, &user, r.Status(), log)
-			if err != nil {
-				// we had an error during AWS Object deletion... so we return here to retry
-				log.Error(err, "unable to delete User")
-				return ctrl.Result{}, err
+			if r.CreateOnly {
+				// leave the AWS object untouched, but let the resource go
+				log.Info(fmt.Sprintf("create-only mode: leaving User '%s' in AWS", userName))
+			} else {
+				// delete the actual AWS Object and pass the cleanup function
+				statusUpdater, err := DeleteAWSObject(iamsvc, ins, cleanupFunc)
+				// we got a StatusUpdater function returned... let's execute it
+				statusUpdater(ctx, ins, &user, sw, log)
+				if err != nil {
+					// we had an error during AWS Object deletion... so we return here to retry
+					log.Error(err, "unable to delete User")
+					return ctrl.Result{}, err
+				}
 			}
 
 			// remove our finalizer from the list and update it.
@@ -133,6 +204,9 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 				log.Error(err, "unable to remove finalizer from User")
 				return ctrl.Result{}, err
 			}
+			if !r.CreateOnly {
+				r.Notifier.Notify(&user, v1.EventTypeNormal, "Deleted", fmt.Sprintf("Deleted User '%s'", user.Status.ARN))
+			}
 		}
 
 		// Stop reconciliation as the item is being deleted
@@ -141,6 +215,41 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 
 	// RECONCILE THE RESOURCE
 
+	if r.ReadOnly {
+		action := "create"
+		if user.Status.ARN != "" {
+			action = "update"
+		}
+		return ctrl.Result{}, skipWithStatus(ctx, &user, fmt.Sprintf("read-only mode: would %s User '%s'", action, userName), sw)
+	}
+
+	if r.CreateOnly && user.Status.ARN != "" {
+		return ctrl.Result{}, skipWithStatus(ctx, &user, fmt.Sprintf("create-only mode: not updating existing User '%s'", userName), sw)
+	}
+
+	if user.Status.ARN == "" {
+		if err := checkManagedEntityCap(ctx, r.Client, r.MaxManagedEntities); err != nil {
+			r.Notifier.Notify(&user, v1.EventTypeWarning, "ManagedEntityCapReached", err.Error())
+			return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+		}
+	}
+
+	if r.GuardBoundaryRemoval {
+		if err := checkBoundaryRemoval(&user, user.Spec.PermissionsBoundary); err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+		}
+	}
+
+	// the operator can't write the secret of keys it doesn't create
+	if user.Spec.AccessKeysStatusOnly && user.Spec.CreateProgrammaticAccess {
+		return ctrl.Result{}, errWithStatus(ctx, &user, fmt.Errorf("accessKeysStatusOnly cannot be combined with createProgrammaticAccess"), sw)
+	}
+
+	// refuse invalid inline policies before we touch the existing User
+	if _, _, err := inlinePolicyDocuments(iamv1beta1.UserTargetType, user.Spec.InlinePolicies); err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+
 	loginSecret := user.Name + LoginSecretSuffix
 	accessKeySecret := user.Name + AccesskeySecretSuffix
 
@@ -149,7 +258,7 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	if user.Status.ARN != "" {
 		// User already exists; we need to update it
 		statusUpdater, err := UpdateAWSObject(iamsvc, ins, DoNothingPreFunc)
-		statusUpdater(ctx, ins, &user, r.Status(), log)
+		statusUpdater(ctx, ins, &user, sw, log)
 		if err != nil {
 			log.Error(err, "error while updating User during reconciliation")
 			return ctrl.Result{}, err
@@ -157,15 +266,66 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	} else {
 		// User does not yet exist, let's create it
 		statusUpdater, err := CreateAWSObject(iamsvc, ins, DoNothingPreFunc)
-		statusUpdater(ctx, ins, &user, r.Status(), log)
+		statusUpdater(ctx, ins, &user, sw, log)
 		if err != nil {
 			log.Error(err, "error while creating User during reconciliation")
 			return ctrl.Result{}, err
 		}
 	}
 
+	if err = reconcilePermissionsBoundary(iamsvc, &user, iamv1beta1.UserTargetType, userName, user.Spec.PermissionsBoundary); err != nil {
+		log.Error(err, "unable to apply permissions boundary to User")
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+
+	// an inline policy converted to a managed one stays in place, until its replacement is attached
+	keepRemoved := false
+	if removed := removedInlinePolicies(user.Spec.InlinePolicies, user.Status.InlinePolicies); len(removed) != 0 {
+		pending, err := pendingPolicyAttachments(ctx, r.Client, iamv1beta1.UserTargetType, user.Namespace, user.Name)
+		if err != nil {
+			return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+		}
+		if len(pending) != 0 {
+			log.Info(fmt.Sprintf("keeping inline policies %v of User until PolicyAttachments %v are attached", removed, pending))
+			keepRemoved = true
+		}
+	}
+
+	applied, size, err := reconcileInlinePolicies(iamsvc, iamv1beta1.UserTargetType, userName, user.Spec.InlinePolicies, user.Status.InlinePolicies, keepRemoved)
+	user.Status.InlinePolicies = applied
+	user.Status.InlinePolicySize = size
+	if err != nil {
+		log.Error(err, "unable to apply inline policies to User")
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+
+	managedPolicies, err := userManagedPolicyArns(ctx, r.Client, &user, ins.ARN())
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+	heldPolicies, err := heldUserPolicyArns(ctx, r.Client, &user)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+	user.Status.ManagedPolicies, err = reconcileUserPolicies(iamsvc, userName, managedPolicies, user.Status.ManagedPolicies, heldPolicies)
+	if err != nil {
+		log.Error(err, "unable to attach managed policies to User")
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+
+	desiredTags, err := expandTemplateValues(user.Spec.Tags, &user)
+	if err != nil {
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+	tags, err := reconcileUserTags(iamsvc, userName, desiredTags, user.Status.Tags)
+	user.Status.Tags = tags
+	if err != nil {
+		log.Error(err, "unable to apply tags to User")
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+
 	// Create Secret if Login Profile
-	if user.Spec.CreateLoginProfile {
+	if loginProfile {
 		if !user.Status.LoginProfileCreated {
 			data := map[string]string{LoginSecretUserKey: ins.LoginProfileCredentials().Username(), LoginSecretPassKey: ins.LoginProfileCredentials().Password()}
 			sec := userSecret(data, loginSecret, user.Namespace)
@@ -177,7 +337,13 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 			}
 			user.Status.LoginProfileCreated = true
 			user.Status.LoginProfileSecret = v1.SecretReference{Name: sec.Name, Namespace: sec.Namespace}
-			r.Status().Update(ctx, &user)
+			user.Status.LoginProfileGrantedAt = time.Now().Format(time.RFC3339)
+			if err := sw.Update(ctx, &user); err != nil {
+				return ctrl.Result{}, err
+			}
+		} else if user.Status.LoginProfileGrantedAt == "" {
+			// the grant time of Login Profiles created before isn't known, so their TTL starts now
+			user.Status.LoginProfileGrantedAt = time.Now().Format(time.RFC3339)
 		}
 	} else {
 		sec := &v1.Secret{}
@@ -190,9 +356,21 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 			}
 			user.Status.LoginProfileCreated = false
 			user.Status.LoginProfileSecret = v1.SecretReference{}
-			r.Status().Update(ctx, &user)
+			user.Status.LoginProfileGrantedAt = ""
+			if err := sw.Update(ctx, &user); err != nil {
+				return ctrl.Result{}, err
+			}
 		}
 	}
+	if consoleExpired {
+		user.Status.LoginProfileCreated = false
+		user.Status.LoginProfileGrantedAt = ""
+		user.Status.LoginProfileExpired = true
+		r.Notifier.Notify(&user, v1.EventTypeNormal, "LoginProfileExpired", fmt.Sprintf("Removed console access of User '%s', as its TTL of %s expired", userName, user.Spec.LoginProfileTTL.Duration))
+	}
+	if !user.Spec.CreateLoginProfile {
+		user.Status.LoginProfileExpired = false
+	}
 
 	if user.Spec.CreateProgrammaticAccess {
 		if !user.Status.ProgrammaticAccessCreated {
@@ -206,7 +384,9 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 			}
 			user.Status.ProgrammaticAccessCreated = true
 			user.Status.ProgrammaticAccessSecret = v1.SecretReference{Name: sec.Name, Namespace: sec.Namespace}
-			r.Status().Update(ctx, &user)
+			if err := sw.Update(ctx, &user); err != nil {
+				return ctrl.Result{}, err
+			}
 		}
 	} else {
 		sec := &v1.Secret{}
@@ -219,15 +399,50 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 			}
 			user.Status.ProgrammaticAccessCreated = false
 			user.Status.ProgrammaticAccessSecret = v1.SecretReference{}
-			r.Status().Update(ctx, &user)
+			if err := sw.Update(ctx, &user); err != nil {
+				return ctrl.Result{}, err
+			}
 		}
 	}
 
-	user.Status.ObservedGeneration = user.ObjectMeta.Generation
-	r.Status().Update(ctx, &user)
+	user.Status.AccessKeys = nil
+	if user.Spec.AccessKeysStatusOnly {
+		if user.Status.AccessKeys, err = listAccessKeyStatus(iamsvc, userName); err != nil {
+			log.Error(err, "unable to list access keys of User")
+			return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+		}
+	}
+
+	if err = r.reconcileServiceSpecificCredentials(ctx, iamsvc, &user, userName, sw); err != nil {
+		log.Error(err, "error while reconciling service-specific credentials of User")
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+
+	if err = r.reconcileGroupMemberships(ctx, iamsvc, &user, userName); err != nil {
+		log.Error(err, "error while reconciling group memberships of User")
+		return ctrl.Result{}, errWithStatus(ctx, &user, err, sw)
+	}
+
+	// the spec is only applied completely, once the kept inline policies are gone
+	if keepRemoved {
+		return ctrl.Result{RequeueAfter: conversionRetryInterval}, sw.Update(ctx, &user)
+	}
+
+	user.Status.ObservedGeneration = sw.generation
+	if err := sw.Update(ctx, &user); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := storeLastAppliedSpecHash(ctx, r.Client, &user, hash); err != nil {
+		log.Error(err, "unable to store last applied spec hash for User")
+		return ctrl.Result{}, err
+	}
+	r.Notifier.Notify(&user, v1.EventTypeNormal, "Reconciled", fmt.Sprintf("Reconciled User '%s'", user.Status.ARN))
 
 	log.Info(fmt.Sprintf("Created User '%s'", user.Status.ARN))
-	return ctrl.Result{}, nil
+
+	// come back to remove time-boxed console access once it expires
+	return ctrl.Result{RequeueAfter: loginProfileExpiresIn(&user)}, nil
 }
 
 func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -237,8 +452,12 @@ func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 // Returns a function, that does everything necessary before we can delete our actual User (cleanup)
-func userCleanup(r *UserReconciler, ctx context.Context, user iamv1beta1.User) func() error {
+func userCleanup(r *UserReconciler, ctx context.Context, user iamv1beta1.User, svc iamiface.IAMAPI, userName string) func() error {
 	return func() error {
+		if err := checkDeletionProtection(svc, iamv1beta1.UserTargetType, userName, r.ProtectionTag); err != nil {
+			r.Notifier.Notify(&user, v1.EventTypeWarning, "DeletionProtected", err.Error())
+			return err
+		}
 		attachments := iamv1beta1.PolicyAttachmentList{}
 		if err := r.List(ctx, &attachments); err != nil {
 			return err
@@ -251,11 +470,167 @@ func userCleanup(r *UserReconciler, ctx context.Context, user iamv1beta1.User) f
 				}
 			}
 		}
+		sets, err := setsAttachingTo(ctx, r.Client, iamv1beta1.UserTargetType, user.Namespace, user.Name)
+		if err != nil {
+			return err
+		}
+		if len(sets) != 0 {
+			return fmt.Errorf("cannot delete User due to existing PolicyAttachmentSet '%s/%s'", sets[0].Name, sets[0].Namespace)
+		}
+
+		// AWS refuses to delete users that still have service-specific credentials, inline or attached policies or groups
+		if user.Status.ARN != "" {
+			if err := deleteServiceSpecificCredentials(svc, userName); err != nil {
+				return err
+			}
+			if err := deleteInlinePolicies(svc, iamv1beta1.UserTargetType, userName, user.Status.InlinePolicies); err != nil {
+				return err
+			}
+			if err := detachUserPolicies(svc, userName, user.Status.ManagedPolicies); err != nil {
+				return err
+			}
+			for _, groupName := range user.Status.Groups {
+				if err := removeUserFromGroup(svc, userName, groupName); err != nil {
+					return err
+				}
+			}
+		}
 
 		return nil
 	}
 }
 
+// reconcileServiceSpecificCredentials creates the service-specific credentials requested in the spec, removes the
+// ones that are not requested anymore and refreshes the AWS status of the remaining ones
+func (r *UserReconciler) reconcileServiceSpecificCredentials(ctx context.Context, svc iamiface.IAMAPI, user *iamv1beta1.User, userName string, sw client.StatusWriter) error {
+	listOut, err := svc.ListServiceSpecificCredentials(&awsiam.ListServiceSpecificCredentialsInput{
+		UserName: awssdk.String(userName),
+	})
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*awsiam.ServiceSpecificCredentialMetadata)
+	for _, meta := range listOut.ServiceSpecificCredentials {
+		existing[awssdk.StringValue(meta.ServiceSpecificCredentialId)] = meta
+	}
+
+	var credentials []iamv1beta1.ServiceSpecificCredentialStatus
+	for _, cred := range user.Status.ServiceSpecificCredentials {
+		if !containsString(user.Spec.ServiceSpecificCredentials, cred.ServiceName) {
+			// not requested anymore, so remove it from AWS together with its secret
+			if err := deleteServiceSpecificCredential(svc, userName, cred.CredentialID); err != nil {
+				return err
+			}
+			sec := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: cred.Secret.Name, Namespace: cred.Secret.Namespace}}
+			if err := r.Client.Delete(ctx, sec); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			continue
+		}
+		meta, ok := existing[cred.CredentialID]
+		if !ok {
+			// the credential has been removed outside of the operator; we create a new one below
+			continue
+		}
+		cred.Status = awssdk.StringValue(meta.Status)
+		credentials = append(credentials, cred)
+	}
+	user.Status.ServiceSpecificCredentials = credentials
+
+	for _, service := range user.Spec.ServiceSpecificCredentials {
+		if hasServiceSpecificCredential(user.Status.ServiceSpecificCredentials, service) {
+			continue
+		}
+		out, err := svc.CreateServiceSpecificCredential(&awsiam.CreateServiceSpecificCredentialInput{
+			ServiceName: awssdk.String(service),
+			UserName:    awssdk.String(userName),
+		})
+		if err != nil {
+			return err
+		}
+		created := out.ServiceSpecificCredential
+
+		// the password is only returned once, so if we cannot store it, we remove the credential again
+		data := map[string]string{
+			ServiceCredentialSecretUserKey: awssdk.StringValue(created.ServiceUserName),
+			ServiceCredentialSecretPassKey: awssdk.StringValue(created.ServicePassword),
+		}
+		sec := userSecret(data, serviceCredentialSecretName(user.Name, service), user.Namespace)
+		if err := r.storeServiceCredentialSecret(ctx, user, sec); err != nil {
+			if delErr := deleteServiceSpecificCredential(svc, userName, awssdk.StringValue(created.ServiceSpecificCredentialId)); delErr != nil {
+				return delErr
+			}
+			return err
+		}
+
+		user.Status.ServiceSpecificCredentials = append(user.Status.ServiceSpecificCredentials, iamv1beta1.ServiceSpecificCredentialStatus{
+			ServiceName:  service,
+			CredentialID: awssdk.StringValue(created.ServiceSpecificCredentialId),
+			Status:       awssdk.StringValue(created.Status),
+			Secret:       v1.SecretReference{Name: sec.Name, Namespace: sec.Namespace},
+		})
+		sw.Update(ctx, user)
+	}
+
+	return nil
+}
+
+func (r *UserReconciler) storeServiceCredentialSecret(ctx context.Context, user *iamv1beta1.User, sec *v1.Secret) error {
+	if err := ctrl.SetControllerReference(user, sec, r.Scheme); err != nil {
+		return err
+	}
+	err := r.Client.Create(ctx, sec)
+	if errors.IsAlreadyExists(err) {
+		// a leftover of a credential that has been removed outside of the operator
+		return r.Client.Update(ctx, sec)
+	}
+	return err
+}
+
+func hasServiceSpecificCredential(credentials []iamv1beta1.ServiceSpecificCredentialStatus, service string) bool {
+	for _, cred := range credentials {
+		if cred.ServiceName == service {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceCredentialSecretName returns the secret name for a service e.g. "user-sample-codecommit-credential" for
+// codecommit.amazonaws.com
+func serviceCredentialSecretName(userName, service string) string {
+	return userName + "-" + strings.SplitN(service, ".", 2)[0] + ServiceCredentialSecretSuffix
+}
+
+func deleteServiceSpecificCredential(svc iamiface.IAMAPI, userName, id string) error {
+	_, err := svc.DeleteServiceSpecificCredential(&awsiam.DeleteServiceSpecificCredentialInput{
+		ServiceSpecificCredentialId: awssdk.String(id),
+		UserName:                    awssdk.String(userName),
+	})
+	if err != nil && !aws.IsNotExistsError(err) {
+		return err
+	}
+	return nil
+}
+
+func deleteServiceSpecificCredentials(svc iamiface.IAMAPI, userName string) error {
+	out, err := svc.ListServiceSpecificCredentials(&awsiam.ListServiceSpecificCredentialsInput{
+		UserName: awssdk.String(userName),
+	})
+	if err != nil {
+		if aws.IsNotExistsError(err) {
+			return nil
+		}
+		return err
+	}
+	for _, meta := range out.ServiceSpecificCredentials {
+		if err := deleteServiceSpecificCredential(svc, userName, awssdk.StringValue(meta.ServiceSpecificCredentialId)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func userSecret(data map[string]string, name, namespace string) *v1.Secret {
 	return &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"sort"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+)
+
+// reconcileUserTags sets the given tags on the User and removes the tags applied before, that are no longer given.
+// Tags set outside of the operator are left alone, as only the previously applied keys are ever removed. It returns
+// the sorted keys of the tags applied now.
+func reconcileUserTags(svc iamiface.IAMAPI, userName string, tags map[string]string, applied []string) ([]string, error) {
+	var keys []string
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if len(keys) != 0 {
+		var awsTags []*awsiam.Tag
+		for _, key := range keys {
+			awsTags = append(awsTags, &awsiam.Tag{Key: awssdk.String(key), Value: awssdk.String(tags[key])})
+		}
+		if _, err := svc.TagUser(&awsiam.TagUserInput{UserName: awssdk.String(userName), Tags: awsTags}); err != nil {
+			return applied, err
+		}
+	}
+
+	var stale []string
+	for _, key := range applied {
+		if _, ok := tags[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) != 0 {
+		if _, err := svc.UntagUser(&awsiam.UntagUserInput{UserName: awssdk.String(userName), TagKeys: awssdk.StringSlice(stale)}); err != nil {
+			// the stale tags are still there, so keep track of them
+			remaining := append(keys, stale...)
+			sort.Strings(remaining)
+			return remaining, err
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+// PolicyVersionSweep deletes the superfluous versions of all managed Policies once on startup, which cleans up the
+// versions piled up by earlier updates
+type PolicyVersionSweep struct {
+	Client client.Client
+	Region string
+	// Keep is the number of versions to keep per policy, including the default version
+	Keep       int
+	ReadOnly   bool
+	CreateOnly bool
+	Log        logr.Logger
+}
+
+// Start implements manager.Runnable; it sweeps all Policies once and returns
+func (s *PolicyVersionSweep) Start(ctx context.Context) error {
+	if s.ReadOnly || s.CreateOnly {
+		s.Log.Info("read-only or create-only mode: not deleting any policy versions")
+		return nil
+	}
+	iamsvc, err := IAMService(s.Region, s.ReadOnly)
+	if err != nil {
+		return err
+	}
+
+	policies := iamv1beta1.PolicyList{}
+	if err := s.Client.List(ctx, &policies); err != nil {
+		return err
+	}
+	pinned, err := pinnedPolicyVersions(ctx, s.Client)
+	if err != nil {
+		return err
+	}
+
+	deleted := 0
+	for _, policy := range policies.Items {
+		if policy.Status.ARN == "" {
+			continue
+		}
+		n, err := pruneVersions(iamsvc, policy.Status.ARN, s.Keep, pinned[policy.Status.ARN])
+		deleted += n
+		if err != nil {
+			// one failing policy shouldn't keep the others from being cleaned up
+			s.Log.Error(err, fmt.Sprintf("unable to delete superfluous versions of policy '%s'", policy.Status.ARN))
+		}
+	}
+	s.Log.Info(fmt.Sprintf("deleted %d superfluous versions of %d policies", deleted, len(policies.Items)))
+	return nil
+}
+
+// pinnedPolicyVersions returns the versions pinned by Roles per policy ARN; they are kept, so pinned versions can
+// still be compared against
+func pinnedPolicyVersions(ctx context.Context, c client.Client) (map[string][]string, error) {
+	roles := iamv1beta1.RoleList{}
+	if err := c.List(ctx, &roles); err != nil {
+		return nil, err
+	}
+	pinned := make(map[string][]string)
+	for _, role := range roles.Items {
+		for _, version := range role.Status.PolicyVersions {
+			pinned[version.ARN] = append(pinned[version.ARN], version.PinnedVersion)
+		}
+	}
+	return pinned, nil
+}
+
+// pruneVersions deletes all but the newest versions of the policy, so that keep versions are left. The default
+// version and the given pinned versions are never deleted. It returns the number of deleted versions.
+func pruneVersions(svc iamiface.IAMAPI, policyArn string, keep int, pinned []string) (int, error) {
+	out, err := svc.ListPolicyVersions(&awsiam.ListPolicyVersionsInput{PolicyArn: awssdk.String(policyArn)})
+	if err != nil {
+		return 0, err
+	}
+
+	versions := out.Versions
+	sort.Slice(versions, func(i, j int) bool {
+		return awssdk.TimeValue(versions[i].CreateDate).After(awssdk.TimeValue(versions[j].CreateDate))
+	})
+
+	// the default version always counts as kept, regardless of its age
+	kept := 1
+	deleted := 0
+	for _, version := range versions {
+		if awssdk.BoolValue(version.IsDefaultVersion) || containsString(pinned, awssdk.StringValue(version.VersionId)) {
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+		if _, err := svc.DeletePolicyVersion(&awsiam.DeletePolicyVersionInput{
+			PolicyArn: awssdk.String(policyArn),
+			VersionId: version.VersionId,
+		}); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
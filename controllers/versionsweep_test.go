@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsiam "github.com/aws/aws-sdk-go/service/iam"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	iamv1beta1 "github.com/redradrat/aws-iam-operator/api/v1beta1"
+)
+
+var _ = Describe("PolicyVersionSweep", func() {
+	const policyArn = "arn:aws:iam::123456789012:policy/app"
+
+	var (
+		fake    *fakeIAM
+		sweep   *PolicyVersionSweep
+		deleted []string
+	)
+
+	BeforeEach(func() {
+		fake = installFakeIAM()
+		policy := &iamv1beta1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+		policy.Status.ARN = policyArn
+		pending := &iamv1beta1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "default"}}
+		role := &iamv1beta1.Role{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+		role.Status.PolicyVersions = []iamv1beta1.PinnedPolicyVersion{{ARN: policyArn, PinnedVersion: "v2", CurrentVersion: "v4"}}
+
+		// the shared API server holds the Policies of all other specs, which would be swept as well
+		sweep = &PolicyVersionSweep{
+			Client: fakeclient.NewClientBuilder().WithScheme(k8sClient.Scheme()).WithObjects(policy, pending, role).Build(),
+			Region: "eu-west-1",
+			Keep:   2,
+			Log:    ctrl.Log.WithName("controllers").WithName("PolicyVersionSweep"),
+		}
+
+		created := time.Now().Add(-time.Hour)
+		fake.respond("ListPolicyVersions", func(r *request.Request) {
+			Expect(awssdk.StringValue(r.Params.(*awsiam.ListPolicyVersionsInput).PolicyArn)).To(Equal(policyArn))
+			output := r.Data.(*awsiam.ListPolicyVersionsOutput)
+			for i := 1; i <= 5; i++ {
+				output.Versions = append(output.Versions, &awsiam.PolicyVersion{
+					VersionId:        awssdk.String(fmt.Sprintf("v%d", i)),
+					CreateDate:       awssdk.Time(created.Add(time.Duration(i) * time.Minute)),
+					IsDefaultVersion: awssdk.Bool(i == 4),
+				})
+			}
+		})
+		deleted = nil
+		fake.respond("DeletePolicyVersion", func(r *request.Request) {
+			deleted = append(deleted, awssdk.StringValue(r.Params.(*awsiam.DeletePolicyVersionInput).VersionId))
+		})
+	})
+
+	AfterEach(func() {
+		uninstallFakeIAM()
+	})
+
+	It("deletes the excess versions, but keeps the default and the pinned ones", func() {
+		Expect(sweep.Start(context.Background())).To(Succeed())
+		Expect(fake.Calls()).To(Equal([]string{"ListPolicyVersions", "DeletePolicyVersion", "DeletePolicyVersion"}))
+		Expect(deleted).To(Equal([]string{"v3", "v1"}))
+	})
+
+	It("doesn't delete anything in create-only mode", func() {
+		sweep.CreateOnly = true
+
+		Expect(sweep.Start(context.Background())).To(Succeed())
+		Expect(fake.Calls()).To(BeEmpty())
+	})
+})
@@ -20,10 +20,12 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/redradrat/cloud-objects/aws"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -45,6 +47,7 @@ var (
 
 func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
+	_ = apiextensionsv1.AddToScheme(scheme)
 
 	_ = iamv1beta1.AddToScheme(scheme)
 	_ = awsiamv1beta1.AddToScheme(scheme)
@@ -52,17 +55,64 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "preflight" {
+		preflight(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		simulate(os.Args[2:])
+		return
+	}
+
 	var metricsAddr string
 	var region string
 	var oidcProviderARN string
 	var resourcePrefix string
 	var enableLeaderElection bool
+	var readOnly bool
+	var createOnly bool
+	var guardBoundaryRemoval bool
 	var requeueInterval time.Duration
+	var debounceWindow time.Duration
+	var annotationPrefix string
+	var uniqueRoleNames bool
+	var crdWaitTimeout time.Duration
+	var statusMessageLimit int
+	var maxManagedEntities int
+	var unusedRoleWindow time.Duration
+	var protectionTag string
+	var ownerTagFormat string
+	var validatePolicies bool
+	var strictPolicyValidation bool
+	var suggestLeastPrivilegeAfter time.Duration
+	var quotaCheckInterval time.Duration
+	var allowedOperations string
+	var policyVersionsToKeep int
+	var trustPolicySizeLimit int
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&region, "region", "eu-west-1", "The AWS region to use.")
 	flag.StringVar(&oidcProviderARN, "oidc-provider-arn", "", "The ARN for the identity provider to use for injecting IRSA trust statements.")
 	flag.DurationVar(&requeueInterval, "requeue-interaval", 30*time.Second, "The requeue interval to use do reconcile specific resources.")
+	flag.DurationVar(&debounceWindow, "debounce-window", 0, "The time a changed resource waits for further changes to settle before it is reconciled. Disabled by default.")
 	flag.StringVar(&resourcePrefix, "resource-prefix", "", "A prefix to prepend to all created AWS resources.")
+	flag.StringVar(&annotationPrefix, "annotation-prefix", "", "Annotations of resources starting with this prefix are copied into the emitted events (e.g. for change request numbers).")
+	flag.BoolVar(&readOnly, "read-only", false, "Only observe and report what would be done, without making any changes in AWS.")
+	flag.BoolVar(&createOnly, "create-only", false, "Only create missing resources in AWS, but never update or delete existing ones.")
+	flag.BoolVar(&guardBoundaryRemoval, "guard-boundary-removal", false, "Require the removal of a permissions boundary to be confirmed via the 'aws-iam.redradrat.xyz/allow-boundary-removal' annotation.")
+	flag.BoolVar(&uniqueRoleNames, "unique-role-names", false, "Refuse Roles whose AWS role name is already used by another Role in the cluster, regardless of namespace or path.")
+	flag.DurationVar(&crdWaitTimeout, "crd-wait-timeout", time.Minute, "How long to wait for the CRDs to be established before starting the controllers. 0 disables waiting.")
+	flag.IntVar(&statusMessageLimit, "status-message-limit", 0, "Truncate status messages to this number of bytes; the full message is emitted as event. 0 disables truncation.")
+	flag.IntVar(&maxManagedEntities, "max-managed-entities", 0, "Refuse to create AWS roles, policies, users and groups beyond this total number. 0 disables the cap.")
+	flag.DurationVar(&unusedRoleWindow, "unused-role-window", 0, "Flag Roles that have not been used within this duration via the Unused condition. 0 disables the check.")
+	flag.StringVar(&protectionTag, "deletion-protection-tag", "", "Never delete AWS roles and users carrying this tag key. Disabled by default.")
+	flag.StringVar(&ownerTagFormat, "owner-tag-format", controllers.DefaultOwnerTagFormat, "The format of the owning-app tag value of roles; ${app} is replaced by the name of the owning application, ${namespace} and ${name} by those of the Role.")
+	flag.BoolVar(&validatePolicies, "validate-policies", false, "Run policy documents through IAM Access Analyzer and report its findings.")
+	flag.BoolVar(&strictPolicyValidation, "strict-policy-validation", false, "Refuse policy documents for which IAM Access Analyzer reports errors. Implies --validate-policies.")
+	flag.StringVar(&allowedOperations, "allowed-operations", "", "Comma-separated list of AWS operations (e.g. 'iam:CreateRole') the operator may call; all others are blocked. Empty allows all operations.")
+	flag.DurationVar(&quotaCheckInterval, "quota-check-interval", 0, "Read the IAM account summary in this interval and expose the quota usage as metrics. 0 disables it.")
+	flag.IntVar(&trustPolicySizeLimit, "trust-policy-size-limit", controllers.DefaultTrustPolicySizeLimit, "Refuse trust policies exceeding this number of characters after compaction. Raise it along with the quota of the account (at most 4096).")
+	flag.IntVar(&policyVersionsToKeep, "policy-versions-to-keep", 0, "On startup, delete all but this many versions (including the default one) of every managed Policy. 0 disables it.")
+	flag.DurationVar(&suggestLeastPrivilegeAfter, "suggest-least-privilege-after", 0, "Suggest to remove services not accessed via Policies in use for this duration. 0 disables the suggestions.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -72,6 +122,16 @@ func main() {
 
 	ctrl.Log.Info(fmt.Sprintf("aws-iam-operator version: %s (built: %s)", operatorversion, operatorbuilddate))
 
+	// everything not on the allow-list is blocked in the AWS clients themselves
+	if allowedOperations != "" {
+		controllers.AllowOperations(strings.Split(allowedOperations, ","))
+	}
+
+	if readOnly && createOnly {
+		setupLog.Error(fmt.Errorf("--read-only and --create-only are mutually exclusive"), "invalid options. exiting...")
+		os.Exit(1)
+	}
+
 	if oidcProviderARN != "" {
 		if _, err := aws.ARNify(oidcProviderARN); err != nil {
 			setupLog.Error(err, "cannot parse given oidc provider arn. exiting...")
@@ -91,62 +151,188 @@ func main() {
 		os.Exit(1)
 	}
 
+	ctx := ctrl.SetupSignalHandler()
+
+	// on fresh installs the CRDs might not be served yet; starting the controllers against them only causes errors
+	if crdWaitTimeout > 0 {
+		setupLog.Info("waiting for CRDs to be established")
+		if err := controllers.WaitForCRDsEstablished(ctx, mgr.GetAPIReader(), controllers.ManagedCRDs, time.Second, crdWaitTimeout); err != nil {
+			setupLog.Error(err, "CRDs have not been established in time")
+			os.Exit(1)
+		}
+	}
+
+	notifier := controllers.NewNotifier(mgr.GetEventRecorderFor("aws-iam-operator"), annotationPrefix)
+	k8sClient := controllers.WithStatusMessageLimit(mgr.GetClient(), statusMessageLimit, notifier)
+
 	if err = (&controllers.RoleReconciler{
-		Client:          mgr.GetClient(),
-		Interval:        requeueInterval,
-		Log:             ctrl.Log.WithName("controllers").WithName("Role"),
-		Region:          region,
-		Scheme:          mgr.GetScheme(),
-		ResourcePrefix:  resourcePrefix,
-		OidcProviderARN: oidcProviderARN,
+		Client:               k8sClient,
+		Interval:             requeueInterval,
+		Log:                  ctrl.Log.WithName("controllers").WithName("Role"),
+		Region:               region,
+		Scheme:               mgr.GetScheme(),
+		ResourcePrefix:       resourcePrefix,
+		OidcProviderARN:      oidcProviderARN,
+		ReadOnly:             readOnly,
+		CreateOnly:           createOnly,
+		MaxManagedEntities:   maxManagedEntities,
+		GuardBoundaryRemoval: guardBoundaryRemoval,
+		ProtectionTag:        protectionTag,
+		OwnerTagFormat:       ownerTagFormat,
+		UniqueRoleNames:      uniqueRoleNames,
+		UnusedWindow:         unusedRoleWindow,
+		TrustPolicySizeLimit: trustPolicySizeLimit,
+		Debouncer:            controllers.NewDebouncer(debounceWindow),
+		Notifier:             notifier,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Role")
 		os.Exit(1)
 	}
 	if err = (&controllers.PolicyReconciler{
-		Client:         mgr.GetClient(),
-		Log:            ctrl.Log.WithName("controllers").WithName("Policy"),
-		Region:         region,
-		Scheme:         mgr.GetScheme(),
-		ResourcePrefix: resourcePrefix,
+		Client:                     k8sClient,
+		Log:                        ctrl.Log.WithName("controllers").WithName("Policy"),
+		Region:                     region,
+		Scheme:                     mgr.GetScheme(),
+		ResourcePrefix:             resourcePrefix,
+		ReadOnly:                   readOnly,
+		CreateOnly:                 createOnly,
+		MaxManagedEntities:         maxManagedEntities,
+		ValidatePolicies:           validatePolicies,
+		StrictPolicyValidation:     strictPolicyValidation,
+		SuggestLeastPrivilegeAfter: suggestLeastPrivilegeAfter,
+		Debouncer:                  controllers.NewDebouncer(debounceWindow),
+		Notifier:                   notifier,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Policy")
 		os.Exit(1)
 	}
 	if err = (&controllers.PolicyAttachmentReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("PolicyAttachment"),
-		Region: region,
-		Scheme: mgr.GetScheme(),
+		Client:     k8sClient,
+		Log:        ctrl.Log.WithName("controllers").WithName("PolicyAttachment"),
+		Region:     region,
+		Scheme:     mgr.GetScheme(),
+		ReadOnly:   readOnly,
+		CreateOnly: createOnly,
+		Debouncer:  controllers.NewDebouncer(debounceWindow),
+		Notifier:   notifier,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PolicyAttachment")
 		os.Exit(1)
 	}
+	if err = (&controllers.PolicyAttachmentSetReconciler{
+		Client:     k8sClient,
+		Log:        ctrl.Log.WithName("controllers").WithName("PolicyAttachmentSet"),
+		Region:     region,
+		Scheme:     mgr.GetScheme(),
+		ReadOnly:   readOnly,
+		CreateOnly: createOnly,
+		Debouncer:  controllers.NewDebouncer(debounceWindow),
+		Notifier:   notifier,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PolicyAttachmentSet")
+		os.Exit(1)
+	}
 	if err = (&controllers.GroupReconciler{
-		Client:         mgr.GetClient(),
-		Log:            ctrl.Log.WithName("controllers").WithName("Group"),
-		Region:         region,
-		Scheme:         mgr.GetScheme(),
-		ResourcePrefix: resourcePrefix,
+		Client:             k8sClient,
+		Log:                ctrl.Log.WithName("controllers").WithName("Group"),
+		Region:             region,
+		Scheme:             mgr.GetScheme(),
+		ResourcePrefix:     resourcePrefix,
+		ReadOnly:           readOnly,
+		CreateOnly:         createOnly,
+		MaxManagedEntities: maxManagedEntities,
+		Debouncer:          controllers.NewDebouncer(debounceWindow),
+		Notifier:           notifier,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Group")
 		os.Exit(1)
 	}
 	if err = (&controllers.UserReconciler{
-		Client:         mgr.GetClient(),
-		Log:            ctrl.Log.WithName("controllers").WithName("User"),
-		Region:         region,
-		Scheme:         mgr.GetScheme(),
-		ResourcePrefix: resourcePrefix,
+		Client:               k8sClient,
+		Log:                  ctrl.Log.WithName("controllers").WithName("User"),
+		Region:               region,
+		Scheme:               mgr.GetScheme(),
+		ResourcePrefix:       resourcePrefix,
+		ReadOnly:             readOnly,
+		CreateOnly:           createOnly,
+		MaxManagedEntities:   maxManagedEntities,
+		GuardBoundaryRemoval: guardBoundaryRemoval,
+		ProtectionTag:        protectionTag,
+		Debouncer:            controllers.NewDebouncer(debounceWindow),
+		Notifier:             notifier,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "User")
 		os.Exit(1)
 	}
 	// +kubebuilder:scaffold:builder
 
+	if policyVersionsToKeep > 0 {
+		if err := mgr.Add(&controllers.PolicyVersionSweep{
+			Client:     mgr.GetClient(),
+			Region:     region,
+			Keep:       policyVersionsToKeep,
+			ReadOnly:   readOnly,
+			CreateOnly: createOnly,
+			Log:        ctrl.Log.WithName("policy-version-sweep"),
+		}); err != nil {
+			setupLog.Error(err, "unable to add policy version sweep")
+			os.Exit(1)
+		}
+	}
+
+	if quotaCheckInterval > 0 {
+		if err := mgr.Add(&controllers.QuotaMonitor{
+			Region:   region,
+			Interval: quotaCheckInterval,
+			Log:      ctrl.Log.WithName("quota"),
+		}); err != nil {
+			setupLog.Error(err, "unable to add IAM quota monitor")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// preflight checks whether the identity of the operator is allowed to call all required IAM actions
+func preflight(args []string) {
+	fs := flag.NewFlagSet("preflight", flag.ExitOnError)
+	region := fs.String("region", "eu-west-1", "The AWS region to use.")
+	validatePolicies := fs.Bool("validate-policies", false, "Check the actions needed for --validate-policies as well.")
+	_ = fs.Parse(args)
+
+	allowed, err := controllers.RunPreflight(*region, *validatePolicies, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "preflight failed: %s\n", err)
+		os.Exit(2)
+	}
+	if !allowed {
+		os.Exit(1)
+	}
+}
+
+// simulate checks a principal against a set of allow/deny test cases
+func simulate(args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	region := fs.String("region", "eu-west-1", "The AWS region to use.")
+	principalArn := fs.String("principal-arn", "", "The ARN of the role (or user) to simulate the cases for.")
+	casesFile := fs.String("cases", "", "The file holding the YAML list of cases to simulate.")
+	_ = fs.Parse(args)
+
+	if *principalArn == "" || *casesFile == "" {
+		fmt.Fprintln(os.Stderr, "simulate requires --principal-arn and --cases")
+		os.Exit(2)
+	}
+	passed, err := controllers.RunSimulation(*region, *principalArn, *casesFile, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simulation failed: %s\n", err)
+		os.Exit(2)
+	}
+	if !passed {
+		os.Exit(1)
+	}
+}